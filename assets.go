@@ -0,0 +1,144 @@
+package deck
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Resolver locates the assets (images, text and code files, fonts) named in a deck.
+// Relative names are tried in the directory of the deck file, then in each directory
+// of the search path, and finally in the current directory.
+// The assets of a bundle are found only within the bundle.
+type Resolver struct {
+	Dir          string   // directory of the deck file, or the bundle file
+	Path         []string // search path, by default from DECKPATH
+	FS           fs.FS    // contents of a bundle, nil for decks read from files
+	missing      map[string]bool
+	errs         []error                // errors reading assets, other than missing files
+	dictionaries map[string]*Hyphenator // hyphenation dictionaries read for the deck
+}
+
+// SearchPath returns the list of directories in the DECKPATH environment variable
+func SearchPath() []string {
+	return filepath.SplitList(os.Getenv("DECKPATH"))
+}
+
+// NewResolver makes a resolver for the assets of the named deck file.
+// An empty name, or "-" (standard input) resolves relative to the current directory.
+func NewResolver(filename string) *Resolver {
+	dir := "."
+	if filename != "" && filename != "-" {
+		dir = filepath.Dir(filename)
+	}
+	return &Resolver{Dir: dir, Path: SearchPath(), missing: map[string]bool{}}
+}
+
+// remote determines if a name refers to a URL instead of a file
+func remote(name string) bool {
+	return strings.Contains(name, "://") || strings.HasPrefix(name, "data:")
+}
+
+// candidates returns the locations to try for an asset, in order
+func (r *Resolver) candidates(name string) []string {
+	if filepath.IsAbs(name) {
+		return []string{name}
+	}
+	c := []string{filepath.Join(r.Dir, name)}
+	for _, dir := range r.Path {
+		c = append(c, filepath.Join(dir, name))
+	}
+	return append(c, name)
+}
+
+// Resolve returns the location of the named asset. Names that cannot be found
+// are recorded (see Missing) and returned unchanged, so the caller's error refers to the original name.
+// A nil Resolver returns every name unchanged.
+func (r *Resolver) Resolve(name string) string {
+	if r == nil || name == "" || remote(name) {
+		return name
+	}
+	if r.FS != nil {
+		p := path.Clean(filepath.ToSlash(name))
+		if _, err := fs.Stat(r.FS, p); err == nil {
+			return p
+		}
+		r.report(name)
+		return name
+	}
+	for _, f := range r.candidates(name) {
+		if _, err := os.Stat(f); err == nil {
+			return f
+		}
+	}
+	r.report(name)
+	return name
+}
+
+// report notes an unresolved asset
+func (r *Resolver) report(name string) {
+	if r.missing == nil {
+		r.missing = map[string]bool{}
+	}
+	r.missing[name] = true
+}
+
+// Open opens the named asset
+func (r *Resolver) Open(name string) (io.ReadCloser, error) {
+	if r != nil && r.FS != nil {
+		return r.FS.Open(r.Resolve(name))
+	}
+	return os.Open(r.Resolve(name))
+}
+
+// ReadFile returns the contents of the named asset
+func (r *Resolver) ReadFile(name string) ([]byte, error) {
+	if r != nil && r.FS != nil {
+		return fs.ReadFile(r.FS, r.Resolve(name))
+	}
+	return ioutil.ReadFile(r.Resolve(name))
+}
+
+// Font returns the contents of a font file carried in the fonts directory of a bundle.
+// The result is false for decks that are not bundles, or bundles without the font.
+func (r *Resolver) Font(file string) ([]byte, bool) {
+	if r == nil || r.FS == nil {
+		return nil, false
+	}
+	data, err := fs.ReadFile(r.FS, path.Join(BundleFonts, file))
+	return data, err == nil
+}
+
+// Missing returns the names of the assets that could not be resolved
+func (r *Resolver) Missing() []string {
+	var names []string
+	for name := range r.missing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Errors returns the problems found with the assets of a deck: the names that could not be found,
+// with the places searched, followed by the assets that could not be read
+func (r *Resolver) Errors() []error {
+	if r == nil {
+		return nil
+	}
+	var errs []error
+	for _, name := range r.Missing() {
+		if r.FS != nil {
+			errs = append(errs, fmt.Errorf("cannot find %q in %s", name, r.Dir))
+			continue
+		}
+		dirs := append([]string{r.Dir}, r.Path...)
+		errs = append(errs, fmt.Errorf("cannot find %q (searched %s)", name, strings.Join(dirs, string(filepath.ListSeparator))))
+	}
+	return append(errs, r.errs...)
+}
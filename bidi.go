@@ -0,0 +1,282 @@
+package deck
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/bidi"
+)
+
+// RTL reports whether text is laid out right to left, according to its dir attribute:
+// "rtl", "ltr" (the default), or "auto", which takes the direction of the first strongly directional character.
+func RTL(s, dir string) bool {
+	switch dir {
+	case "rtl":
+		return true
+	case "auto":
+		for _, r := range s {
+			switch class(r) {
+			case bidi.L:
+				return false
+			case bidi.R, bidi.AL:
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Align returns the alignment of right to left text, mirroring the start and end
+func Align(align string, rtl bool) string {
+	if !rtl {
+		return align
+	}
+	switch align {
+	case "center", "middle", "mid", "c":
+		return align
+	case "right", "end", "e":
+		return "left"
+	}
+	return "right"
+}
+
+// class returns the bidirectional class of a character
+func class(r rune) bidi.Class {
+	p, _ := bidi.LookupRune(r)
+	return p.Class()
+}
+
+const (
+	lrm      = '‎' // left to right mark
+	nestmark = '￼' // object replacement character, standing in for nested text
+)
+
+// marks are the invisible directional marks, which are not drawn
+var marks = map[rune]bool{'‎': true, '‏': true, '؜': true}
+
+// Visual returns a line of text in display (left to right) order, applying the Unicode
+// bidirectional algorithm of golang.org/x/text/unicode/bidi to a paragraph of the given direction.
+// Brackets are mirrored in right to left runs, and the directional controls and marks are removed.
+// The text of isolates (LRI, RLI, FSI) and embeddings (LRE, RLE) is ordered by its own direction,
+// and placed as a unit; overrides (LRO, RLO) force the direction of their text.
+func Visual(s string, rtl bool) string {
+	if !rtl && !bidirectional(s) {
+		return s
+	}
+	return strings.Join(visual(s, rtl), "")
+}
+
+// bidirectional determines if text has right to left characters or directional controls
+func bidirectional(s string) bool {
+	for _, r := range s {
+		switch class(r) {
+		case bidi.R, bidi.AL, bidi.AN, bidi.LRE, bidi.RLE, bidi.LRO, bidi.RLO, bidi.PDF, bidi.LRI, bidi.RLI, bidi.FSI, bidi.PDI:
+			return true
+		}
+	}
+	return false
+}
+
+// visual returns the pieces of a line in display order: characters, and the ordered text of
+// isolates and embeddings, which stand in the paragraph as neutral characters
+func visual(s string, rtl bool) []string {
+	var pieces []string
+	var text []rune
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch c := class(r); c {
+		case bidi.LRI, bidi.RLI, bidi.FSI, bidi.LRE, bidi.RLE, bidi.LRO, bidi.RLO:
+			j := closing(runes, i)
+			pieces = append(pieces, nested(c, string(runes[i+1:j])))
+			text = append(text, nestmark)
+			i = j
+		case bidi.PDI, bidi.PDF:
+			continue
+		default:
+			if marks[r] {
+				pieces = append(pieces, "")
+			} else {
+				pieces = append(pieces, string(r))
+			}
+			text = append(text, r)
+		}
+	}
+	// a paragraph is right to left when its first strong character is, unless marked otherwise
+	shift := 0
+	if !rtl {
+		shift = 1
+	}
+	o, err := order(text, rtl)
+	if err != nil {
+		return pieces
+	}
+	levels := make([]int, len(pieces))
+	prev := bidi.LeftToRight
+	for i := 0; i < o.NumRuns(); i++ {
+		run := o.Run(i)
+		start, end := run.Pos()
+		start, end = start-shift, end-shift
+		if start < 0 {
+			start = 0
+		}
+		level := runlevel(run.Direction(), rtl)
+		// numbers following right to left text in a left to right paragraph are a level higher
+		numbers := -1
+		if !rtl && level == 0 && prev == bidi.RightToLeft {
+			numbers = numberend(text[start : end+1])
+		}
+		for k := start; k <= end; k++ {
+			levels[k] = level
+			if k-start <= numbers {
+				levels[k] = 2
+			}
+		}
+		prev = run.Direction()
+	}
+	return reorder(pieces, levels)
+}
+
+// order runs the bidirectional algorithm over a paragraph, a left to right one led by a mark.
+// Releases of golang.org/x/text before v0.42.0 leave the algorithm unimplemented, and panic;
+// their error leaves the text in logical order.
+func order(text []rune, rtl bool) (o bidi.Ordering, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("bidi: %v", r)
+		}
+	}()
+	var p bidi.Paragraph
+	if rtl {
+		_, err = p.SetString(string(text), bidi.DefaultDirection(bidi.RightToLeft))
+	} else {
+		_, err = p.SetString(string(append([]rune{lrm}, text...)))
+	}
+	if err != nil {
+		return o, err
+	}
+	return p.Order()
+}
+
+// runlevel returns the embedding level of a run of the given direction,
+// in a paragraph of the given direction
+func runlevel(dir bidi.Direction, rtl bool) int {
+	switch {
+	case rtl && dir == bidi.RightToLeft:
+		return 1
+	case rtl:
+		return 2
+	case dir == bidi.RightToLeft:
+		return 1
+	}
+	return 0
+}
+
+// numberend returns the index of the end of the number beginning a left to right run,
+// or -1 if the run does not begin with a number
+func numberend(text []rune) int {
+	end := -1
+	for k, r := range text {
+		switch class(r) {
+		case bidi.EN, bidi.AN:
+			end = k
+		case bidi.ET:
+			if end == k-1 && end >= 0 {
+				end = k
+			}
+		case bidi.ES, bidi.CS, bidi.NSM:
+		default:
+			return end
+		}
+	}
+	return end
+}
+
+// closing returns the index of the character closing the isolate or embedding beginning at i,
+// or the end of the text if it is not closed
+func closing(runes []rune, i int) int {
+	c := class(runes[i])
+	isolate := c == bidi.LRI || c == bidi.RLI || c == bidi.FSI
+	depth := 0
+	for j := i + 1; j < len(runes); j++ {
+		switch c := class(runes[j]); {
+		case isolate && (c == bidi.LRI || c == bidi.RLI || c == bidi.FSI):
+			depth++
+		case !isolate && (c == bidi.LRE || c == bidi.RLE || c == bidi.LRO || c == bidi.RLO):
+			depth++
+		case (isolate && c == bidi.PDI) || (!isolate && c == bidi.PDF):
+			if depth == 0 {
+				return j
+			}
+			depth--
+		}
+	}
+	return len(runes)
+}
+
+// nested returns the text of an isolate or embedding in display order
+func nested(c bidi.Class, s string) string {
+	switch c {
+	case bidi.LRI, bidi.LRE:
+		return Visual(s, false)
+	case bidi.RLI, bidi.RLE:
+		return Visual(s, true)
+	case bidi.FSI:
+		return Visual(s, RTL(s, "auto"))
+	case bidi.LRO:
+		return plain(s)
+	}
+	return bidi.ReverseString(plain(s))
+}
+
+// plain returns text without its directional controls and marks
+func plain(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch class(r) {
+		case bidi.LRE, bidi.RLE, bidi.LRO, bidi.RLO, bidi.PDF, bidi.LRI, bidi.RLI, bidi.FSI, bidi.PDI:
+			return -1
+		}
+		if marks[r] {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// reorder returns pieces of text in display order, given their levels (rule L2),
+// mirroring the brackets of right to left text (rule L4)
+func reorder(pieces []string, levels []int) []string {
+	highest, lowest := 0, 255
+	for i, l := range levels {
+		if l%2 == 1 {
+			if utf8.RuneCountInString(pieces[i]) == 1 {
+				pieces[i] = bidi.ReverseString(pieces[i])
+			}
+			if l < lowest {
+				lowest = l
+			}
+		}
+		if l > highest {
+			highest = l
+		}
+	}
+	for l := highest; l >= lowest && l > 0; l-- {
+		for i := 0; i < len(pieces); {
+			if levels[i] < l {
+				i++
+				continue
+			}
+			j := i
+			for j < len(pieces) && levels[j] >= l {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				pieces[a], pieces[b] = pieces[b], pieces[a]
+				levels[a], levels[b] = levels[b], levels[a]
+			}
+			i = j
+		}
+	}
+	return pieces
+}
@@ -0,0 +1,85 @@
+package deck
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// A bundle is a zip archive holding a deck with its assets:
+//
+//	deck.xml        the deck description (or the first .xml file at the top level)
+//	images, code    named in the deck, relative to the top of the archive
+//	fonts/          optional TrueType fonts, named as the renderers name them (for example fonts/Helvetica.ttf)
+//
+// A directory with the same layout and extension (an unpacked bundle) is read the same way.
+const (
+	BundleExt   = ".deckz"   // file extension of a bundle
+	BundleDeck  = "deck.xml" // preferred name of the deck within a bundle
+	BundleFonts = "fonts"    // directory of fonts within a bundle
+)
+
+// IsBundle determines if the named file is a bundle
+func IsBundle(filename string) bool {
+	return strings.HasSuffix(filename, BundleExt)
+}
+
+// Deckfile returns the name of the deck description within the bundle contents
+func Deckfile(fsys fs.FS) (string, error) {
+	if _, err := fs.Stat(fsys, BundleDeck); err == nil {
+		return BundleDeck, nil
+	}
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if !e.IsDir() && path.Ext(e.Name()) == ".xml" {
+			return e.Name(), nil
+		}
+	}
+	return "", fmt.Errorf("no deck in bundle")
+}
+
+// OpenBundle returns the contents of a bundle
+func OpenBundle(filename string) (fs.FS, error) {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return os.DirFS(filename), nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return zr, nil
+}
+
+// ReadBundle reads a deck and its assets from a bundle
+func ReadBundle(filename string, w, h int, tags ...string) (Deck, error) {
+	var d Deck
+	fsys, err := OpenBundle(filename)
+	if err != nil {
+		return d, err
+	}
+	name, err := Deckfile(fsys)
+	if err != nil {
+		return d, fmt.Errorf("%s: %v", filename, err)
+	}
+	r, err := fsys.Open(name)
+	if err != nil {
+		return d, err
+	}
+	return readdeck(r, w, h, &Resolver{Dir: filename, FS: fsys, missing: map[string]bool{}}, tags)
+}
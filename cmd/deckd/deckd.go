@@ -2,13 +2,17 @@
 package main
 
 import (
+	"archive/zip"
 	"bufio"
+	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"image"
 	_ "image/jpeg"
 	_ "image/png"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -25,10 +29,11 @@ import (
 
 const (
 	timeformat = "Jan 2, 2006, 3:04pm (MST)"
-	deckpat    = `\.xml$`
+	deckpat    = `\.xml$|\.deckz$`
 	imgpat     = `\.png$|\.jpg$|\.jpeg$`
 	vidpat     = `\.mov$|\.mp4$|\.m4v$|\.avi$|\.h264$`
-	stdpat     = `\.xml$|` + imgpat
+	stdpat     = deckpat + `|` + imgpat
+	maxentries = 1000 // maximum number of files in an uploaded bundle
 	inforesp   = "{\"API\":[{\"deck\":\"/deck/\"},{\"upload\":\"/upload/\"},{\"media\":\"/media/\"},{\"table\":\"/table/\"}]}\n"
 	errmeta    = "."
 	stdmeta    = "..."
@@ -178,12 +183,12 @@ func dodeck(w http.ResponseWriter, req *http.Request) {
 			log.Printf("%s %v", requester, err)
 			return
 		}
-		if fs.IsDir() {
+		if fs.IsDir() && !unpacked(deck) {
 			eresp(w, "cannot remove directories", http.StatusInternalServerError)
 			log.Printf("%s cannot remove directories", requester)
 			return
 		}
-		err = os.Remove(deck)
+		err = os.RemoveAll(deck)
 		if err != nil {
 			eresp(w, err.Error(), http.StatusInternalServerError)
 			log.Printf("%s %v", requester, err)
@@ -195,7 +200,7 @@ func dodeck(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
-// upload uploads decks from POSTed data
+// upload uploads decks from POSTed data, unpacking bundles
 // POST /upload, Deck:<file>
 func upload(w http.ResponseWriter, req *http.Request) {
 	requester := req.RemoteAddr
@@ -223,7 +228,11 @@ func upload(w http.ResponseWriter, req *http.Request) {
 			log.Printf(requester + " " + msg)
 			return
 		}
-		err = ioutil.WriteFile(deckpath, deckdata, 0644)
+		if deck.IsBundle(deckpath) {
+			err = unbundle(deckpath, deckdata)
+		} else {
+			err = ioutil.WriteFile(deckpath, deckdata, 0644)
+		}
 		if err != nil {
 			eresp(w, err.Error(), http.StatusInternalServerError)
 			log.Printf("%s %v", requester, err)
@@ -234,6 +243,95 @@ func upload(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// unpacked determines if a directory is an unpacked bundle
+func unpacked(name string) bool {
+	if !deck.IsBundle(name) {
+		return false
+	}
+	_, err := deck.Deckfile(os.DirFS(name))
+	return err == nil
+}
+
+// unbundle safely unpacks an uploaded bundle into a directory of the same name,
+// replacing a previous upload. Entries must be regular files with relative paths inside the bundle,
+// and the unpacked size may not exceed the upload limit.
+func unbundle(dest string, data []byte) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("upload: %v", err)
+	}
+	if len(zr.File) > maxentries {
+		return fmt.Errorf("upload: %d files over the limit of %d", len(zr.File)-maxentries, maxentries)
+	}
+	if _, err := deck.Deckfile(zr); err != nil {
+		return fmt.Errorf("upload: %v", err)
+	}
+	tmp, err := ioutil.TempDir(".", ".upload-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	var total int64
+	for _, f := range zr.File {
+		name := strings.TrimSuffix(f.Name, "/")
+		if !fs.ValidPath(name) || strings.Contains(name, `\`) {
+			return fmt.Errorf("upload: invalid name %q in bundle", f.Name)
+		}
+		target := filepath.Join(tmp, filepath.FromSlash(name))
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			return fmt.Errorf("upload: %q in bundle is not a regular file", f.Name)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		n, err := extract(target, f, *maxupload-total)
+		if err != nil {
+			return err
+		}
+		total += n
+	}
+	if fi, err := os.Stat(dest); err == nil {
+		if fi.IsDir() && !unpacked(dest) {
+			return fmt.Errorf("upload: %s is a directory", dest)
+		}
+		if err := os.RemoveAll(dest); err != nil {
+			return err
+		}
+	}
+	if err := os.Chmod(tmp, 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
+
+// extract copies a file from a bundle, reading no more than limit bytes
+func extract(target string, f *zip.File, limit int64) (int64, error) {
+	r, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	w, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(w, io.LimitReader(r, limit+1))
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n > limit {
+		err = fmt.Errorf("upload: bundle unpacks to more than %d bytes", *maxupload)
+	}
+	return n, err
+}
+
 // media plays video
 // POST /media Media:<file>
 func media(w http.ResponseWriter, req *http.Request) {
@@ -350,33 +448,56 @@ func eresp(w http.ResponseWriter, err string, code int) {
 	http.Error(w, fmt.Sprintf("{\"error\": \"%s\"}", err), code)
 }
 
-func metadata(filename string) string {
-	if strings.HasSuffix(filename, ".xml") {
+// entry describes a file in the deck listing
+type entry struct {
+	Name       string            `json:"name"`
+	Meta       string            `json:"meta"`
+	Date       string            `json:"date"`
+	Slides     int               `json:"slides,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+// metadata describes a deck by its number of slides and its properties, and an image by its size
+func metadata(filename string) entry {
+	e := entry{Name: filename, Meta: stdmeta}
+	if strings.HasSuffix(filename, ".xml") || deck.IsBundle(filename) {
 		d, err := deck.Read(filename, 0, 0)
 		if err != nil {
-			return errmeta
+			e.Meta = errmeta
+			return e
 		}
 		ns := len(d.Slide)
 		if ns == 0 {
-			return errmeta
+			e.Meta = errmeta
+			return e
+		}
+		e.Meta = fmt.Sprintf("%d slides", ns)
+		e.Slides = ns
+		for _, p := range d.Properties() {
+			if e.Properties == nil {
+				e.Properties = map[string]string{}
+			}
+			e.Properties[p.Name] = p.Value
 		}
-		return fmt.Sprintf("%d slides", ns)
+		return e
 	}
 
 	if strings.HasSuffix(filename, ".png") || strings.HasSuffix(filename, ".jpg") || strings.HasSuffix(filename, ".jpeg") {
 		var err error
 		f, err := os.Open(filename)
 		if err != nil {
-			return errmeta
+			e.Meta = errmeta
+			return e
 		}
 		defer f.Close()
 		im, _, err := image.DecodeConfig(f)
 		if err != nil {
-			return errmeta
+			e.Meta = errmeta
+			return e
 		}
-		return fmt.Sprintf("(%d x %d)", im.Width, im.Height)
+		e.Meta = fmt.Sprintf("(%d x %d)", im.Width, im.Height)
 	}
-	return stdmeta
+	return e
 }
 
 // deckinfo returns information (file, size, date) for a deck and movie files in the deck directory
@@ -387,16 +508,20 @@ func deckinfo(w http.ResponseWriter, data []os.FileInfo, pattern string) {
 	for _, s := range data {
 		matched, err := regexp.MatchString(pattern, s.Name())
 		if err == nil && matched {
-			meta := metadata(s.Name())
-			if meta == errmeta {
+			e := metadata(s.Name())
+			if e.Meta == errmeta {
+				continue
+			}
+			e.Date = s.ModTime().Format(timeformat)
+			b, err := json.Marshal(e)
+			if err != nil {
 				continue
 			}
 			nf++
 			if nf > 1 {
 				io.WriteString(w, ",\n")
 			}
-			io.WriteString(w, fmt.Sprintf(`{"name":"%s", "meta":"%s", "date":"%s"}`,
-				s.Name(), meta, s.ModTime().Format(timeformat)))
+			w.Write(b)
 		}
 	}
 	io.WriteString(w, "]}\n")
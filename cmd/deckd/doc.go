@@ -15,7 +15,11 @@ Command line options control the working directory and address:port
 
 GET / lists the API
 
-GET /deck lists information on content, (filename, file size, modification time) in JSON
+GET /deck lists information on content, (filename, file size, modification time) in JSON.
+Decks are listed with their number of slides and their metadata properties, for example:
+
+	{"name":"talk.xml","meta":"12 slides","date":"Jan 2, 2006, 3:04pm (MST)","slides":12,
+	 "properties":{"title":"Go Fast","language":"en","event":"GopherCon"}}
 
 GET /deck?filter=[type] filter content list by type (std, deck, image, video)
 
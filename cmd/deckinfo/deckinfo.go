@@ -124,13 +124,17 @@ func main() {
 		if *showit {
 			fmt.Println("deck")
 		}
-		var texts, images, lists, arcs, lines, ellipses, rects, curves, polygons, links int
+		var texts, textpaths, images, lists, arcs, lines, ellipses, rects, curves, polygons, qrcodes, links, hidden int
 		show("// slide count", len(d.Slide))
 		for ns, s := range d.Slide {
+			if s.IsHidden() {
+				hidden++
+			}
 			if *showit {
 				showitems(s, ns)
 			}
 			texts += len(s.Text)
+			textpaths += len(s.TextPath)
 			images += len(s.Image)
 			lists += len(s.List)
 			lines += len(s.Line)
@@ -139,9 +143,12 @@ func main() {
 			ellipses += len(s.Ellipse)
 			curves += len(s.Curve)
 			polygons += len(s.Polygon)
+			qrcodes += len(s.QRCode)
 		}
 
+		show("// hidden slides", hidden)
 		show("// text", texts)
+		show("// textpath", textpaths)
 		show("// image", images)
 		show("// link", links)
 		show("// list", lists)
@@ -151,6 +158,7 @@ func main() {
 		show("// arc", arcs)
 		show("// curve", curves)
 		show("// polygon", polygons)
+		show("// qrcode", qrcodes)
 	}
 	if *showit {
 		fmt.Println("edeck")
@@ -0,0 +1,72 @@
+// decklint: check the contrast and legibility of decks
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ajstarks/deck"
+)
+
+// report is the JSON form of the problems found in a deck
+type report struct {
+	File     string         `json:"file"`
+	Error    string         `json:"error,omitempty"`
+	Problems []deck.Problem `json:"problems"`
+}
+
+func main() {
+	var (
+		contrast = flag.Float64("contrast", deck.DefaultLimits.Contrast, "minimum contrast ratio")
+		large    = flag.Float64("largecontrast", deck.DefaultLimits.LargeContrast, "minimum contrast ratio of large text")
+		minsize  = flag.Float64("minsize", deck.DefaultLimits.MinSize, "minimum text size (percentage of canvas width)")
+		words    = flag.Int("words", deck.DefaultLimits.MaxWords, "maximum words per slide")
+		width    = flag.Int("w", 792, "canvas width, if not set by the deck")
+		height   = flag.Int("h", 612, "canvas height, if not set by the deck")
+		jsonout  = flag.Bool("json", false, "JSON output")
+	)
+	flag.Parse()
+
+	limits := deck.DefaultLimits
+	limits.Contrast = *contrast
+	limits.LargeContrast = *large
+	limits.MinSize = *minsize
+	limits.MaxWords = *words
+
+	status := 0
+	reports := []report{}
+	for _, file := range flag.Args() {
+		r := report{File: file, Problems: []deck.Problem{}}
+		d, err := deck.Read(file, *width, *height)
+		if err != nil {
+			r.Error = err.Error()
+			fmt.Fprintf(os.Stderr, "decklint: %v\n", err)
+			status = 2
+		} else {
+			r.Problems = append(r.Problems, deck.Lint(d, limits)...)
+			for _, err := range d.Assets.Errors() {
+				fmt.Fprintf(os.Stderr, "decklint: %s: %v\n", file, err)
+			}
+		}
+		if len(r.Problems) > 0 && status == 0 {
+			status = 1
+		}
+		if !*jsonout {
+			for _, p := range r.Problems {
+				fmt.Printf("%s: %v\n", file, p)
+			}
+		}
+		reports = append(reports, r)
+	}
+	if *jsonout {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(reports); err != nil {
+			fmt.Fprintf(os.Stderr, "decklint: %v\n", err)
+			status = 2
+		}
+	}
+	os.Exit(status)
+}
@@ -0,0 +1,22 @@
+/*
+decklint checks decks for contrast and legibility problems.
+
+Usage
+
+	$ decklint deck.xml ...
+
+Each problem is reported with its file, slide and element:
+
+	deck.xml: slide 3: text 2: contrast: contrast 3.95:1 of gray on rgb(255,255,255) is less than 4.5:1
+
+the -contrast and -largecontrast options set the minimum contrast ratios of text and large text.
+
+the -minsize option sets the minimum text size, as a percentage of the canvas width.
+
+the -words option sets the maximum number of words on a slide.
+
+the -w and -h options set the canvas size for decks that do not specify one.
+
+the -json option writes the problems for all files as JSON.
+*/
+package main
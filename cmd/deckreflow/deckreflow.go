@@ -0,0 +1,60 @@
+// deckreflow: convert decks to a canvas of a different size or aspect ratio
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ajstarks/deck"
+)
+
+// $ deckreflow -size 1152,648 old.xml > new.xml
+func main() {
+	var (
+		size   = flag.String("size", "1152,648", "new canvas size (w,h)")
+		mode   = flag.String("mode", deck.Smart, "reflow mode: letterbox, stretch, or smart")
+		width  = flag.Int("w", 792, "canvas width, if not set by the deck")
+		height = flag.Int("h", 612, "canvas height, if not set by the deck")
+		dest   = flag.String("o", "", "output destination (default standard output)")
+	)
+	flag.Parse()
+
+	var nw, nh int
+	if n, err := fmt.Sscanf(*size, "%d,%d", &nw, &nh); n != 2 || err != nil {
+		fmt.Fprintf(os.Stderr, "deckreflow: bad size %q (w,h)\n", *size)
+		os.Exit(1)
+	}
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: deckreflow [options] file.xml")
+		os.Exit(1)
+	}
+	// the deck is reflowed as written, so that it can still be edited
+	d, err := deck.Decode(flag.Arg(0), *width, *height)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "deckreflow: %v\n", err)
+		os.Exit(2)
+	}
+	d, err = deck.Reflow(d, nw, nh, *mode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "deckreflow: %v\n", err)
+		os.Exit(1)
+	}
+	var output io.WriteCloser = os.Stdout
+	if len(*dest) > 0 {
+		output, err = os.Create(*dest)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "deckreflow: %v\n", err)
+			os.Exit(2)
+		}
+		// files named relative to the input are renamed relative to the output
+		d.Rebase(d.Assets.Dir, filepath.Dir(*dest))
+	}
+	if err := d.Encode(output); err != nil {
+		fmt.Fprintf(os.Stderr, "deckreflow: %v\n", err)
+		os.Exit(2)
+	}
+	output.Close()
+}
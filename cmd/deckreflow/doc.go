@@ -0,0 +1,24 @@
+/*
+deckreflow converts a deck to a canvas of a different size or aspect ratio, for example from 4:3 to 16:9.
+
+Usage
+
+	$ deckreflow -size 1152,648 -mode smart talk.xml > talk-wide.xml
+
+the -size option sets the new canvas size, w,h.
+
+the -mode option chooses how the content is placed on the new canvas:
+letterbox fits each slide, unchanged, in a centered area of the new canvas;
+stretch keeps the percentages, so content stretches with the canvas;
+smart (the default) moves content with the canvas, keeping the size of text and images and the shape of graphics.
+
+the -w and -h options set the canvas size of decks that do not specify one.
+
+the -o option names the output file; the default is the standard output.
+
+The converted deck is written as it was written, so it can still be edited: headers, footers, sections,
+tables of contents, markdown, placeholders, comments and the layout of the markup are kept as they are;
+only the attributes that change are rewritten, and a canvas element is added if the deck had none.
+With -o, images, text files, and fonts named relative to the input are renamed relative to the output.
+*/
+package main
@@ -3,9 +3,11 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
 	"math/rand"
 	"os"
@@ -15,6 +17,8 @@ import (
 	"strings"
 	"text/scanner"
 	"time"
+
+	"github.com/ajstarks/deck"
 )
 
 // types of for loops
@@ -35,6 +39,15 @@ const (
 // emap is the id=expression map
 var emap = map[string]string{}
 
+// assets locates the files named in the input, relative to the input file and DECKPATH
+var assets = deck.NewResolver("")
+
+// variant holds the tags selecting the variant of the deck made with -tags
+var variant []string
+
+// bulletstyle holds the attributes of the bullets of the next list
+var bulletstyle string
+
 // xmlmap defines the XML substitutions
 var xmlmap = strings.NewReplacer(
 	"&", "&amp;",
@@ -279,7 +292,7 @@ func parse(src string) []string {
 func endtag(w io.Writer, s []string, linenumber int) error {
 	tag := s[0]
 	if len(tag) < 2 || tag[0:1] != "e" {
-		return fmt.Errorf("line %d: edeck, eslide, or elist", linenumber)
+		return fmt.Errorf("line %d: edeck, eslide, esection, edivider, eheader, efooter, or elist", linenumber)
 	}
 	fmt.Fprintf(w, "</%s>\n", tag[1:])
 	return nil
@@ -341,8 +354,8 @@ func filequote(s string, linenumber int) (string, error) {
 	return s[1:end], nil
 }
 
-// deck produces the "deck" element
-func deck(w io.Writer, s []string, linenumber int) error {
+// startdeck produces the "deck" element
+func startdeck(w io.Writer, s []string, linenumber int) error {
 	_, err := fmt.Fprintln(w, "<deck>")
 	return err
 }
@@ -360,18 +373,138 @@ func canvas(w io.Writer, s []string, linenumber int) error {
 	return nil
 }
 
-// slide produces the "slide" element
+// fontfile declares a font for the deck
+func fontfile(w io.Writer, s []string, linenumber int) error {
+	if len(s) != 3 {
+		return fmt.Errorf("line %d: %s name \"file\"", linenumber, s[0])
+	}
+	filearg, err := filequote(s[2], linenumber)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "<font name=%q file=%q/>\n", s[1], filearg)
+	return nil
+}
+
+// fallback declares the fallback fonts of a font
+func fallback(w io.Writer, s []string, linenumber int) error {
+	if len(s) < 3 {
+		return fmt.Errorf("line %d: %s name font...", linenumber, s[0])
+	}
+	fmt.Fprintf(w, "<font name=%q fallback=%q/>\n", s[1], strings.Join(s[2:], " "))
+	return nil
+}
+
+// overlay begins the "header" or "footer" element, whose content is drawn on every slide
+func overlay(w io.Writer, s []string, linenumber int) error {
+	if len(s) != 1 {
+		return fmt.Errorf("line %d: %s", linenumber, s[0])
+	}
+	_, err := fmt.Fprintf(w, "<%s>\n", s[0])
+	return err
+}
+
+// block is an only or except block, holding content for some variants of the deck
+type block struct {
+	kind string    // only or except
+	w    io.Writer // the output enclosing the block
+}
+
+// beginblock begins an only or except block, returning its output. When making a variant (-tags),
+// the content of the block is kept or left out; otherwise its elements are marked with the block's tags.
+func beginblock(w io.Writer, s []string, blocks []block, linenumber int) (io.Writer, error) {
+	if len(s) != 2 {
+		return w, fmt.Errorf("line %d: %s \"tag,...\"", linenumber, s[0])
+	}
+	tags := strings.Trim(s[1], "\"")
+	if len(variant) > 0 {
+		v := deck.Variant{Only: tags}
+		if s[0] == "except" {
+			v = deck.Variant{Except: tags}
+		}
+		if !v.Selected(variant) {
+			return ioutil.Discard, nil
+		}
+		return w, nil
+	}
+	for _, b := range blocks {
+		if b.kind == s[0] {
+			return w, fmt.Errorf("line %d: nested %s blocks need -tags", linenumber, s[0])
+		}
+	}
+	return &attrwriter{w: w, attr: fmt.Sprintf(" %s=\"%s\"", s[0], xmlesc(tags)), begin: true}, nil
+}
+
+// attrwriter adds an attribute to the elements that begin lines
+type attrwriter struct {
+	w     io.Writer
+	attr  string
+	begin bool // at the beginning of a line
+}
+
+func (a *attrwriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n') + 1
+		if i == 0 {
+			i = len(p)
+		}
+		line := p[:i]
+		if a.begin && len(line) > 1 && line[0] == '<' && line[1] >= 'a' && line[1] <= 'z' {
+			j := bytes.IndexAny(line, " />")
+			if j < 0 {
+				j = len(line)
+			}
+			line = append(append(append([]byte{}, line[:j]...), a.attr...), line[j:]...)
+		}
+		if _, err := a.w.Write(line); err != nil {
+			return 0, err
+		}
+		a.begin = p[i-1] == '\n'
+		p = p[i:]
+	}
+	return n, nil
+}
+
+// slide produces the "slide" element, or the "divider" element styling section dividers
 func slide(w io.Writer, s []string, linenumber int) error {
 	switch len(s) {
 	case 1:
-		fmt.Fprintln(w, "<slide>")
+		fmt.Fprintf(w, "<%s>\n", s[0])
 	case 2:
-		fmt.Fprintf(w, "<slide bg=%s>\n", s[1])
+		fmt.Fprintf(w, "<%s bg=%s>\n", s[0], s[1])
 	case 3:
-		fmt.Fprintf(w, "<slide bg=%s fg=%s>\n", s[1], s[2])
+		fmt.Fprintf(w, "<%s bg=%s fg=%s>\n", s[0], s[1], s[2])
 	default:
-		return fmt.Errorf("line %d: slide [bgcolor] [fgcolor]", linenumber)
+		return fmt.Errorf("line %d: %s [bgcolor] [fgcolor]", linenumber, s[0])
+	}
+	return nil
+}
+
+// section begins the "section" element, optionally with a divider slide
+func section(w io.Writer, s []string, linenumber int) error {
+	switch {
+	case len(s) == 2:
+		fmt.Fprintf(w, "<section title=\"%s\">\n", qesc(s[1]))
+	case len(s) == 3 && s[2] == "divider":
+		fmt.Fprintf(w, "<section title=\"%s\" divider=\"on\">\n", qesc(s[1]))
+	default:
+		return fmt.Errorf("line %d: section \"title\" [divider]", linenumber)
+	}
+	return nil
+}
+
+// toc produces the "toc" element, listing the deck's sections
+func toc(w io.Writer, s []string, linenumber int) error {
+	n := len(s)
+	if n < 4 {
+		return fmt.Errorf("line %d: toc x y size [font] [color] [opacity] [lp]", linenumber)
+	}
+	var fco string
+	if n > 4 {
+		fco = fontColorOpLp(s[4:])
 	}
+	fmt.Fprintf(w, "<toc xp=%q yp=%q sp=%q %s/>\n", s[1], s[2], s[3], fco)
 	return nil
 }
 
@@ -385,11 +518,14 @@ func include(w io.Writer, s []string, linenumber int) error {
 	if err != nil {
 		return err
 	}
-	r, err := os.Open(filearg)
+	r, err := assets.Open(filearg)
 	if err != nil {
 		return err
 	}
 	defer r.Close()
+	// the files named in the included file are found relative to it
+	defer func(a *deck.Resolver) { assets = a }(assets)
+	assets = deck.NewResolver(assets.Resolve(filearg))
 	return process(w, r)
 }
 
@@ -402,6 +538,9 @@ func loadata(s []string, linenumber int, scanner *bufio.Scanner) error {
 	if err != nil {
 		return err
 	}
+	if !filepath.IsAbs(filearg) {
+		filearg = filepath.Join(assets.Dir, filearg)
+	}
 	dataw, err := os.Create(filearg)
 	if err != nil {
 		return fmt.Errorf("line %d: %v (%v)", linenumber, s, err)
@@ -451,7 +590,7 @@ func grid(w io.Writer, s []string, linenumber int) error {
 	if err != nil {
 		return err
 	}
-	r, err := os.Open(filearg)
+	r, err := assets.Open(filearg)
 	if err != nil {
 		return err
 	}
@@ -540,8 +679,12 @@ func textcode(w io.Writer, s []string, linenumber int) error {
 		fmt.Fprintf(w, "<text type=\"code\" file=%s xp=%q yp=%q wp=%q sp=%q/>\n", s[1], s[2], s[3], s[4], s[5])
 	case 7:
 		fmt.Fprintf(w, "<text type=\"code\" file=%s xp=%q yp=%q wp=%q sp=%q color=%s/>\n", s[1], s[2], s[3], s[4], s[5], s[6])
+	case 8:
+		fmt.Fprintf(w, "<text type=\"code\" file=%s xp=%q yp=%q wp=%q sp=%q color=%s lang=%s/>\n", s[1], s[2], s[3], s[4], s[5], s[6], s[7])
+	case 9:
+		fmt.Fprintf(w, "<text type=\"code\" file=%s xp=%q yp=%q wp=%q sp=%q color=%s lang=%s scheme=%s/>\n", s[1], s[2], s[3], s[4], s[5], s[6], s[7], s[8])
 	default:
-		return fmt.Errorf("line %d: %s \"file\" x y width size [color]", linenumber, s[0])
+		return fmt.Errorf("line %d: %s \"file\" x y width size [color] [lang] [scheme]", linenumber, s[0])
 	}
 	return nil
 }
@@ -564,6 +707,29 @@ func image(w io.Writer, s []string, linenumber int) error {
 	return nil
 }
 
+// qrcode makes a QR code of data, with optional error correction level and colors
+func qrcode(w io.Writer, s []string, linenumber int) error {
+	n := len(s)
+	e := fmt.Errorf("line %d: %s \"data\" x y width [level] [color] [bg]", linenumber, s[0])
+	if n < 5 {
+		return e
+	}
+	qc := fmt.Sprintf("data=%s xp=%q yp=%q wp=%q", xmlesc(s[1]), s[2], s[3], s[4])
+	switch n {
+	case 5:
+		fmt.Fprintf(w, "<qrcode %s/>\n", qc)
+	case 6:
+		fmt.Fprintf(w, "<qrcode %s level=%s/>\n", qc, s[5])
+	case 7:
+		fmt.Fprintf(w, "<qrcode %s level=%s color=%s/>\n", qc, s[5], s[6])
+	case 8:
+		fmt.Fprintf(w, "<qrcode %s level=%s color=%s bg=%s/>\n", qc, s[5], s[6], s[7])
+	default:
+		return e
+	}
+	return nil
+}
+
 // cimage makes a captioned image
 func cimage(w io.Writer, s []string, linenumber int) error {
 	n := len(s)
@@ -596,6 +762,11 @@ func list(w io.Writer, s []string, linenumber int) error {
 		fco = fontColorOpLp(s[4:])
 	}
 
+	if len(bulletstyle) > 0 {
+		fco = strings.TrimSpace(fco + " " + bulletstyle)
+		bulletstyle = ""
+	}
+
 	switch s[0] {
 	case "list":
 		fmt.Fprintf(w, "<list xp=%q yp=%q sp=%q %s>\n", s[1], s[2], s[3], fco)
@@ -605,20 +776,59 @@ func list(w io.Writer, s []string, linenumber int) error {
 		fmt.Fprintf(w, "<list type=\"number\" xp=%q yp=%q sp=%q %s>\n", s[1], s[2], s[3], fco)
 	case "clist":
 		fmt.Fprintf(w, "<list align=\"center\" xp=%q yp=%q sp=%q %s>\n", s[1], s[2], s[3], fco)
+	case "tlist":
+		fmt.Fprintf(w, "<list type=\"check\" xp=%q yp=%q sp=%q %s>\n", s[1], s[2], s[3], fco)
+	}
+	return nil
+}
+
+// bullet sets the style of the bullets of the next list
+func bullet(w io.Writer, s []string, linenumber int) error {
+	n := len(s)
+	if n == 1 {
+		bulletstyle = ""
+		return nil
+	}
+	switch s[0] {
+	case "bullet":
+		if n > 5 {
+			return fmt.Errorf("line %d: %s \"glyph\" [font] [color] [size]", linenumber, s[0])
+		}
+		bulletstyle = fmt.Sprintf("bullets=\"%s\"", qesc(s[1]))
+		for i, attr := range []string{"bulletfont", "bulletcolor", "bulletsize"} {
+			if n > i+2 {
+				bulletstyle += fmt.Sprintf(" %s=\"%s\"", attr, strings.Trim(s[i+2], `"`))
+			}
+		}
+	case "bulletimage":
+		if n > 3 {
+			return fmt.Errorf("line %d: %s \"file\" [size]", linenumber, s[0])
+		}
+		bulletstyle = fmt.Sprintf("bulletimage=\"%s\"", qesc(s[1]))
+		if n > 2 {
+			bulletstyle += fmt.Sprintf(" bulletsize=%q", s[2])
+		}
 	}
 	return nil
 }
 
-// listitem generates list items
+// listitem generates list items; done and todo items are checked and unchecked boxes
 func listitem(w io.Writer, s []string, linenumber int) error {
 	ls := len(s)
+	tag := "li"
+	switch s[0] {
+	case "done":
+		tag = `li checked="true"`
+	case "todo":
+		tag = `li checked="false"`
+	}
 	switch {
 	case ls == 1:
-		fmt.Fprintln(w, "<li/>")
+		fmt.Fprintf(w, "<%s/>\n", tag)
 	case ls == 2:
-		fmt.Fprintf(w, "<li>%s</li>\n", qesc(s[1]))
+		fmt.Fprintf(w, "<%s>%s</li>\n", tag, qesc(s[1]))
 	case ls > 2:
-		fmt.Fprintf(w, "<li %s>%s</li>\n", fontColorOp(s[2:]), qesc(s[1]))
+		fmt.Fprintf(w, "<%s %s>%s</li>\n", tag, fontColorOp(s[2:]), qesc(s[1]))
 	}
 	return nil
 }
@@ -1199,6 +1409,11 @@ func chart(w io.Writer, s string, linenumber int) error {
 		}
 		cmd.Path = lp
 	}
+	// run in the directory of the input, where data files are written
+	if ap, err := filepath.Abs(cmd.Path); err == nil {
+		cmd.Path = ap
+	}
+	cmd.Dir = assets.Dir
 	out, err := cmd.Output()
 	if err != nil {
 		return fmt.Errorf("line: %d [%v] - %v", linenumber, s, err)
@@ -1261,10 +1476,11 @@ func forvector(s []string) ([]string, error) {
 func forfile(s []string) ([]string, error) {
 	var contents []string
 	fname := s[3][1 : len(s[3])-1] // remove quotes
-	r, err := os.Open(fname)
+	r, err := assets.Open(fname)
 	if err != nil {
 		return contents, err
 	}
+	defer r.Close()
 	fs := bufio.NewScanner(r)
 	for fs.Scan() {
 		contents = append(contents, fs.Text())
@@ -1377,17 +1593,35 @@ func keyparse(w io.Writer, tokens []string, t string, n int) error {
 	//fmt.Fprintf(os.Stderr, "%v\n", emap)
 	switch tokens[0] {
 	case "deck":
-		return deck(w, tokens, n)
+		return startdeck(w, tokens, n)
 
 	case "canvas":
 		return canvas(w, tokens, n)
 
+	case "fontfile":
+		return fontfile(w, tokens, n)
+
+	case "fallback":
+		return fallback(w, tokens, n)
+
 	case "include":
 		return include(w, tokens, n)
 
 	case "slide":
 		return slide(w, tokens, n)
 
+	case "header", "footer":
+		return overlay(w, tokens, n)
+
+	case "divider":
+		return slide(w, tokens, n)
+
+	case "section":
+		return section(w, tokens, n)
+
+	case "toc":
+		return toc(w, tokens, n)
+
 	case "grid":
 		return grid(w, tokens, n)
 
@@ -1409,13 +1643,19 @@ func keyparse(w io.Writer, tokens []string, t string, n int) error {
 	case "cimage":
 		return cimage(w, tokens, n)
 
-	case "list", "blist", "nlist", "clist":
+	case "qrcode":
+		return qrcode(w, tokens, n)
+
+	case "list", "blist", "nlist", "clist", "tlist":
 		return list(w, tokens, n)
 
-	case "elist", "eslide", "edeck":
+	case "bullet", "bulletimage":
+		return bullet(w, tokens, n)
+
+	case "elist", "eslide", "edeck", "eheader", "efooter", "esection", "edivider":
 		return endtag(w, tokens, n)
 
-	case "li":
+	case "li", "done", "todo":
 		return listitem(w, tokens, n)
 
 	case "ellipse", "rect":
@@ -1473,6 +1713,7 @@ func process(w io.Writer, r io.Reader) error {
 	scanner := bufio.NewScanner(r)
 	scanner.Buffer(make([]byte, maxbufsize), maxbufsize) // the default 64k buffer is too small
 	errors := []error{}
+	blocks := []block{} // open only and except blocks
 
 	// For every line in the input, parse into tokens,
 	// call the appropriate function, collecting errors as we go.
@@ -1489,8 +1730,27 @@ func process(w io.Writer, r io.Reader) error {
 		if tokens[0] == "data" {
 			errors = append(errors, loadata(tokens, n, scanner))
 		}
+		switch tokens[0] {
+		case "only", "except":
+			bw, err := beginblock(w, tokens, blocks, n)
+			blocks = append(blocks, block{kind: tokens[0], w: w})
+			w = bw
+			errors = append(errors, err)
+			continue
+		case "eonly", "eexcept":
+			if len(blocks) == 0 || blocks[len(blocks)-1].kind != tokens[0][1:] {
+				errors = append(errors, fmt.Errorf("line %d: %s without %s", n, tokens[0], tokens[0][1:]))
+				continue
+			}
+			w = blocks[len(blocks)-1].w
+			blocks = blocks[:len(blocks)-1]
+			continue
+		}
 		errors = append(errors, keyparse(w, tokens, t, n))
 	}
+	for _, b := range blocks {
+		errors = append(errors, fmt.Errorf("%s block without e%s", b.kind, b.kind))
+	}
 	// report any collected errors
 	nerrs := 0
 	for _, e := range errors {
@@ -1520,14 +1780,17 @@ func process(w io.Writer, r io.Reader) error {
 // $ decksh -o foo.xml foo.sh # input from foo.sh output to foo.xml
 func main() {
 	var dest = flag.String("o", "", "output destination")
+	var tags = flag.String("tags", "", "comma separated tags selecting a variant of the deck")
 	var input io.ReadCloser = os.Stdin
 	var output io.WriteCloser = os.Stdout
 	var rerr, werr error
 
 	flag.Parse()
+	variant = deck.Tags(*tags)
 	rand.Seed(time.Now().UnixNano())
 
 	if len(flag.Args()) > 0 {
+		assets = deck.NewResolver(flag.Args()[0])
 		input, rerr = os.Open(flag.Args()[0])
 		if rerr != nil {
 			fmt.Fprintf(os.Stderr, "%v\n", rerr)
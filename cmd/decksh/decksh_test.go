@@ -1,9 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/ajstarks/deck"
 )
 
 func TestMain(m *testing.M) {
@@ -24,3 +29,38 @@ func TestMain(m *testing.M) {
 	}
 	os.Exit(m.Run())
 }
+
+func TestInclude(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "parts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"main.dsh":        "deck\nslide\ninclude \"parts/outer.dsh\"\neslide\nedeck\n",
+		"parts/outer.dsh": "include \"inner.dsh\"\n",
+		"parts/inner.dsh": "text \"inner\" 50 50 2\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(a *deck.Resolver) { assets = a }(assets)
+	main := filepath.Join(dir, "main.dsh")
+	assets = deck.NewResolver(main)
+	r, err := os.Open(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var w bytes.Buffer
+	if err := process(&w, r); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(w.String(), ">inner</text>") {
+		t.Errorf("nested include not found relative to the including file:\n%s", w.String())
+	}
+	if assets.Dir != dir {
+		t.Errorf("resolver directory after include = %q, want %q", assets.Dir, dir)
+	}
+}
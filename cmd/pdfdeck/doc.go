@@ -25,6 +25,15 @@ Specifying w,h sets an arbitrary dimension, for example -pagesize 1600,900.
 
 the -pages option specifies the range (default all) of pages to be rendered.
 
+the -include-hidden option renders hidden slides, which are otherwise left out.
+
+the -tags option makes a variant of the deck, keeping the content selected by the comma separated tags.
+
+the -outline option adds a bookmark for each slide, named by its first item.
+
+Images and graphics with alt text (or a title) are written as /Figure marked content carrying the text.
+The PDF is not tagged: there is no structure tree, MarkInfo or /Lang, and the text itself is not marked.
+
 
 the -stdout option specified that output goes to the standard output file.
 */
@@ -9,8 +9,11 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"github.com/ajstarks/deck"
+	"github.com/golang/freetype/truetype"
 	"github.com/jung-kurt/gofpdf"
 )
 
@@ -20,6 +23,7 @@ const (
 	listspacing = 2.0
 	fontfactor  = 1.0
 	listwrap    = 95.0
+	pathsize    = 2.0 // size of text on a path
 )
 
 // PageDimen describes page dimensions
@@ -31,9 +35,27 @@ type PageDimen struct {
 // fontmap maps generic font names to specific implementation names
 var fontmap = map[string]string{}
 
+// tags select the variant of the decks
+var tags []string
+
+// includehidden renders hidden slides
+var includehidden bool
+
+// outlines adds a bookmark for each slide
+var outlines bool
+
 // transmap maps generic font names to the translation function
 var transmap = map[string]func(string) string{}
 
+// fallbacks maps font names to their fallback fonts, in order of preference
+var fallbacks = map[string][]string{}
+
+// coverage maps font implementation names to a test for the characters they contain
+var coverage = map[string]func(rune) bool{}
+
+// slidelinks maps slide numbers to the internal links to their pages
+var slidelinks = map[int]int{}
+
 // pagemap defines page dimensions
 var pagemap = map[string]PageDimen{
 	"Letter":     {792, 612, 1},
@@ -93,18 +115,67 @@ func setopacity(doc *gofpdf.Fpdf, v float64) {
 	}
 }
 
-// whitespace determines if a rune is whitespace
-func whitespace(r rune) bool {
-	return r == ' ' || r == '\n' || r == '\t'
-}
-
 // fontlookup maps font aliases to implementation font names
 func fontlookup(s string) string {
 	if font, ok := fontmap[s]; ok {
 		return font
 	} else {
-		return "sans"
+		return fontmap["sans"]
+	}
+}
+
+// translate applies the translation function of a font, using that of sans for unknown fonts
+func translate(font, s string) string {
+	if tf, ok := transmap[font]; ok {
+		return tf(s)
+	}
+	return transmap["sans"](s)
+}
+
+// outline adds a bookmark for slide n, named by the first of its items in reading order
+func outline(doc *gofpdf.Fpdf, d deck.Deck, n int) {
+	label := fmt.Sprintf("Slide %d", n+1)
+	if items := d.ReadingOrder(n); len(items) > 0 && items[0].Label() != "" {
+		label += ": " + items[0].Label()
 	}
+	doc.SetFont(fontlookup("sans"), "", 12)
+	doc.Bookmark(translate("sans", label), 0, 0)
+}
+
+// figure begins the marked content of an image or graphic with a text alternative (or title),
+// returning the function that ends it. gofpdf cannot write the structure tree of a tagged PDF,
+// so the alternate text is carried by the marked content itself.
+func figure(doc *gofpdf.Fpdf, alt, title string) func() {
+	if alt == "" {
+		alt = title
+	}
+	if alt == "" {
+		return func() {}
+	}
+	doc.RawWriteStr("/Figure <</Alt " + pdfstring(alt) + ">> BDC")
+	return func() { doc.RawWriteStr("EMC") }
+}
+
+// pdfstring returns text as a PDF hexadecimal string, in UTF-16
+func pdfstring(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// linkto links an area to a URL, or to the page of a slide ("#n")
+func linkto(doc *gofpdf.Fpdf, x, y, w, h float64, link string) {
+	if n, ok := deck.SlideLink(link); ok {
+		if id, ok := slidelinks[n]; ok {
+			doc.Link(x, y, w, h, id)
+		}
+		return
+	}
+	doc.LinkString(x, y, w, h, link)
 }
 
 // grid makes a percentage scale
@@ -119,25 +190,64 @@ func grid(doc *gofpdf.Fpdf, w, h float64, color string, percent float64) {
 	for x, pl := 0.0, 0.0; x <= w; x += pw {
 		doc.Line(x, 0, x, h)
 		if pl > 0 {
-			showtext(doc, x, h-fs, fmt.Sprintf("%.0f", pl), fs, "sans", "center", "")
+			showtext(doc, x, h-fs, fmt.Sprintf("%.0f", pl), fs, "sans", "center", "", "", deck.TextStyle{})
 		}
 		pl += percent
 	}
 	for y, pl := 0.0, 0.0; y <= h; y += ph {
 		doc.Line(0, y, w, y)
 		if pl < 100 {
-			showtext(doc, fs, y+(fs/3), fmt.Sprintf("%.0f", 100-pl), fs, "sans", "center", "")
+			showtext(doc, fs, y+(fs/3), fmt.Sprintf("%.0f", 100-pl), fs, "sans", "center", "", "", deck.TextStyle{})
 		}
 		pl += percent
 	}
 }
 
-// bullet draws a bullet
-func bullet(doc *gofpdf.Fpdf, x, y, size float64, color string) {
+// bullet draws the bullet of a list item: a disc, circle, square, dash, checkbox, image, or glyph
+func bullet(doc *gofpdf.Fpdf, tl deck.ListEntry, cx, cy, size float64, font, color string, assets *deck.Resolver) {
+	if len(tl.BulletColor) > 0 {
+		color = tl.BulletColor
+	}
+	if len(tl.BulletFont) > 0 {
+		font = tl.BulletFont
+	}
 	rs := size / 2
 	r, g, b := colorlookup(color)
 	doc.SetFillColor(r, g, b)
-	doc.Circle(x-size*2, y-rs, rs, "F")
+	doc.SetDrawColor(r, g, b)
+	doc.SetTextColor(r, g, b)
+	switch tl.Bullet {
+	case "disc":
+		doc.Circle(cx, cy, rs, "F")
+	case "circle":
+		doc.SetLineWidth(rs / 3)
+		doc.Circle(cx, cy, rs*0.85, "D")
+	case "square":
+		doc.Rect(cx-rs*0.8, cy-rs*0.8, rs*1.6, rs*1.6, "F")
+	case "dash":
+		doc.Rect(cx-rs, cy-rs/4, rs*2, rs/2, "F")
+	case "checkbox", "checked":
+		doc.SetLineWidth(rs / 5)
+		doc.Rect(cx-rs, cy-rs, rs*2, rs*2, "D")
+		if tl.Bullet == "checked" {
+			doc.SetLineWidth(rs / 3)
+			doc.Line(cx-rs*0.6, cy, cx-rs*0.1, cy+rs*0.5)
+			doc.Line(cx-rs*0.1, cy+rs*0.5, cx+rs*0.7, cy-rs*0.6)
+		}
+	case "image":
+		// the image is as high as the bullet, keeping its aspect ratio
+		var opt gofpdf.ImageOptions
+		name := imagename(doc, assets, tl.BulletImage, opt)
+		info := doc.RegisterImageOptions(name, opt)
+		if info == nil || info.Height() == 0 {
+			return
+		}
+		h := size * 1.5
+		w := h * info.Width() / info.Height()
+		doc.ImageOptions(name, cx-w/2, cy-h/2, w, h, false, opt, 0, "")
+	default: // a glyph
+		showtext(doc, cx, cy+rs, tl.Bullet, size*2, font, "center", "", "ltr", deck.TextStyle{})
+	}
 	//dorect(doc, x-size, y-rs, rs, rs, color)
 }
 
@@ -223,7 +333,7 @@ func dopoly(doc *gofpdf.Fpdf, xc, yc, color string, cw, ch float64) {
 }
 
 // dotext places text elements on the canvas according to type
-func dotext(doc *gofpdf.Fpdf, cw, x, y, fs, wp, rotation, spacing float64, tdata, font, color, align, ttype, tlink string) {
+func dotext(doc *gofpdf.Fpdf, cw, x, y, fs, wp, rotation, spacing float64, tdata, font, color, align, ttype, tlink, dir string, ts deck.TextStyle, spans []deck.Span, wrap wrapping) {
 	var tw float64
 	td := strings.Split(tdata, "\n")
 	if rotation > 0 {
@@ -240,11 +350,15 @@ func dotext(doc *gofpdf.Fpdf, cw, x, y, fs, wp, rotation, spacing float64, tdata
 	}
 	if ttype == "block" {
 		tw = deck.Pwidth(wp, cw, cw/2)
-		textwrap(doc, x, y, tw, fs, fs*spacing, transmap[font](tdata), font, tlink)
+		if len(spans) > 0 {
+			spanwrap(doc, x, y, tw, fs, fs*spacing, spans, font, tlink, ts, deck.Justified(align))
+		} else {
+			textwrap(doc, x, y, tw, fs, fs*spacing, tdata, font, tlink, dir, ts, wrap, deck.Justified(align))
+		}
 	} else {
 		ls := spacing * fs
 		for _, t := range td {
-			showtext(doc, x, y, t, fs, font, align, tlink)
+			showtext(doc, x, y, t, fs, font, align, tlink, dir, ts)
 			y += ls
 		}
 	}
@@ -253,41 +367,206 @@ func dotext(doc *gofpdf.Fpdf, cw, x, y, fs, wp, rotation, spacing float64, tdata
 	}
 }
 
-// showtext places fully attributed text at the specified location
-func showtext(doc *gofpdf.Fpdf, x, y float64, s string, fs float64, font, align, link string) {
-	offset := 0.0
+// docode draws code line by line on its background: highlighted by the syntax of its language, in the colors
+// of the scheme, with line numbers, and marked lines on a band, or others dimmed
+func docode(doc *gofpdf.Fpdf, cw, x, y, fs float64, tdata string, t deck.Text) {
+	lines := t.Code(codemap.Replace(tdata))
+	numbers := t.Numbers(lines)
+	colors := deck.Scheme(t.Scheme)
+	lang := deck.Lang(t.Lang)
+	if t.MarkColor == "" {
+		t.MarkColor = colors.Mark
+	}
+	if t.Rotation > 0 {
+		doc.TransformBegin()
+		doc.TransformRotate(t.Rotation, x, y)
+	}
+	alpha, blend := doc.GetAlpha()
+	ls := t.Lp * fs
+	tw := deck.Pwidth(t.Wp, cw, cw-x-20)
+	dorect(doc, x-fs, y-fs, tw, float64(len(lines))*ls, colors.Background)
+	cx := x
+	if len(numbers) > 0 {
+		cx += textwidth(doc, numbers[0]+" ", "mono", fs, 0)
+	}
+	for i, line := range lines {
+		if line.Band {
+			dorect(doc, x-fs, y-0.3*fs-ls/2, tw, ls, t.MarkColor)
+		}
+		doc.SetAlpha(alpha*line.Opacity(), blend)
+		if len(numbers) > 0 {
+			red, green, blue := colorlookup(colors.Color(deck.Comment))
+			doc.SetTextColor(red, green, blue)
+			drawtext(doc, x, y, numbers[i], "mono", fs, 0)
+		}
+		xp := cx
+		for _, tok := range line.Tokens {
+			color := t.Color
+			if lang != "" {
+				color = colors.Color(tok.Kind)
+			}
+			red, green, blue := colorlookup(color)
+			doc.SetTextColor(red, green, blue)
+			xp += drawtext(doc, xp, y, tok.Text, "mono", fs, 0)
+		}
+		doc.SetAlpha(alpha, blend)
+		y += ls
+	}
+	if t.Rotation > 0 {
+		doc.TransformEnd()
+	}
+}
+
+// textruns splits text into runs using the font or its fallbacks,
+// returning the font names and the runs
+func textruns(s, font string) ([]string, []deck.Run) {
+	chain := append([]string{font}, fallbacks[font]...)
+	covers := make([]func(rune) bool, len(chain))
+	for i, f := range chain {
+		covers[i] = coverage[fontlookup(f)]
+	}
+	return chain, deck.Runs(s, covers)
+}
+
+// textwidth returns the width of text, measured run by run, with tracking added between letters
+func textwidth(doc *gofpdf.Fpdf, s, font string, fs, tracking float64) float64 {
+	chain, runs := textruns(s, font)
+	tw := 0.0
+	for _, r := range runs {
+		f := chain[r.Font]
+		doc.SetFont(fontlookup(f), "", fs)
+		tw += doc.GetStringWidth(translate(f, r.Text))
+	}
+	if n := utf8.RuneCountInString(s); n > 1 {
+		tw += tracking * float64(n-1)
+	}
 	doc.SetFont(fontlookup(font), "", fs)
-	var t string
-	tf, ok := transmap[font]
-	if ok {
-		t = tf(s)
-	} else {
+	return tw
+}
+
+// drawtext draws text run by run, with tracking added between letters, returning its width
+func drawtext(doc *gofpdf.Fpdf, x, y float64, s, font string, fs, tracking float64) float64 {
+	chain, runs := textruns(s, font)
+	tw := 0.0
+	letters := 0
+	for _, r := range runs {
+		f := chain[r.Font]
+		doc.SetFont(fontlookup(f), "", fs)
+		if tracking == 0 {
+			t := translate(f, r.Text)
+			doc.Text(x+tw, y, t)
+			tw += doc.GetStringWidth(t)
+			continue
+		}
+		for _, c := range r.Text {
+			if letters > 0 {
+				tw += tracking
+			}
+			letters++
+			t := translate(f, string(c))
+			doc.Text(x+tw, y, t)
+			tw += doc.GetStringWidth(t)
+		}
+	}
+	doc.SetFont(fontlookup(font), "", fs)
+	return tw
+}
+
+// highlight draws the highlight behind text of width w at x, on the baseline y
+func highlight(doc *gofpdf.Fpdf, x, y, w, fs float64, ts deck.TextStyle) {
+	if !ts.Highlighted() || w == 0 {
+		return
+	}
+	top, h, m := ts.HighlightBox(y, fs)
+	r, g, b := colorlookup(ts.Highlight)
+	doc.SetFillColor(r, g, b)
+	doc.Rect(x-m, top, w+m*2, h, "F")
+}
+
+// decorate draws the lines under or through text of width w at x, on the baseline y, in the text color
+func decorate(doc *gofpdf.Fpdf, x, y, w, fs float64, ts deck.TextStyle) {
+	lines, thickness := ts.Lines(y, fs)
+	if len(lines) == 0 || w == 0 {
 		return
 	}
-	//t := transmap[font](s)
-	tw := doc.GetStringWidth(t)
+	doc.SetDrawColor(doc.GetTextColor())
+	doc.SetLineWidth(thickness)
+	for _, ly := range lines {
+		doc.Line(x, ly, x+w, ly)
+	}
+}
+
+// showtext places fully attributed text at the specified location
+func showtext(doc *gofpdf.Fpdf, x, y float64, s string, fs float64, font, align, link, dir string, ts deck.TextStyle) {
+	offset := 0.0
+	rtl := deck.RTL(s, dir)
+	s = deck.Visual(s, rtl)
+	align = deck.Align(align, rtl)
+	tracking := ts.Tracking(fs)
+	tw := textwidth(doc, s, font, fs, tracking)
 	switch align {
 	case "center", "middle", "mid", "c":
 		offset = (tw / 2)
 	case "right", "end", "e":
 		offset = tw
 	}
-	doc.Text(x-offset, y, t)
+	highlight(doc, x-offset, y, tw, fs, ts)
+	drawtext(doc, x-offset, y, s, font, fs, tracking)
+	decorate(doc, x-offset, y, tw, fs, ts)
 	if len(link) > 0 {
-		doc.LinkString(x-offset, y-fs, tw, fs, link)
+		linkto(doc, x-offset, y-fs, tw, fs, link)
+	}
+}
+
+// doqrcode draws a QR code centered at x, y, w wide with its quiet zone, a rectangle for each run of dark modules
+func doqrcode(doc *gofpdf.Fpdf, x, y, w float64, q deck.QRCode) error {
+	modules, err := q.Modules()
+	if err != nil {
+		return err
+	}
+	m := w / float64(len(modules)+2*deck.QuietZone)
+	left, top := x-w/2+m*deck.QuietZone, y-w/2+m*deck.QuietZone
+	dorect(doc, x-w/2, y-w/2, w, w, q.Bg)
+	for _, r := range deck.QRRuns(modules) {
+		dorect(doc, left+m*float64(r.Col), top+m*float64(r.Row), m*float64(r.Len), m, q.Color)
+	}
+	return nil
+}
+
+// dotextpath draws text along its path, turning each character along the way
+func dotextpath(doc *gofpdf.Fpdf, cw, ch, fs float64, t deck.TextPath) {
+	red, green, blue := colorlookup(t.Color)
+	doc.SetTextColor(red, green, blue)
+	tracking := t.Tracking(fs)
+	measure := func(s string) float64 { return textwidth(doc, s, t.Font, fs, tracking) }
+	for _, g := range t.Glyphs(cw, ch, measure) {
+		doc.TransformBegin()
+		doc.TransformRotate(g.Angle, g.X, g.Y)
+		drawtext(doc, g.X, g.Y, g.Text, t.Font, fs, tracking)
+		doc.TransformEnd()
 	}
 }
 
 // dolists places lists on the canvas
-// dolist(doc, cw, x, y, fs, l.Lp, l.Wp, l.Li, l.Font, l.Color, l.Type)
-func dolist(doc *gofpdf.Fpdf, cw, x, y, fs, lwidth, rotation, spacing float64, list []deck.ListItem, font, color, align, ltype string) {
+// dolist(doc, cw, x, y, fs, l.Lp, l.Wp, l.Entries(), l.Font, l.Color, l.Type, l.Dir, d.Assets, l.Wrapping())
+func dolist(doc *gofpdf.Fpdf, cw, x, y, fs, lwidth, rotation, spacing float64, list []deck.ListEntry, font, color, align, ltype, dir string, assets *deck.Resolver, wrap wrapping) {
 	if font == "" {
 		font = "sans"
 	}
 	red, green, blue := colorlookup(color)
 
-	if ltype == "bullet" {
-		x += fs * 1.2
+	// right to left lists start at the right, with bullets to the right of the text
+	rtl := len(list) > 0 && deck.RTL(list[0].ListText, dir)
+	dir = "ltr"
+	if rtl {
+		dir = "rtl"
+	}
+	if ltype == "bullet" || ltype == "check" {
+		if rtl {
+			x -= fs * 1.2
+		} else {
+			x += fs * 1.2
+		}
 	}
 	ls := spacing * fs
 	tw := deck.Pwidth(lwidth, cw, cw/2)
@@ -300,38 +579,54 @@ func dolist(doc *gofpdf.Fpdf, cw, x, y, fs, lwidth, rotation, spacing float64, l
 		doc.TransformRotate(rotation, x, y)
 	}
 	defont := font
-	for i, tl := range list {
-		doc.SetFont(fontlookup(font), "", fs)
+	for _, tl := range list {
+		// nested levels are indented, and sized relative to the list
+		ifs, ix := fs*tl.Scale, x+fs*tl.Indent
+		if rtl {
+			ix = x - fs*tl.Indent
+		}
+		ils := ls * tl.Scale
+		// bullets are centered where the text's bullet would be, sized on their own
+		if len(tl.Bullet) > 0 && rtl {
+			bullet(doc, tl, ix+ifs, y-ifs/4, fs*tl.BulletScale/2, defont, color, assets)
+		} else if len(tl.Bullet) > 0 {
+			bullet(doc, tl, ix-ifs, y-ifs/4, fs*tl.BulletScale/2, defont, color, assets)
+		}
+		doc.SetFont(fontlookup(font), "", ifs)
 		doc.SetTextColor(red, green, blue)
-		if ltype == "number" {
-			t = fmt.Sprintf("%d. ", i+1) + tl.ListText
+		if len(tl.Number) > 0 {
+			t = tl.Number + " " + tl.ListText
 		} else {
 			t = tl.ListText
 		}
-		if ltype == "bullet" {
-			bullet(doc, x, y, fs/2, color)
-		}
 		if len(tl.Color) > 0 {
 			tlred, tlgreen, tlblue := colorlookup(tl.Color)
 			doc.SetTextColor(tlred, tlgreen, tlblue)
 		}
 		if len(tl.Font) > 0 {
-			doc.SetFont(fontlookup(tl.Font), "", fs)
+			doc.SetFont(fontlookup(tl.Font), "", ifs)
 			font = tl.Font
 		} else {
 			font = defont
 		}
 		//doc.Text(x, y, translate(t))
 		if align == "center" || align == "c" {
-			showtext(doc, x, y, transmap[font](t), fs, font, align, "")
-			y += ls
+			showtext(doc, ix, y, t, ifs, font, align, tl.Link, dir, tl.TextStyle)
+			y += ils
+		} else if len(tl.Spans) > 0 {
+			spans := tl.Spans
+			if len(tl.Number) > 0 {
+				spans = append([]deck.Span{{Text: tl.Number + " "}}, spans...)
+			}
+			yw = spanwrap(doc, ix, y, tw-fs*tl.Indent, ifs, ils, spans, font, tl.Link, tl.TextStyle, false)
+			y += ils * float64(yw+1)
 		} else {
 
-			yw = textwrap(doc, x, y, tw, fs, ls, transmap[font](t), font, "")
+			yw = textwrap(doc, ix, y, tw-fs*tl.Indent, ifs, ils, t, font, tl.Link, dir, tl.TextStyle, wrap, false)
 
-			y += ls
+			y += ils
 			if yw >= 1 {
-				y += ls * float64(yw)
+				y += ils * float64(yw)
 			}
 		}
 	}
@@ -340,35 +635,229 @@ func dolist(doc *gofpdf.Fpdf, cw, x, y, fs, lwidth, rotation, spacing float64, l
 	}
 }
 
-// textwrap draws text at location, wrapping at the specified width
-func textwrap(doc *gofpdf.Fpdf, x, y, w, fs, leading float64, s, font, link string) int {
-	var factor = 0.3
-	if font == "mono" {
-		factor = 1.0
+// textwrap draws text at location, broken into lines for the width by the wrapping function.
+// Lines of justified text, but the last, are stretched to the width.
+// Right to left text is set against the location, extending to the left.
+func textwrap(doc *gofpdf.Fpdf, x, y, w, fs, leading float64, s, font, link, dir string, ts deck.TextStyle, wrap wrapping, justify bool) int {
+	tracking := ts.Tracking(fs)
+	wordspacing, measure := measures(doc, font, fs, ts)
+	lines := wrap(s, w, wordspacing, measure)
+	rtl := deck.RTL(s, dir)
+	yp := y
+	for n, line := range lines {
+		words := strings.Fields(deck.Visual(line, rtl))
+		// the width of the line places right to left text, and its highlight and decoration
+		lw := 0.0
+		for i, word := range words {
+			if i > 0 {
+				lw += wordspacing
+			}
+			lw += measure(word)
+		}
+		spacing := wordspacing
+		if justify && n < len(lines)-1 && len(words) > 1 {
+			spacing += (w - lw) / float64(len(words)-1)
+			lw = w
+		}
+		xp := x
+		if rtl {
+			xp -= lw
+		}
+		lx := xp
+		highlight(doc, lx, yp, lw, fs, ts)
+		for _, word := range words {
+			xp += drawtext(doc, xp, yp, word, font, fs, tracking) + spacing
+		}
+		decorate(doc, lx, yp, lw, fs, ts)
+		yp += leading
 	}
-	nbreak := 0
-	doc.SetFont(fontlookup(font), "", fs)
-	wordspacing := doc.GetStringWidth("M")
-	words := strings.FieldsFunc(s, whitespace)
-	xp := x
+	nbreak := len(lines) - 1
+	if len(link) > 0 {
+		lx := x
+		if rtl {
+			lx = x - w
+		}
+		linkto(doc, lx, y-fs, w, float64(nbreak)*leading+fs, link)
+	}
+	return nbreak
+}
+
+// spanwrap draws styled text at location, broken into lines of words that fit the width.
+// Lines of justified text, but the last, are stretched to the width.
+func spanwrap(doc *gofpdf.Fpdf, x, y, w, fs, leading float64, spans []deck.Span, font, link string, ts deck.TextStyle, justify bool) int {
+	tracking := ts.Tracking(fs)
+	wordspacing, _ := measures(doc, font, fs, ts)
+	measure := func(word deck.Word) float64 {
+		ww := 0.0
+		for _, s := range word {
+			ww += spanwidth(doc, s, font, fs, tracking)
+		}
+		return ww
+	}
+	lines := deck.WrapWords(deck.Words(spans), w, wordspacing, measure)
 	yp := y
-	edge := x + w
-	for _, s := range words {
-		tw := doc.GetStringWidth(s)
-		doc.Text(xp, yp, s)
-		xp += tw + (wordspacing * factor)
-		if xp > edge {
-			xp = x
-			yp += leading
-			nbreak++
+	for n, line := range lines {
+		lw := 0.0
+		for i, word := range line {
+			if i > 0 {
+				lw += wordspacing
+			}
+			lw += measure(word)
+		}
+		spacing := wordspacing
+		if justify && n < len(lines)-1 && len(line) > 1 {
+			spacing += (w - lw) / float64(len(line)-1)
+			lw = w
 		}
+		highlight(doc, x, yp, lw, fs, ts)
+		xp := x
+		for _, word := range line {
+			for _, s := range word {
+				xp += drawspan(doc, xp, yp, s, font, fs, tracking)
+			}
+			xp += spacing
+		}
+		decorate(doc, x, yp, lw, fs, ts)
+		yp += leading
 	}
+	nbreak := len(lines) - 1
 	if len(link) > 0 {
-		doc.LinkString(x, y-fs, edge, (yp-y)+fs, link)
+		linkto(doc, x, y-fs, w, float64(nbreak)*leading+fs, link)
 	}
 	return nbreak
 }
 
+// hasfont reports whether a font is known by name
+func hasfont(name string) bool {
+	_, ok := fontmap[name]
+	return ok
+}
+
+// spanwidth returns the width of a span of styled text; text made bold is drawn twice, a little apart
+func spanwidth(doc *gofpdf.Fpdf, s deck.Span, font string, fs, tracking float64) float64 {
+	face, bold, _ := s.Face(font, hasfont)
+	tw := textwidth(doc, s.Text, face, fs, tracking)
+	if bold {
+		tw += fs / 30
+	}
+	return tw
+}
+
+// drawspan draws a span of styled text, returning its width: code on a light background,
+// links underlined, and text slanted or made bold if the deck has no italic or bold font for it
+func drawspan(doc *gofpdf.Fpdf, x, y float64, s deck.Span, font string, fs, tracking float64) float64 {
+	face, bold, italic := s.Face(font, hasfont)
+	tw := spanwidth(doc, s, font, fs, tracking)
+	if s.Code {
+		highlight(doc, x, y, tw, fs, deck.TextStyle{Highlight: "rgb(240,240,240)"})
+	}
+	if italic {
+		doc.TransformBegin()
+		doc.TransformSkewX(12, x, y)
+	}
+	drawtext(doc, x, y, s.Text, face, fs, tracking)
+	if bold {
+		drawtext(doc, x+fs/30, y, s.Text, face, fs, tracking)
+	}
+	if italic {
+		doc.TransformEnd()
+	}
+	doc.SetFont(fontlookup(font), "", fs)
+	if len(s.Link) > 0 {
+		decorate(doc, x, y, tw, fs, deck.TextStyle{Decoration: "underline"})
+		linkto(doc, x, y-fs, tw, fs, s.Link)
+	}
+	return tw
+}
+
+// wrapping breaks text into lines for a width, given the space between words and the measure of words
+type wrapping func(s string, width, space float64, measure func(string) float64) []string
+
+// measures returns the space between words of text, and the measure of its words
+func measures(doc *gofpdf.Fpdf, font string, fs float64, ts deck.TextStyle) (float64, func(string) float64) {
+	var factor = 0.3
+	if font == "mono" {
+		factor = 1.0
+	}
+	doc.SetFont(fontlookup(font), "", fs)
+	wordspacing := doc.GetStringWidth("M") * factor
+	tracking := ts.Tracking(fs)
+	measure := func(s string) float64 { return textwidth(doc, s, font, fs, tracking) }
+	return wordspacing, measure
+}
+
+// textbox draws a text box at x, y (its top left corner): the box, filled, bordered, and rounded,
+// and its text, wrapped within the padding and aligned to the top, middle, or bottom.
+// Fitted text is sized to fit the box; textbox reports whether it does.
+func textbox(doc *gofpdf.Fpdf, cw, ch, x, y, fs float64, tdata string, t deck.Text, wrap wrapping) bool {
+	if t.Rotation > 0 {
+		doc.TransformBegin()
+		doc.TransformRotate(t.Rotation, x, y)
+	}
+	w, h, pad := deck.Pwidth(t.Wp, cw, cw/2), pct(t.Hp, ch), pct(t.Padding, cw)
+	_, _, smallest := dimen(cw, ch, 0, 0, t.Smallest())
+	largest := h
+	if h <= 0 {
+		largest = w
+	}
+	fs, fits := t.Size(fs, smallest, largest, func(fs float64) bool {
+		wordspacing, measure := measures(doc, t.Font, fs, t.TextStyle)
+		lines := wrap(tdata, w-pad*2, wordspacing, measure)
+		return t.Fits(lines, w, h, pad, fs, fs*t.Lp, wordspacing, measure)
+	})
+	leading := fs * t.Lp
+	wordspacing, measure := measures(doc, t.Font, fs, t.TextStyle)
+	lines := wrap(tdata, w-pad*2, wordspacing, measure)
+	h, ty := t.Box(y, h, pad, lines, fs, leading)
+	style := ""
+	if len(t.Fill) > 0 {
+		r, g, b := colorlookup(t.Fill)
+		doc.SetFillColor(r, g, b)
+		style += "F"
+	}
+	if len(t.Border) > 0 {
+		r, g, b := colorlookup(t.Border)
+		doc.SetDrawColor(r, g, b)
+		doc.SetLineWidth(deck.Pwidth(t.BorderWidth, cw, pct(0.2, cw)))
+		style += "D"
+	}
+	if len(style) > 0 {
+		doc.RoundedRect(x, y, w, h, pct(t.Radius, cw), "1234", style)
+	}
+	// right to left text is set against the right of the box
+	tx := x + pad
+	if deck.RTL(tdata, t.Dir) {
+		tx = x + w - pad
+	}
+	red, green, blue := colorlookup(t.Color)
+	doc.SetTextColor(red, green, blue)
+	textwrap(doc, tx, ty, w-pad*2, fs, leading, tdata, t.Font, t.Link, t.Dir, t.TextStyle, wrap, deck.Justified(t.Align))
+	if t.Rotation > 0 {
+		doc.TransformEnd()
+	}
+	return fits
+}
+
+// fitlist returns the size of the text of a list fitted to its box, and whether the list fits
+func fitlist(doc *gofpdf.Fpdf, cw, ch, fs float64, l deck.List, entries []deck.ListEntry) (float64, bool) {
+	w, h := deck.Pwidth(l.Wp, cw, cw/2), pct(l.Hp, ch)
+	_, _, smallest := dimen(cw, ch, 0, 0, l.Smallest())
+	largest := h
+	if h <= 0 {
+		largest = w
+	}
+	return l.Size(fs, smallest, largest, func(fs float64) bool {
+		return l.Fits(entries, w, h, fs, func(font string, fs float64, ts deck.TextStyle) (float64, func(string) float64) {
+			return measures(doc, font, fs, ts)
+		})
+	})
+}
+
+// overflow warns of text that does not fit its box, even at the smallest size
+func overflow(n int, s string) {
+	fmt.Fprintf(os.Stderr, "pdfdeck: slide %d: %.40q does not fit its box at the smallest size\n", n+1, strings.TrimSpace(s))
+}
+
 // pdfslide makes a slide, one slide per PDF page
 func pdfslide(doc *gofpdf.Fpdf, d deck.Deck, n int, gp float64, showslide bool) {
 	if n < 0 || n > len(d.Slide)-1 || !showslide {
@@ -379,10 +868,26 @@ func pdfslide(doc *gofpdf.Fpdf, d deck.Deck, n int, gp float64, showslide bool)
 	var imgopt gofpdf.ImageOptions
 	imgopt.AllowNegativePosition = true
 
-	doc.AddPage()
+	// slides with their own canvas have pages of their own size
+	if c := d.SlideCanvas(n); c != d.Canvas {
+		d.Canvas = c
+		doc.AddPageFormat("P", gofpdf.SizeType{Wd: float64(c.Width), Ht: float64(c.Height)})
+	} else {
+		doc.AddPage()
+	}
 	cw := float64(d.Canvas.Width)
 	ch := float64(d.Canvas.Height)
 	slide := d.Slide[n]
+	// the blocks of markdown text are placed by the lines they wrap into, in their fonts
+	deck.Flow(slide.Text, slide.List, cw, ch, func(font string, fs float64, ts deck.TextStyle) (float64, func(string) float64) {
+		return measures(doc, font, fs, ts)
+	})
+	if id, ok := slidelinks[n+1]; ok {
+		doc.SetLink(id, 0, -1)
+	}
+	if outlines {
+		outline(doc, d, n)
+	}
 	// set default background
 	if slide.Bg == "" {
 		slide.Bg = "white"
@@ -416,7 +921,9 @@ func pdfslide(doc *gofpdf.Fpdf, d deck.Deck, n int, gp float64, showslide bool)
 		}
 		midx := fw / 2
 		midy := fh / 2
-		doc.ImageOptions(im.Name, x-midx, y-midy, fw, fh, false, imgopt, 0, im.Link)
+		end := figure(doc, im.Alt, im.Title)
+		doc.ImageOptions(imagename(doc, d.Assets, im.Name, imgopt), x-midx, y-midy, fw, fh, false, imgopt, 0, im.Link)
+		end()
 		if len(im.Caption) > 0 {
 			capsize := deck.Pwidth(im.Sp, cw, pct(2, cw))
 			if im.Font == "" {
@@ -436,7 +943,7 @@ func pdfslide(doc *gofpdf.Fpdf, d deck.Deck, n int, gp float64, showslide bool)
 			}
 			capr, capg, capb := colorlookup(im.Color)
 			doc.SetTextColor(capr, capg, capb)
-			showtext(doc, x, y+(midy)+(capsize*1.5), im.Caption, capsize, im.Font, im.Align, "")
+			showtext(doc, x, y+(midy)+(capsize*1.5), im.Caption, capsize, im.Font, im.Align, "", im.Dir, im.TextStyle)
 		}
 	}
 	// every graphic on the slide
@@ -455,7 +962,9 @@ func pdfslide(doc *gofpdf.Fpdf, d deck.Deck, n int, gp float64, showslide bool)
 			rect.Color = defaultColor
 		}
 		setopacity(doc, rect.Opacity)
+		end := figure(doc, rect.Alt, rect.Title)
 		dorect(doc, x-(w/2), y-(h/2), w, h, rect.Color)
+		end()
 	}
 	// ellipse
 	for _, ellipse := range slide.Ellipse {
@@ -471,7 +980,9 @@ func pdfslide(doc *gofpdf.Fpdf, d deck.Deck, n int, gp float64, showslide bool)
 			ellipse.Color = defaultColor
 		}
 		setopacity(doc, ellipse.Opacity)
+		end := figure(doc, ellipse.Alt, ellipse.Title)
 		doellipse(doc, x, y, w/2, h/2, ellipse.Color)
+		end()
 	}
 	// curve
 	for _, curve := range slide.Curve {
@@ -485,7 +996,9 @@ func pdfslide(doc *gofpdf.Fpdf, d deck.Deck, n int, gp float64, showslide bool)
 		if sw == 0 {
 			sw = 2.0
 		}
+		end := figure(doc, curve.Alt, curve.Title)
 		docurve(doc, x1, y1, x2, y2, x3, y3, sw, curve.Color)
+		end()
 	}
 	// arc
 	for _, arc := range slide.Arc {
@@ -499,7 +1012,9 @@ func pdfslide(doc *gofpdf.Fpdf, d deck.Deck, n int, gp float64, showslide bool)
 		if sw == 0 {
 			sw = 2.0
 		}
+		end := figure(doc, arc.Alt, arc.Title)
 		doarc(doc, x, y, w/2, h/2, arc.A1, arc.A2, sw, arc.Color)
+		end()
 	}
 	// line
 	for _, line := range slide.Line {
@@ -512,7 +1027,9 @@ func pdfslide(doc *gofpdf.Fpdf, d deck.Deck, n int, gp float64, showslide bool)
 		if sw == 0 {
 			sw = 2.0
 		}
+		end := figure(doc, line.Alt, line.Title)
 		doline(doc, x1, y1, x2, y2, sw, line.Color)
+		end()
 	}
 	// polygon
 	for _, poly := range slide.Polygon {
@@ -520,7 +1037,23 @@ func pdfslide(doc *gofpdf.Fpdf, d deck.Deck, n int, gp float64, showslide bool)
 			poly.Color = defaultColor
 		}
 		setopacity(doc, poly.Opacity)
+		end := figure(doc, poly.Alt, poly.Title)
 		dopoly(doc, poly.XC, poly.YC, poly.Color, cw, ch)
+		end()
+	}
+	// QR codes
+	for _, q := range slide.QRCode {
+		if q.Color == "" {
+			q.Color = "black"
+		}
+		if q.Bg == "" {
+			q.Bg = "white"
+		}
+		setopacity(doc, q.Opacity)
+		x, y, _ := dimen(cw, ch, q.Xp, q.Yp, 0)
+		if err := doqrcode(doc, x, y, pct(q.Wp, cw), q); err != nil {
+			fmt.Fprintf(os.Stderr, "pdfdeck: slide %d: %v\n", n+1, err)
+		}
 	}
 
 	// for every text element...
@@ -535,14 +1068,40 @@ func pdfslide(doc *gofpdf.Fpdf, d deck.Deck, n int, gp float64, showslide bool)
 		setopacity(doc, t.Opacity)
 		x, y, fs = dimen(cw, ch, t.Xp, t.Yp, t.Sp)
 		if t.File != "" {
-			tdata = includefile(t.File)
+			tdata = includefile(d.Assets, t.File)
 		} else {
 			tdata = t.Tdata
 		}
 		if t.Lp == 0 {
 			t.Lp = linespacing
 		}
-		dotext(doc, cw, x, y, fs, t.Wp, t.Rotation, t.Lp, tdata, t.Font, t.Color, t.Align, t.Type, t.Link)
+		wrap := t.Wrapper(d.Assets.Hyphenator(t.Hyphenate))
+		if t.IsBox() {
+			if !textbox(doc, cw, ch, x, y, fs, tdata, t, wrap) {
+				overflow(n, tdata)
+			}
+			continue
+		}
+		if t.IsListing() {
+			docode(doc, cw, x, y, fs, tdata, t)
+			continue
+		}
+		dotext(doc, cw, x, y, fs, t.Wp, t.Rotation, t.Lp, tdata, t.Font, t.Color, t.Align, t.Type, t.Link, t.Dir, t.TextStyle, t.Spans, wrap)
+	}
+	// text on paths
+	for _, t := range slide.TextPath {
+		if t.Color == "" {
+			t.Color = slide.Fg
+		}
+		if t.Font == "" {
+			t.Font = "sans"
+		}
+		if t.Sp == 0 {
+			t.Sp = pathsize
+		}
+		setopacity(doc, t.Opacity)
+		_, _, fs = dimen(cw, ch, 0, 0, t.Sp)
+		dotextpath(doc, cw, ch, fs, t)
 	}
 	// for every list element...
 	for _, l := range slide.List {
@@ -555,9 +1114,19 @@ func pdfslide(doc *gofpdf.Fpdf, d deck.Deck, n int, gp float64, showslide bool)
 		if l.Wp == 0 {
 			l.Wp = listwrap
 		}
+		if l.Font == "" {
+			l.Font = "sans"
+		}
 		setopacity(doc, l.Opacity)
 		x, y, fs = dimen(cw, ch, l.Xp, l.Yp, l.Sp)
-		dolist(doc, cw, x, y, fs, l.Wp, l.Rotation, l.Lp, l.Li, l.Font, l.Color, l.Align, l.Type)
+		entries := l.Entries()
+		if len(l.Fit) > 0 {
+			var fits bool
+			if fs, fits = fitlist(doc, cw, ch, fs, l, entries); !fits {
+				overflow(n, l.Li[0].ListText)
+			}
+		}
+		dolist(doc, cw, x, y, fs, l.Wp, l.Rotation, l.Lp, entries, l.Font, l.Color, l.Align, l.Type, l.Dir, d.Assets, l.Wrapping())
 	}
 	// add a grid, if specified
 	if gp > 0 {
@@ -565,6 +1134,73 @@ func pdfslide(doc *gofpdf.Fpdf, d deck.Deck, n int, gp float64, showslide bool)
 	}
 }
 
+// imagename returns the name used to place an image in the document.
+// Images carried in a bundle are registered from the bundle contents.
+func imagename(doc *gofpdf.Fpdf, assets *deck.Resolver, name string, opt gofpdf.ImageOptions) string {
+	if assets == nil || assets.FS == nil {
+		return assets.Resolve(name)
+	}
+	key := assets.Dir + ":" + name
+	if doc.GetImageInfo(key) != nil {
+		return key
+	}
+	r, err := assets.Open(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pdfdeck: %v\n", err)
+		return key
+	}
+	defer r.Close()
+	if opt.ImageType == "" {
+		opt.ImageType = strings.TrimPrefix(filepath.Ext(name), ".")
+	}
+	doc.RegisterImageOptionsReader(key, opt, r)
+	return key
+}
+
+// declarefonts loads the fonts declared by a deck, returning the font and translation maps
+// for the deck, which add the declared fonts to the command-line mappings
+func declarefonts(doc *gofpdf.Fpdf, d deck.Deck) (map[string]string, map[string]func(string) string) {
+	fm := map[string]string{}
+	tm := map[string]func(string) string{}
+	for k, v := range fontmap {
+		fm[k] = v
+	}
+	for k, v := range transmap {
+		tm[k] = v
+	}
+	for _, f := range d.Font {
+		if f.File == "" {
+			continue
+		}
+		data, err := d.Assets.ReadFile(f.File)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "pdfdeck: font %s: %v\n", f.Name, err)
+			continue
+		}
+		family := filepath.Join(d.Assets.Dir, f.File)
+		doc.AddUTF8FontFromBytes(family, "", data)
+		setcoverage(family, data)
+		fm[f.Name] = family
+		tm[f.Name] = nulltrans
+	}
+	return fm, tm
+}
+
+// setcoverage records the characters contained in a TrueType font
+func setcoverage(family string, data []byte) {
+	f, err := truetype.Parse(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pdfdeck: %s: %v\n", family, err)
+		return
+	}
+	coverage[family] = func(r rune) bool { return f.Index(r) != 0 }
+}
+
+// latin1 tests for the characters of the core (non-Unicode) fonts
+func latin1(r rune) bool {
+	return r < 256
+}
+
 // nulltrans is the null translation function
 func nulltrans(s string) string {
 	return s
@@ -577,22 +1213,46 @@ func doslides(doc *gofpdf.Fpdf, pc gofpdf.InitType, filename, author, title stri
 
 	w := int(pc.Size.Wd)
 	h := int(pc.Size.Ht)
+	d, err = deck.Read(filename, w, h, tags...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pdfdeck: %v\n", err)
+		return
+	}
+	// warn of the assets that could not be found or read, once the slides are made
+	defer func() {
+		for _, err := range d.Assets.Errors() {
+			fmt.Fprintf(os.Stderr, "pdfdeck: %v\n", err)
+		}
+	}()
 	for k, v := range fontmap {
+		// fonts carried in a bundle take precedence over the font directory
+		if data, ok := d.Assets.Font(v + ".ttf"); ok {
+			doc.AddUTF8FontFromBytes(v, "", data)
+			setcoverage(v, data)
+			transmap[k] = nulltrans
+			continue
+		}
 		fontfile := filepath.Join(pc.FontDirStr, v)
 		_, err := os.Stat(fontfile + ".json")
 		if err != nil {
 			doc.AddUTF8Font(v, "", v+".ttf")
+			if data, err := ioutil.ReadFile(fontfile + ".ttf"); err == nil {
+				setcoverage(v, data)
+			}
 			transmap[k] = nulltrans
 		} else {
 			doc.AddFont(v, "", v+".json")
+			coverage[v] = latin1
 			transmap[k] = doc.UnicodeTranslatorFromDescriptor("")
 		}
 	}
-	d, err = deck.Read(filename, w, h)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "pdfdeck: %v\n", err)
-		return
-	}
+	fallbacks = d.Fallbacks()
+	// the fonts declared by the deck apply only to this deck
+	defer func(fm map[string]string, tm map[string]func(string) string) {
+		fontmap, transmap = fm, tm
+	}(fontmap, transmap)
+	fontmap, transmap = declarefonts(doc, d)
+
 	if pc.OrientationStr == "L" {
 		w, h = h, w
 	}
@@ -621,8 +1281,25 @@ func doslides(doc *gofpdf.Fpdf, pc gofpdf.InitType, filename, author, title stri
 	if len(d.Subject) > 0 {
 		doc.SetSubject(d.Subject, true)
 	}
+
+	if kw := d.KeywordList(); len(kw) > 0 {
+		doc.SetKeywords(strings.Join(kw, ", "), true)
+	}
+	// the remaining metadata is carried in XMP
+	d.Title, d.Creator = title, author
+	doc.SetXmpMetadata(d.XMP())
+	// links to slides not in the page range, or hidden, are left out
+	shown := func(i int) bool {
+		return i+1 >= begin && i+1 <= end && (includehidden || !d.Slide[i].IsHidden())
+	}
+	slidelinks = map[int]int{}
+	for i := 0; i < len(d.Slide); i++ {
+		if shown(i) {
+			slidelinks[i+1] = doc.AddLink()
+		}
+	}
 	for i := 0; i < len(d.Slide); i++ {
-		pdfslide(doc, d, i, gp, (i+1 >= begin && i+1 <= end))
+		pdfslide(doc, d, i, gp, shown(i))
 	}
 }
 
@@ -642,8 +1319,8 @@ func dodeck(files []string, pageconfig gofpdf.InitType, w, h float64, sflag bool
 		}
 	} else { // output to individual files
 		for _, filename := range files {
-			base := strings.Split(filepath.Base(filename), ".xml")
-			out, err := os.Create(filepath.Join(outdir, base[0]+".pdf"))
+			base := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(filename), deck.BundleExt), ".xml")
+			out, err := os.Create(filepath.Join(outdir, base+".pdf"))
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "pdfdeck: %v\n", err)
 				continue
@@ -661,8 +1338,8 @@ func dodeck(files []string, pageconfig gofpdf.InitType, w, h float64, sflag bool
 }
 
 // includefile returns the contents of a file as string
-func includefile(filename string) string {
-	data, err := ioutil.ReadFile(filename)
+func includefile(assets *deck.Resolver, filename string) string {
+	data, err := assets.ReadFile(filename)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		return ""
@@ -685,6 +1362,9 @@ func main() {
 		gridpct    = flag.Float64("grid", 0, "draw a percentage grid on each slide")
 		stdout     = flag.Bool("stdout", false, "output to standard output")
 		pr         = flag.String("pages", "1-1000000", "page range (first-last)")
+		variant    = flag.String("tags", "", "comma separated tags selecting a variant of the deck")
+		hidden     = flag.Bool("include-hidden", false, "render hidden slides")
+		bookmarks  = flag.Bool("outline", false, "add a bookmark for each slide")
 	)
 	flag.Parse()
 
@@ -709,6 +1389,9 @@ func main() {
 		Size:       gofpdf.SizeType{Wd: pw, Ht: ph},
 		FontDirStr: *fontdir,
 	}
+	tags = deck.Tags(*variant)
+	includehidden = *hidden
+	outlines = *bookmarks
 	fontmap["sans"] = *sansfont
 	fontmap["serif"] = *serifont
 	fontmap["mono"] = *monofont
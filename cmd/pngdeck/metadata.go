@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"os"
+
+	"github.com/ajstarks/deck"
+)
+
+// pngkeys maps deck properties to the predefined keywords of PNG text chunks
+var pngkeys = map[string]string{
+	"title":       "Title",
+	"creator":     "Author",
+	"description": "Description",
+	"license":     "Copyright",
+	"date":        "Creation Time",
+}
+
+// savepng writes an image as PNG, with the deck's metadata in text chunks
+func savepng(filename string, im image.Image, d deck.Deck) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, im); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	// the text chunks follow the signature and the header chunk
+	const ihdr = 8 + 4 + 4 + 13 + 4
+	var chunks bytes.Buffer
+	chunks.Write(textchunk("Software", "pngdeck", d.Language))
+	for _, p := range d.Properties() {
+		key, ok := pngkeys[p.Name]
+		if !ok {
+			key = p.Name
+		}
+		chunks.Write(textchunk(key, p.Value, d.Language))
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data[:ihdr])
+	if err == nil {
+		_, err = f.Write(chunks.Bytes())
+	}
+	if err == nil {
+		_, err = f.Write(data[ihdr:])
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// textchunk returns an international text (iTXt) chunk, holding UTF-8 text.
+// Keywords are limited to 79 Latin-1 characters; others are left out.
+func textchunk(key, text, lang string) []byte {
+	if len(key) == 0 || len(key) > 79 {
+		return nil
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 32 || key[i] > 126 {
+			return nil
+		}
+	}
+	var c bytes.Buffer
+	c.WriteString("iTXt")
+	c.WriteString(key)
+	c.Write([]byte{0, 0, 0}) // keyword end, uncompressed
+	c.WriteString(lang)
+	c.Write([]byte{0, 0}) // language end, no translated keyword
+	c.WriteString(text)
+	chunk := make([]byte, c.Len()+8)
+	binary.BigEndian.PutUint32(chunk, uint32(c.Len()-4))
+	copy(chunk[4:], c.Bytes())
+	binary.BigEndian.PutUint32(chunk[4+c.Len():], crc32.ChecksumIEEE(c.Bytes()))
+	return chunk
+}
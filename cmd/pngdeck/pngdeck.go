@@ -6,15 +6,19 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/ajstarks/deck"
 	"github.com/disintegration/gift"
 	"github.com/fogleman/gg"
+	"github.com/golang/freetype/truetype"
 )
 
 const (
@@ -23,6 +27,7 @@ const (
 	listspacing = 2.0
 	fontfactor  = 1.0
 	listwrap    = 95.0
+	pathsize    = 2.0 // size of text on a path
 )
 
 // PageDimen describes page dimensions
@@ -34,6 +39,19 @@ type PageDimen struct {
 // fontmap maps generic font names to specific implementation names
 var fontmap = map[string]string{}
 
+// tags select the variant of the decks
+var tags []string
+
+// includehidden renders hidden slides
+var includehidden bool
+
+// deckfonts holds the fonts used by the deck, keyed by fontmap entry:
+// those carried in a bundle or declared by the deck, and those read from font files as needed
+var deckfonts = map[string]*truetype.Font{}
+
+// fallbacks maps font names to their fallback fonts, in order of preference
+var fallbacks = map[string][]string{}
+
 // pagemap defines page dimensions
 var pagemap = map[string]PageDimen{
 	"Letter":     {792, 612, 1},
@@ -69,8 +87,8 @@ func pagerange(s string) (int, int) {
 }
 
 // includefile returns the contents of a file as string
-func includefile(filename string) string {
-	data, err := ioutil.ReadFile(filename)
+func includefile(assets *deck.Resolver, filename string) string {
+	data, err := assets.ReadFile(filename)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		return ""
@@ -94,7 +112,7 @@ func fontlookup(s string) string {
 	if ok {
 		return font
 	}
-	return "sans"
+	return fontmap["sans"]
 }
 
 // grid makes a percentage scale
@@ -109,7 +127,7 @@ func grid(doc *gg.Context, w, h float64, color string, percent float64) {
 		doc.DrawLine(x, 0, x, h)
 		doc.Stroke()
 		if pl > 0 {
-			showtext(doc, x, h-fs, fmt.Sprintf("%.0f", pl), fs, "sans", "center")
+			showtext(doc, x, h-fs, fmt.Sprintf("%.0f", pl), fs, "sans", "center", "", deck.TextStyle{})
 		}
 		pl += percent
 	}
@@ -117,7 +135,7 @@ func grid(doc *gg.Context, w, h float64, color string, percent float64) {
 		doc.DrawLine(0, y, w, y)
 		doc.Stroke()
 		if pl < 100 {
-			showtext(doc, fs, y+(fs/3), fmt.Sprintf("%.0f", 100-pl), fs, "sans", "center")
+			showtext(doc, fs, y+(fs/3), fmt.Sprintf("%.0f", 100-pl), fs, "sans", "center", "", deck.TextStyle{})
 		}
 		pl += percent
 	}
@@ -131,13 +149,56 @@ func setop(v float64) int {
 	return 255
 }
 
-// bullet draws a bullet
-func bullet(doc *gg.Context, x, y, size float64, color string) {
+// bullet draws the bullet of a list item: a disc, circle, square, dash, checkbox, image, or glyph
+func bullet(doc *gg.Context, tl deck.ListEntry, cx, cy, size float64, font, color string, assets *deck.Resolver) {
+	if len(tl.BulletColor) > 0 {
+		color = tl.BulletColor
+	}
+	if len(tl.BulletFont) > 0 {
+		font = tl.BulletFont
+	}
 	rs := size / 2
 	r, g, b := colorlookup(color)
 	doc.SetRGB255(r, g, b)
-	doc.DrawCircle(x-size*2, y-rs, rs)
-	doc.Fill()
+	switch tl.Bullet {
+	case "disc":
+		doc.DrawCircle(cx, cy, rs)
+		doc.Fill()
+	case "circle":
+		doc.SetLineWidth(rs / 3)
+		doc.DrawCircle(cx, cy, rs*0.85)
+		doc.Stroke()
+	case "square":
+		doc.DrawRectangle(cx-rs*0.8, cy-rs*0.8, rs*1.6, rs*1.6)
+		doc.Fill()
+	case "dash":
+		doc.DrawRectangle(cx-rs, cy-rs/4, rs*2, rs/2)
+		doc.Fill()
+	case "checkbox", "checked":
+		doc.SetLineWidth(rs / 5)
+		doc.DrawRectangle(cx-rs, cy-rs, rs*2, rs*2)
+		doc.Stroke()
+		if tl.Bullet == "checked" {
+			doc.SetLineWidth(rs / 3)
+			doc.MoveTo(cx-rs*0.6, cy)
+			doc.LineTo(cx-rs*0.1, cy+rs*0.5)
+			doc.LineTo(cx+rs*0.7, cy-rs*0.6)
+			doc.Stroke()
+		}
+	case "image":
+		// the image is as high as the bullet, keeping its aspect ratio
+		img, err := loadimage(assets, tl.BulletImage)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "pngdeck: %v\n", err)
+			return
+		}
+		g := gift.New(gift.Resize(0, int(size*1.5), gift.BoxResampling))
+		resized := image.NewRGBA(g.Bounds(img.Bounds()))
+		g.Draw(resized, img)
+		doc.DrawImageAnchored(resized, int(cx), int(cy), 0.5, 0.5)
+	default: // a glyph
+		showtext(doc, cx, cy+rs, tl.Bullet, size*2, font, "center", "ltr", deck.TextStyle{})
+	}
 }
 
 // background places a colored rectangle
@@ -240,7 +301,7 @@ func dopoly(doc *gg.Context, xc, yc string, cw, ch float64, color string, opacit
 }
 
 // dotext places text elements on the canvas according to type
-func dotext(doc *gg.Context, cw, x, y, fs, wp, rotation, spacing float64, tdata, font, align, ttype, color string, opacity float64) {
+func dotext(doc *gg.Context, cw, x, y, fs, wp, rotation, spacing float64, tdata, font, align, ttype, color, dir string, opacity float64, ts deck.TextStyle, spans []deck.Span, wrap wrapping) {
 	var tw float64
 
 	td := strings.Split(tdata, "\n")
@@ -258,11 +319,15 @@ func dotext(doc *gg.Context, cw, x, y, fs, wp, rotation, spacing float64, tdata,
 	doc.SetRGBA255(red, green, blue, setop(opacity))
 	if ttype == "block" {
 		tw = deck.Pwidth(wp, cw, cw/2)
-		textwrap(doc, x, y, tw, fs, fs*spacing, tdata, font)
+		if len(spans) > 0 {
+			spanwrap(doc, x, y, tw, fs, fs*spacing, spans, font, ts, deck.Justified(align))
+		} else {
+			textwrap(doc, x, y, tw, fs, fs*spacing, tdata, font, dir, ts, wrap, deck.Justified(align))
+		}
 	} else {
 		ls := spacing * fs
 		for _, t := range td {
-			showtext(doc, x, y, t, fs, font, align)
+			showtext(doc, x, y, t, fs, font, align, dir, ts)
 			y += ls
 		}
 	}
@@ -271,71 +336,504 @@ func dotext(doc *gg.Context, cw, x, y, fs, wp, rotation, spacing float64, tdata,
 	}
 }
 
-// whitespace determines if a rune is whitespace
-func whitespace(r rune) bool {
-	return r == ' ' || r == '\n' || r == '\t'
+// docode draws code line by line on its background: highlighted by the syntax of its language, in the colors
+// of the scheme, with line numbers, and marked lines on a band, or others dimmed
+func docode(doc *gg.Context, cw, x, y, fs float64, tdata string, t deck.Text) {
+	lines := t.Code(codemap.Replace(tdata))
+	numbers := t.Numbers(lines)
+	colors := deck.Scheme(t.Scheme)
+	lang := deck.Lang(t.Lang)
+	if t.MarkColor == "" {
+		t.MarkColor = colors.Mark
+	}
+	if t.Rotation > 0 {
+		doc.Push()
+		doc.RotateAbout(gg.Radians(360-t.Rotation), x, y)
+	}
+	ls := t.Lp * fs
+	tw := deck.Pwidth(t.Wp, cw, cw-x-20)
+	dorect(doc, x-fs, y-fs, tw, float64(len(lines))*ls, colors.Background, 100)
+	cx := x
+	if len(numbers) > 0 {
+		cx += textwidth(doc, numbers[0]+" ", "mono", fs, 0)
+	}
+	opacity := float64(setop(t.Opacity))
+	for i, line := range lines {
+		if line.Band {
+			dorect(doc, x-fs, y-0.3*fs-ls/2, tw, ls, t.MarkColor, 100)
+		}
+		alpha := int(opacity * line.Opacity())
+		if len(numbers) > 0 {
+			red, green, blue := colorlookup(colors.Color(deck.Comment))
+			doc.SetRGBA255(red, green, blue, alpha)
+			drawtext(doc, x, y, numbers[i], "mono", fs, 0)
+		}
+		xp := cx
+		for _, tok := range line.Tokens {
+			color := t.Color
+			if lang != "" {
+				color = colors.Color(tok.Kind)
+			}
+			red, green, blue := colorlookup(color)
+			doc.SetRGBA255(red, green, blue, alpha)
+			xp += drawtext(doc, xp, y, tok.Text, "mono", fs, 0)
+		}
+		y += ls
+	}
+	if t.Rotation > 0 {
+		doc.Pop()
+	}
 }
 
-// loadfont loads a font at the specified size
-func loadfont(doc *gg.Context, s string, size float64) {
-	f, err := gg.LoadFontFace(fontlookup(s), size)
+// loadimage reads and decodes an image
+func loadimage(assets *deck.Resolver, name string) (image.Image, error) {
+	r, err := assets.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	img, _, err := image.Decode(r)
+	return img, err
+}
+
+// parsefont parses TrueType data, adding it to the deck fonts
+func parsefont(key string, data []byte) bool {
+	f, err := truetype.Parse(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pngdeck: %s: %v\n", key, err)
+		return false
+	}
+	deckfonts[key] = f
+	return true
+}
+
+// declarefonts loads the fonts declared by a deck, returning the font map for the deck,
+// which adds the declared fonts to the command-line mappings
+func declarefonts(d deck.Deck) map[string]string {
+	fm := map[string]string{}
+	for k, v := range fontmap {
+		fm[k] = v
+	}
+	for _, f := range d.Font {
+		if f.File == "" {
+			continue
+		}
+		data, err := d.Assets.ReadFile(f.File)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "pngdeck: font %s: %v\n", f.Name, err)
+			continue
+		}
+		key := filepath.Join(d.Assets.Dir, f.File)
+		if parsefont(key, data) {
+			fm[f.Name] = key
+		}
+	}
+	return fm
+}
+
+// ttfont returns the named font, reading the font file on first use
+func ttfont(s string) *truetype.Font {
+	fontfile := fontlookup(s)
+	if f, ok := deckfonts[fontfile]; ok {
+		return f
+	}
+	data, err := ioutil.ReadFile(fontfile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "pngdeck %v\n", err)
+		deckfonts[fontfile] = nil
+		return nil
+	}
+	if !parsefont(fontfile, data) {
+		deckfonts[fontfile] = nil
+	}
+	return deckfonts[fontfile]
+}
+
+// loadfont loads a font at the specified size
+func loadfont(doc *gg.Context, s string, size float64) {
+	if f := ttfont(s); f != nil {
+		doc.SetFontFace(truetype.NewFace(f, &truetype.Options{Size: size}))
+	}
+}
+
+// textruns splits text into runs using the font or its fallbacks,
+// returning the font names and the runs
+func textruns(s, font string) ([]string, []deck.Run) {
+	chain := append([]string{font}, fallbacks[font]...)
+	covers := make([]func(rune) bool, len(chain))
+	for i, name := range chain {
+		if f := ttfont(name); f != nil {
+			covers[i] = func(r rune) bool { return f.Index(r) != 0 }
+		}
+	}
+	return chain, deck.Runs(s, covers)
+}
+
+// textwidth returns the width of text, measured run by run, with tracking added between letters
+func textwidth(doc *gg.Context, s, font string, fs, tracking float64) float64 {
+	chain, runs := textruns(s, font)
+	tw := 0.0
+	for _, r := range runs {
+		loadfont(doc, chain[r.Font], fs)
+		w, _ := doc.MeasureString(r.Text)
+		tw += w
+	}
+	if n := utf8.RuneCountInString(s); n > 1 {
+		tw += tracking * float64(n-1)
+	}
+	loadfont(doc, font, fs)
+	return tw
+}
+
+// drawtext draws text run by run, with tracking added between letters, returning its width
+func drawtext(doc *gg.Context, x, y float64, s, font string, fs, tracking float64) float64 {
+	chain, runs := textruns(s, font)
+	tw := 0.0
+	letters := 0
+	for _, r := range runs {
+		loadfont(doc, chain[r.Font], fs)
+		if tracking == 0 {
+			doc.DrawString(r.Text, x+tw, y)
+			w, _ := doc.MeasureString(r.Text)
+			tw += w
+			continue
+		}
+		for _, c := range r.Text {
+			if letters > 0 {
+				tw += tracking
+			}
+			letters++
+			doc.DrawString(string(c), x+tw, y)
+			w, _ := doc.MeasureString(string(c))
+			tw += w
+		}
+	}
+	loadfont(doc, font, fs)
+	return tw
+}
+
+// highlight draws the highlight behind text of width w at x, on the baseline y
+func highlight(doc *gg.Context, x, y, w, fs float64, ts deck.TextStyle) {
+	if !ts.Highlighted() || w == 0 {
 		return
 	}
-	doc.SetFontFace(f)
+	top, h, m := ts.HighlightBox(y, fs)
+	r, g, b := colorlookup(ts.Highlight)
+	doc.Push()
+	doc.SetRGB255(r, g, b)
+	doc.DrawRectangle(x-m, top, w+m*2, h)
+	doc.Fill()
+	doc.Pop()
+}
+
+// decorate draws the lines under or through text of width w at x, on the baseline y, in the text color
+func decorate(doc *gg.Context, x, y, w, fs float64, ts deck.TextStyle) {
+	lines, thickness := ts.Lines(y, fs)
+	if len(lines) == 0 || w == 0 {
+		return
+	}
+	doc.SetLineWidth(thickness)
+	for _, ly := range lines {
+		doc.DrawLine(x, ly, x+w, ly)
+		doc.Stroke()
+	}
 }
 
-// textwrap draws text at location, wrapping at the specified width
-func textwrap(doc *gg.Context, x, y, w, fs, leading float64, s, font string) int {
+// textwrap draws text at location, broken into lines for the width by the wrapping function.
+// Lines of justified text, but the last, are stretched to the width.
+// Right to left text is set against the location, extending to the left.
+func textwrap(doc *gg.Context, x, y, w, fs, leading float64, s, font, dir string, ts deck.TextStyle, wrap wrapping, justify bool) int {
+	tracking := ts.Tracking(fs)
+	wordspacing, measure := measures(doc, font, fs, ts)
+	lines := wrap(s, w, wordspacing, measure)
+	rtl := deck.RTL(s, dir)
+	yp := y
+	for n, line := range lines {
+		words := strings.Fields(deck.Visual(line, rtl))
+		// the width of the line places right to left text, and its highlight and decoration
+		lw := 0.0
+		for i, word := range words {
+			if i > 0 {
+				lw += wordspacing
+			}
+			lw += measure(word)
+		}
+		spacing := wordspacing
+		if justify && n < len(lines)-1 && len(words) > 1 {
+			spacing += (w - lw) / float64(len(words)-1)
+			lw = w
+		}
+		xp := x
+		if rtl {
+			xp -= lw
+		}
+		lx := xp
+		highlight(doc, lx, yp, lw, fs, ts)
+		for _, word := range words {
+			xp += drawtext(doc, xp, yp, word, font, fs, tracking) + spacing
+		}
+		decorate(doc, lx, yp, lw, fs, ts)
+		yp += leading
+	}
+	return len(lines) - 1
+}
+
+// wrapping breaks text into lines for a width, given the space between words and the measure of words
+type wrapping func(s string, width, space float64, measure func(string) float64) []string
+
+// spanwrap draws styled text at location, broken into lines of words that fit the width.
+// Lines of justified text, but the last, are stretched to the width.
+func spanwrap(doc *gg.Context, x, y, w, fs, leading float64, spans []deck.Span, font string, ts deck.TextStyle, justify bool) int {
+	tracking := ts.Tracking(fs)
+	wordspacing, _ := measures(doc, font, fs, ts)
+	measure := func(word deck.Word) float64 {
+		ww := 0.0
+		for _, s := range word {
+			ww += spanwidth(doc, s, font, fs, tracking)
+		}
+		return ww
+	}
+	lines := deck.WrapWords(deck.Words(spans), w, wordspacing, measure)
+	yp := y
+	for n, line := range lines {
+		lw := 0.0
+		for i, word := range line {
+			if i > 0 {
+				lw += wordspacing
+			}
+			lw += measure(word)
+		}
+		spacing := wordspacing
+		if justify && n < len(lines)-1 && len(line) > 1 {
+			spacing += (w - lw) / float64(len(line)-1)
+			lw = w
+		}
+		highlight(doc, x, yp, lw, fs, ts)
+		xp := x
+		for _, word := range line {
+			for _, s := range word {
+				xp += drawspan(doc, xp, yp, s, font, fs, tracking)
+			}
+			xp += spacing
+		}
+		decorate(doc, x, yp, lw, fs, ts)
+		yp += leading
+	}
+	return len(lines) - 1
+}
+
+// hasfont reports whether a font is known by name
+func hasfont(name string) bool {
+	_, ok := fontmap[name]
+	return ok
+}
+
+// spanwidth returns the width of a span of styled text; text made bold is drawn twice, a little apart
+func spanwidth(doc *gg.Context, s deck.Span, font string, fs, tracking float64) float64 {
+	face, bold, _ := s.Face(font, hasfont)
+	tw := textwidth(doc, s.Text, face, fs, tracking)
+	if bold {
+		tw += fs / 30
+	}
+	return tw
+}
+
+// drawspan draws a span of styled text, returning its width: code on a light background,
+// links underlined, and text slanted or made bold if the deck has no italic or bold font for it
+func drawspan(doc *gg.Context, x, y float64, s deck.Span, font string, fs, tracking float64) float64 {
+	face, bold, italic := s.Face(font, hasfont)
+	tw := spanwidth(doc, s, font, fs, tracking)
+	if s.Code {
+		highlight(doc, x, y, tw, fs, deck.TextStyle{Highlight: "rgb(240,240,240)"})
+	}
+	if italic {
+		doc.Push()
+		doc.ShearAbout(-0.2, 0, x, y)
+	}
+	drawtext(doc, x, y, s.Text, face, fs, tracking)
+	if bold {
+		drawtext(doc, x+fs/30, y, s.Text, face, fs, tracking)
+	}
+	if italic {
+		doc.Pop()
+	}
+	loadfont(doc, font, fs)
+	if len(s.Link) > 0 {
+		decorate(doc, x, y, tw, fs, deck.TextStyle{Decoration: "underline"})
+	}
+	return tw
+}
+
+// measures returns the space between words of text, and the measure of its words
+func measures(doc *gg.Context, font string, fs float64, ts deck.TextStyle) (float64, func(string) float64) {
 	var factor = 0.3
 	if font == "mono" {
 		factor = 1.0
 	}
-	nbreak := 0
 	loadfont(doc, font, fs)
 	wordspacing, _ := doc.MeasureString("M")
-	words := strings.FieldsFunc(s, whitespace)
-	xp := x
-	yp := y
-	edge := x + w
-	for _, s := range words {
-		tw, _ := doc.MeasureString(s)
-		doc.DrawString(s, xp, yp)
-		xp += tw + (wordspacing * factor)
-		if xp > edge {
-			xp = x
-			yp += leading
-			nbreak++
-		}
+	wordspacing *= factor
+	tracking := ts.Tracking(fs)
+	measure := func(s string) float64 { return textwidth(doc, s, font, fs, tracking) }
+	return wordspacing, measure
+}
+
+// textbox draws a text box at x, y (its top left corner): the box, filled, bordered, and rounded,
+// and its text, wrapped within the padding and aligned to the top, middle, or bottom.
+// Fitted text is sized to fit the box; textbox reports whether it does.
+func textbox(doc *gg.Context, cw, ch, x, y, fs float64, tdata string, t deck.Text, wrap wrapping) bool {
+	if t.Rotation > 0 {
+		doc.Push()
+		doc.RotateAbout(gg.Radians(360-t.Rotation), x, y)
+	}
+	w, h, pad := deck.Pwidth(t.Wp, cw, cw/2), pct(t.Hp, ch), pct(t.Padding, cw)
+	_, _, smallest := dimen(cw, ch, 0, 0, t.Smallest())
+	largest := h
+	if h <= 0 {
+		largest = w
+	}
+	fs, fits := t.Size(fs, smallest, largest, func(fs float64) bool {
+		wordspacing, measure := measures(doc, t.Font, fs, t.TextStyle)
+		lines := wrap(tdata, w-pad*2, wordspacing, measure)
+		return t.Fits(lines, w, h, pad, fs, fs*t.Lp, wordspacing, measure)
+	})
+	leading := fs * t.Lp
+	wordspacing, measure := measures(doc, t.Font, fs, t.TextStyle)
+	lines := wrap(tdata, w-pad*2, wordspacing, measure)
+	h, ty := t.Box(y, h, pad, lines, fs, leading)
+	if len(t.Fill) > 0 {
+		r, g, b := colorlookup(t.Fill)
+		doc.SetRGBA255(r, g, b, setop(t.Opacity))
+		doc.DrawRoundedRectangle(x, y, w, h, pct(t.Radius, cw))
+		doc.Fill()
+	}
+	if len(t.Border) > 0 {
+		r, g, b := colorlookup(t.Border)
+		doc.SetRGBA255(r, g, b, setop(t.Opacity))
+		doc.SetLineWidth(deck.Pwidth(t.BorderWidth, cw, pct(0.2, cw)))
+		doc.DrawRoundedRectangle(x, y, w, h, pct(t.Radius, cw))
+		doc.Stroke()
 	}
-	return nbreak
+	// right to left text is set against the right of the box
+	tx := x + pad
+	if deck.RTL(tdata, t.Dir) {
+		tx = x + w - pad
+	}
+	red, green, blue := colorlookup(t.Color)
+	doc.SetRGBA255(red, green, blue, setop(t.Opacity))
+	textwrap(doc, tx, ty, w-pad*2, fs, leading, tdata, t.Font, t.Dir, t.TextStyle, wrap, deck.Justified(t.Align))
+	if t.Rotation > 0 {
+		doc.Pop()
+	}
+	return fits
+}
+
+// fitlist returns the size of the text of a list fitted to its box, and whether the list fits
+func fitlist(doc *gg.Context, cw, ch, fs float64, l deck.List, entries []deck.ListEntry) (float64, bool) {
+	w, h := deck.Pwidth(l.Wp, cw, cw/2), pct(l.Hp, ch)
+	_, _, smallest := dimen(cw, ch, 0, 0, l.Smallest())
+	largest := h
+	if h <= 0 {
+		largest = w
+	}
+	return l.Size(fs, smallest, largest, func(fs float64) bool {
+		return l.Fits(entries, w, h, fs, func(font string, fs float64, ts deck.TextStyle) (float64, func(string) float64) {
+			return measures(doc, font, fs, ts)
+		})
+	})
+}
+
+// overflow warns of text that does not fit its box, even at the smallest size
+func overflow(n int, s string) {
+	fmt.Fprintf(os.Stderr, "pngdeck: slide %d: %.40q does not fit its box at the smallest size\n", n+1, strings.TrimSpace(s))
 }
 
 // showtext places fully attributed text at the specified location
-func showtext(doc *gg.Context, x, y float64, s string, fs float64, font, align string) {
+func showtext(doc *gg.Context, x, y float64, s string, fs float64, font, align, dir string, ts deck.TextStyle) {
 	offset := 0.0
-	loadfont(doc, font, fs)
-	t := s
-	tw, _ := doc.MeasureString(t)
+	rtl := deck.RTL(s, dir)
+	s = deck.Visual(s, rtl)
+	align = deck.Align(align, rtl)
+	tracking := ts.Tracking(fs)
+	tw := textwidth(doc, s, font, fs, tracking)
 	switch align {
 	case "center", "middle", "mid", "c":
 		offset = (tw / 2)
 	case "right", "end", "e":
 		offset = tw
 	}
-	doc.DrawString(t, x-offset, y)
+	highlight(doc, x-offset, y, tw, fs, ts)
+	drawtext(doc, x-offset, y, s, font, fs, tracking)
+	decorate(doc, x-offset, y, tw, fs, ts)
+}
+
+// doqrcode draws a QR code centered at x, y, w wide with its quiet zone, as an image with a pixel
+// or more for each module
+func doqrcode(doc *gg.Context, x, y, w float64, q deck.QRCode) error {
+	modules, err := q.Modules()
+	if err != nil {
+		return err
+	}
+	n := len(modules)
+	size := int(w + 0.5)
+	if size < n+2*deck.QuietZone {
+		size = n + 2*deck.QuietZone
+	}
+	alpha := uint8(setop(q.Opacity))
+	r, g, b := colorlookup(q.Color)
+	dark := color.NRGBA{uint8(r), uint8(g), uint8(b), alpha}
+	r, g, b = colorlookup(q.Bg)
+	light := color.NRGBA{uint8(r), uint8(g), uint8(b), alpha}
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	m := float64(size) / float64(n+2*deck.QuietZone)
+	for py := 0; py < size; py++ {
+		row := int(float64(py)/m) - deck.QuietZone
+		for px := 0; px < size; px++ {
+			col := int(float64(px)/m) - deck.QuietZone
+			if row >= 0 && row < n && col >= 0 && col < n && modules[row][col] {
+				img.SetNRGBA(px, py, dark)
+			} else {
+				img.SetNRGBA(px, py, light)
+			}
+		}
+	}
+	doc.DrawImage(img, int(x-float64(size)/2+0.5), int(y-float64(size)/2+0.5))
+	return nil
+}
+
+// dotextpath draws text along its path, turning each character along the way
+func dotextpath(doc *gg.Context, cw, ch, fs float64, t deck.TextPath) {
+	red, green, blue := colorlookup(t.Color)
+	doc.SetRGBA255(red, green, blue, setop(t.Opacity))
+	tracking := t.Tracking(fs)
+	measure := func(s string) float64 { return textwidth(doc, s, t.Font, fs, tracking) }
+	for _, g := range t.Glyphs(cw, ch, measure) {
+		doc.Push()
+		doc.RotateAbout(gg.Radians(-g.Angle), g.X, g.Y)
+		drawtext(doc, g.X, g.Y, g.Text, t.Font, fs, tracking)
+		doc.Pop()
+	}
 }
 
 // dolists places lists on the canvas
-func dolist(doc *gg.Context, cw, x, y, fs, lwidth, rotation, spacing float64, list []deck.ListItem, font, ltype, align, color string, opacity float64) {
+func dolist(doc *gg.Context, cw, x, y, fs, lwidth, rotation, spacing float64, list []deck.ListEntry, font, ltype, align, color, dir string, opacity float64, assets *deck.Resolver, wrap wrapping) {
 	if font == "" {
 		font = "sans"
 	}
 	red, green, blue := colorlookup(color)
 
-	if ltype == "bullet" {
-		x += fs * 1.2
+	// right to left lists start at the right, with bullets to the right of the text
+	rtl := len(list) > 0 && deck.RTL(list[0].ListText, dir)
+	dir = "ltr"
+	if rtl {
+		dir = "rtl"
+	}
+	if ltype == "bullet" || ltype == "check" {
+		if rtl {
+			x -= fs * 1.2
+		} else {
+			x += fs * 1.2
+		}
 	}
 	ls := spacing * fs
 	tw := deck.Pwidth(lwidth, cw, cw/2)
@@ -345,32 +843,49 @@ func dolist(doc *gg.Context, cw, x, y, fs, lwidth, rotation, spacing float64, li
 		doc.RotateAbout(gg.Radians(360-rotation), x, y)
 	}
 	var t string
-	for i, tl := range list {
-		loadfont(doc, font, fs)
+	for _, tl := range list {
+		// nested levels are indented, and sized relative to the list
+		ifs, ix := fs*tl.Scale, x+fs*tl.Indent
+		if rtl {
+			ix = x - fs*tl.Indent
+		}
+		ils := ls * tl.Scale
+		// bullets are centered where the text's bullet would be, sized on their own
+		if len(tl.Bullet) > 0 && rtl {
+			bullet(doc, tl, ix+ifs, y-ifs/4, fs*tl.BulletScale/2, font, color, assets)
+		} else if len(tl.Bullet) > 0 {
+			bullet(doc, tl, ix-ifs, y-ifs/4, fs*tl.BulletScale/2, font, color, assets)
+		}
+		loadfont(doc, font, ifs)
 		doc.SetRGB255(red, green, blue)
-		if ltype == "number" {
-			t = fmt.Sprintf("%d. ", i+1) + tl.ListText
+		if len(tl.Number) > 0 {
+			t = tl.Number + " " + tl.ListText
 		} else {
 			t = tl.ListText
 		}
-		if ltype == "bullet" {
-			bullet(doc, x, y, fs/2, color)
-		}
 		if len(tl.Color) > 0 {
 			tlred, tlgreen, tlblue := colorlookup(tl.Color)
 			doc.SetRGB255(tlred, tlgreen, tlblue)
 		}
+		ifont := font
 		if len(tl.Font) > 0 {
-			loadfont(doc, tl.Font, fs)
+			ifont = tl.Font
 		}
 		if align == "center" || align == "c" {
-			showtext(doc, x, y, t, fs, font, align)
-			y += ls
+			showtext(doc, ix, y, t, ifs, ifont, align, dir, tl.TextStyle)
+			y += ils
+		} else if len(tl.Spans) > 0 {
+			spans := tl.Spans
+			if len(tl.Number) > 0 {
+				spans = append([]deck.Span{{Text: tl.Number + " "}}, spans...)
+			}
+			yw := spanwrap(doc, ix, y, tw-fs*tl.Indent, ifs, ils, spans, ifont, tl.TextStyle, false)
+			y += ils * float64(yw+1)
 		} else {
-			yw := textwrap(doc, x, y, tw, fs, ls, t, font)
-			y += ls
+			yw := textwrap(doc, ix, y, tw-fs*tl.Indent, ifs, ils, t, ifont, dir, tl.TextStyle, wrap, false)
+			y += ils
 			if yw >= 1 {
-				y += ls * float64(yw)
+				y += ils * float64(yw)
 			}
 		}
 	}
@@ -387,9 +902,14 @@ func pngslide(doc *gg.Context, d deck.Deck, n int, gp float64, showslide bool, d
 
 	var x, y, fs float64
 
+	d.Canvas = d.SlideCanvas(n)
 	cw := float64(d.Canvas.Width)
 	ch := float64(d.Canvas.Height)
 	slide := d.Slide[n]
+	// the blocks of markdown text are placed by the lines they wrap into, in their fonts
+	deck.Flow(slide.Text, slide.List, cw, ch, func(font string, fs float64, ts deck.TextStyle) (float64, func(string) float64) {
+		return measures(doc, font, fs, ts)
+	})
 	// set default background
 	if slide.Bg == "" {
 		slide.Bg = "white"
@@ -422,7 +942,7 @@ func pngslide(doc *gg.Context, d deck.Deck, n int, gp float64, showslide bool, d
 			iw = d.Canvas.Width
 		}
 
-		img, err := gg.LoadImage(im.Name)
+		img, err := loadimage(d.Assets, im.Name)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "pngdeck: slide %d (%v)\n", n+1, err)
 			return
@@ -457,7 +977,7 @@ func pngslide(doc *gg.Context, d deck.Deck, n int, gp float64, showslide bool, d
 			}
 			capr, capg, capb := colorlookup(im.Color)
 			doc.SetRGB255(capr, capg, capb)
-			showtext(doc, x, y+(midy)+(capsize*1.5), im.Caption, capsize, im.Font, im.Align)
+			showtext(doc, x, y+(midy)+(capsize*1.5), im.Caption, capsize, im.Font, im.Align, im.Dir, im.TextStyle)
 		}
 	}
 	// every graphic on the slide
@@ -537,6 +1057,19 @@ func pngslide(doc *gg.Context, d deck.Deck, n int, gp float64, showslide bool, d
 		}
 		dopoly(doc, poly.XC, poly.YC, cw, ch, poly.Color, poly.Opacity)
 	}
+	// QR codes
+	for _, q := range slide.QRCode {
+		if q.Color == "" {
+			q.Color = "black"
+		}
+		if q.Bg == "" {
+			q.Bg = "white"
+		}
+		x, y, _ := dimen(cw, ch, q.Xp, q.Yp, 0)
+		if err := doqrcode(doc, x, y, pct(q.Wp, cw), q); err != nil {
+			fmt.Fprintf(os.Stderr, "pngdeck: slide %d: %v\n", n+1, err)
+		}
+	}
 
 	// for every text element...
 	var tdata string
@@ -549,14 +1082,39 @@ func pngslide(doc *gg.Context, d deck.Deck, n int, gp float64, showslide bool, d
 		}
 		x, y, fs = dimen(cw, ch, t.Xp, t.Yp, t.Sp)
 		if t.File != "" {
-			tdata = includefile(t.File)
+			tdata = includefile(d.Assets, t.File)
 		} else {
 			tdata = t.Tdata
 		}
 		if t.Lp == 0 {
 			t.Lp = linespacing
 		}
-		dotext(doc, cw, x, y, fs, t.Wp, t.Rotation, t.Lp, tdata, t.Font, t.Align, t.Type, t.Color, t.Opacity)
+		wrap := t.Wrapper(d.Assets.Hyphenator(t.Hyphenate))
+		if t.IsBox() {
+			if !textbox(doc, cw, ch, x, y, fs, tdata, t, wrap) {
+				overflow(n, tdata)
+			}
+			continue
+		}
+		if t.IsListing() {
+			docode(doc, cw, x, y, fs, tdata, t)
+			continue
+		}
+		dotext(doc, cw, x, y, fs, t.Wp, t.Rotation, t.Lp, tdata, t.Font, t.Align, t.Type, t.Color, t.Dir, t.Opacity, t.TextStyle, t.Spans, wrap)
+	}
+	// text on paths
+	for _, t := range slide.TextPath {
+		if t.Color == "" {
+			t.Color = slide.Fg
+		}
+		if t.Font == "" {
+			t.Font = "sans"
+		}
+		if t.Sp == 0 {
+			t.Sp = pathsize
+		}
+		_, _, fs = dimen(cw, ch, 0, 0, t.Sp)
+		dotextpath(doc, cw, ch, fs, t)
 	}
 	// for every list element...
 	for _, l := range slide.List {
@@ -569,30 +1127,61 @@ func pngslide(doc *gg.Context, d deck.Deck, n int, gp float64, showslide bool, d
 		if l.Wp == 0 {
 			l.Wp = listwrap
 		}
+		if l.Font == "" {
+			l.Font = "sans"
+		}
 		x, y, fs = dimen(cw, ch, l.Xp, l.Yp, l.Sp)
-		dolist(doc, cw, x, y, fs, l.Wp, l.Rotation, l.Lp, l.Li, l.Font, l.Type, l.Align, l.Color, l.Opacity)
+		entries := l.Entries()
+		if len(l.Fit) > 0 {
+			var fits bool
+			if fs, fits = fitlist(doc, cw, ch, fs, l, entries); !fits {
+				overflow(n, l.Li[0].ListText)
+			}
+		}
+		dolist(doc, cw, x, y, fs, l.Wp, l.Rotation, l.Lp, entries, l.Font, l.Type, l.Align, l.Color, l.Dir, l.Opacity, d.Assets, l.Wrapping())
 	}
 	// add a grid, if specified
 	if gp > 0 {
 		grid(doc, cw, ch, slide.Fg, gp)
 	}
-	doc.SavePNG(fmt.Sprintf("%s-%05d.png", dest, n+1))
+	if err := savepng(fmt.Sprintf("%s-%05d.png", dest, n+1), doc.Image(), d); err != nil {
+		fmt.Fprintf(os.Stderr, "pngdeck: %v\n", err)
+	}
 }
 
 // doslides reads the deck file, making a series of PNGs
 func doslides(outname, filename string, w, h int, gp float64, begin, end int) {
 	var d deck.Deck
 	var err error
-	d, err = deck.Read(filename, w, h)
+	d, err = deck.Read(filename, w, h, tags...)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "pngdeck: %v\n", err)
 		return
 	}
+	// warn of the assets that could not be found or read, once the slides are made
+	defer func() {
+		for _, err := range d.Assets.Errors() {
+			fmt.Fprintf(os.Stderr, "pngdeck: %v\n", err)
+		}
+	}()
 	d.Canvas.Width = w
 	d.Canvas.Height = h
 
+	// use the fonts carried in a bundle, and those declared by the deck (for this deck only)
+	deckfonts = map[string]*truetype.Font{}
+	for _, fontfile := range fontmap {
+		if data, ok := d.Assets.Font(filepath.Base(fontfile)); ok {
+			parsefont(fontfile, data)
+		}
+	}
+	defer func(fm map[string]string) { fontmap = fm }(fontmap)
+	fontmap = declarefonts(d)
+	fallbacks = d.Fallbacks()
+
 	for i := 0; i < len(d.Slide); i++ {
-		pngslide(gg.NewContext(w, h), d, i, gp, (i+1 >= begin && i+1 <= end), outname)
+		shown := i+1 >= begin && i+1 <= end && (includehidden || !d.Slide[i].IsHidden())
+		c := d.SlideCanvas(i)
+		pngslide(gg.NewContext(c.Width, c.Height), d, i, gp, shown, outname)
 	}
 }
 
@@ -600,8 +1189,8 @@ func doslides(outname, filename string, w, h int, gp float64, begin, end int) {
 // PNGs are written to the destination directory, to filenames based on the input name.
 func dodeck(files []string, w, h float64, outdir string, gp float64, begin, end int) {
 	for _, filename := range files {
-		base := strings.Split(filepath.Base(filename), ".xml")
-		outname := filepath.Join(outdir, base[0])
+		base := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(filename), deck.BundleExt), ".xml")
+		outname := filepath.Join(outdir, base)
 		doslides(outname, filename, int(w), int(h), gp, begin, end)
 	}
 }
@@ -618,6 +1207,8 @@ func main() {
 		outdir     = flag.String("outdir", ".", "output directory")
 		gridpct    = flag.Float64("grid", 0, "draw a percentage grid on each slide")
 		pr         = flag.String("pages", "1-1000000", "page range (first-last)")
+		variant    = flag.String("tags", "", "comma separated tags selecting a variant of the deck")
+		hidden     = flag.Bool("include-hidden", false, "render hidden slides")
 	)
 	flag.Parse()
 
@@ -635,6 +1226,8 @@ func main() {
 		pw = p.width * p.unit
 		ph = p.height * p.unit
 	}
+	tags = deck.Tags(*variant)
+	includehidden = *hidden
 	fontmap["sans"] = filepath.Join(*fontdir, *sansfont+".ttf")
 	fontmap["serif"] = filepath.Join(*fontdir, *serifont+".ttf")
 	fontmap["mono"] = filepath.Join(*fontdir, *monofont+".ttf")
@@ -13,12 +13,17 @@ Clicking on any content will navigate to the next slide, cycling to the first sl
 
 the -grid percent option draws a grid scaled to the specifed percentage on each slide.
 
-the -sans, -serif, and -mono options specify fonts.
+the -sans, -serif, and -mono options specify fonts. Text in the fonts a deck declares is measured by their metrics,
+for wrapping and fitting; the letters of other fonts, which the viewer finds, are estimated at 0.65 of the text size.
 
 the -outdir option specifies the directory where SVG files are written; defaults to the current directory.
 
 the -title options adds title metadata.
 
+the -include-hidden option renders hidden slides, which are otherwise left out.
+
+the -tags option makes a variant of the deck, keeping the content selected by the comma separated tags.
+
 the -pagesize option specifies the page dimensions (Letter, Legal, A3, A4, A5).
 
 the -stdout option specified that output goes to the standard output file.
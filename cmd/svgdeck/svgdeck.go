@@ -2,17 +2,23 @@
 package main
 
 import (
+	"encoding/base64"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"html"
 	"math"
+	"mime"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/ajstarks/deck"
 	svg "github.com/ajstarks/svgo/float"
+	"github.com/golang/freetype/truetype"
+	xfont "golang.org/x/image/font"
 )
 
 const (
@@ -21,6 +27,7 @@ const (
 	listspacing = 2.0
 	fontfactor  = 1.0
 	listwrap    = 95.0
+	pathsize    = 2.0 // size of text on a path
 	namefmt     = "%s-%05d.svg"
 	strokefmt   = "stroke-width:%.2fpx;stroke:%s;stroke-opacity:%.2f"
 	fillfmt     = "fill:%s;fill-opacity:%.2f"
@@ -35,6 +42,24 @@ type PageDimen struct {
 // fontmap maps generic font names to specific implementation names
 var fontmap = map[string]string{}
 
+// tags select the variant of the decks
+var tags []string
+
+// includehidden renders hidden slides
+var includehidden bool
+
+// fallbacks maps font names to their fallback fonts, in order of preference
+var fallbacks = map[string][]string{}
+
+// fontfaces holds the @font-face rules for the fonts declared by the deck
+var fontfaces string
+
+// metrics holds the fonts declared by the deck, by name, for measuring text
+var metrics = map[string]*truetype.Font{}
+
+// highlights maps the highlight colors of a slide to the filters that draw them
+var highlights = map[string]string{}
+
 // pagemap defines page dimensions
 var pagemap = map[string]PageDimen{
 	"Letter":     {792, 612, 1},
@@ -69,6 +94,42 @@ func pagerange(s string) (int, int) {
 	return b, e
 }
 
+// imageref returns the reference to an image, relative to the location of the SVG output.
+// Images carried in a bundle are embedded as data URIs.
+func imageref(assets *deck.Resolver, name, outname string) string {
+	if assets != nil && assets.FS != nil {
+		return datauri(assets, name)
+	}
+	path := assets.Resolve(name)
+	if path == name {
+		return name
+	}
+	absdir, derr := filepath.Abs(filepath.Dir(outname))
+	abspath, perr := filepath.Abs(path)
+	if derr != nil || perr != nil {
+		return path
+	}
+	rel, err := filepath.Rel(absdir, abspath)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// datauri returns the contents of an image as a data URI
+func datauri(assets *deck.Resolver, name string) string {
+	data, err := assets.ReadFile(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "svgdeck: %v\n", err)
+		return name
+	}
+	mtype := mime.TypeByExtension(filepath.Ext(name))
+	if mtype == "" {
+		mtype = http.DetectContentType(data)
+	}
+	return "data:" + mtype + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
 // grid makes a labeled grid
 func grid(doc *svg.SVG, w, h float64, color string, percent float64) {
 	pw := w * (percent / 100)
@@ -137,18 +198,28 @@ func whitespace(r rune) bool {
 	return r == ' ' || r == '\n' || r == '\t'
 }
 
-// fontlookup maps font aliases to implementation font names
+// fontlookup maps font aliases to implementation font names,
+// listing any fallbacks for the viewer to use for missing characters
 func fontlookup(s string) string {
+	family := fontfamily(s)
+	for _, fb := range fallbacks[s] {
+		family += "," + fontfamily(fb)
+	}
+	return family
+}
+
+// fontfamily maps a font alias to its implementation name
+func fontfamily(s string) string {
 	font, ok := fontmap[s]
 	if ok {
 		return font
 	}
-	return "sans"
+	return fontmap["sans"]
 }
 
 // includefile returns the contents of a file as string
-func includefile(filename string) string {
-	data, err := ioutil.ReadFile(filename)
+func includefile(assets *deck.Resolver, filename string) string {
+	data, err := assets.ReadFile(filename)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		return ""
@@ -166,10 +237,99 @@ func fillop(color string, opacity float64) string {
 	return fmt.Sprintf(fillfmt, color, setop(opacity))
 }
 
-// bullet draws a bullet
-func bullet(doc *svg.SVG, x, y, size float64, color string) {
-	rs := size / 2
-	doc.Circle(x-size, y-(rs*2)/3, rs/2, "fill:"+color)
+// href returns the target of a link: a URL, or the file of a slide ("#n"),
+// which is empty when the slides are not written to files
+func href(link, outname string) string {
+	n, ok := deck.SlideLink(link)
+	switch {
+	case ok && outname == "":
+		return ""
+	case ok:
+		return filepath.Base(fmt.Sprintf(namefmt, outname, n))
+	}
+	return link
+}
+
+// attr formats an attribute, escaping its value
+func attr(name, value string) string {
+	return name + `="` + html.EscapeString(value) + `"`
+}
+
+// describe begins a group for a graphic with a text alternative or title, labelled for screen readers,
+// and returns true; decorative graphics, without either, are not grouped
+func describe(doc *svg.SVG, id, alt, title string) bool {
+	if alt == "" && title == "" {
+		return false
+	}
+	name := title
+	if name == "" {
+		name = alt
+	}
+	doc.Group(attr("id", id), `role="img"`, attr("aria-label", name))
+	doc.Title(name)
+	if alt != "" && alt != name {
+		doc.Desc(alt)
+	}
+	return true
+}
+
+// readingorder returns the attributes of a slide giving it a title and description,
+// and presenting its elements to screen readers in reading order
+func readingorder(d deck.Deck, n int, title string) []string {
+	if title == "" {
+		title = d.Title
+	}
+	label := fmt.Sprintf("Slide %d", n+1)
+	if len(title) > 0 {
+		label = title + ": " + label
+	}
+	items := d.ReadingOrder(n)
+	ids := make([]string, len(items))
+	for i, it := range items {
+		ids[i] = fmt.Sprintf("%s-%d", it.Kind, it.Index)
+	}
+	if len(items) > 0 && items[0].Label() != "" {
+		label += ", " + items[0].Label()
+	}
+	attrs := []string{`role="document"`, attr("aria-label", label)}
+	if len(ids) > 0 {
+		attrs = append(attrs, attr("aria-owns", strings.Join(ids, " ")))
+	}
+	return attrs
+}
+
+// bullet draws the bullet of a list item: a disc, circle, square, dash, checkbox, image, or glyph
+func bullet(doc *svg.SVG, tl deck.ListEntry, cx, cy, size float64, color string, assets *deck.Resolver, outname string) {
+	if len(tl.BulletColor) > 0 {
+		color = tl.BulletColor
+	}
+	r := size / 2
+	switch tl.Bullet {
+	case "disc":
+		doc.Circle(cx, cy, r, "fill:"+color)
+	case "circle":
+		doc.Circle(cx, cy, r*0.85, fmt.Sprintf("fill:none;stroke:%s;stroke-width:%.2f", color, r/3))
+	case "square":
+		doc.Rect(cx-r*0.8, cy-r*0.8, r*1.6, r*1.6, "fill:"+color)
+	case "dash":
+		doc.Rect(cx-r, cy-r/4, r*2, r/2, "fill:"+color)
+	case "checkbox", "checked":
+		doc.Rect(cx-r, cy-r, r*2, r*2, fmt.Sprintf("fill:none;stroke:%s;stroke-width:%.2f", color, r/5))
+		if tl.Bullet == "checked" {
+			doc.Polyline([]float64{cx - r*0.6, cx - r*0.1, cx + r*0.7}, []float64{cy, cy + r*0.5, cy - r*0.6},
+				fmt.Sprintf("fill:none;stroke:%s;stroke-width:%.2f", color, r/3))
+		}
+	case "image":
+		// the image is as high as the bullet, keeping its aspect ratio
+		h := size * 1.5
+		doc.Image(cx-h/2, cy-h/2, int(h), int(h), imageref(assets, tl.BulletImage, outname))
+	default: // a glyph
+		style := fmt.Sprintf("text-anchor:middle;fill:%s;font-size:%.2fpx", color, size*2)
+		if len(tl.BulletFont) > 0 {
+			style += ";font-family:" + fontlookup(tl.BulletFont)
+		}
+		doc.Text(cx, cy+r*4/3, tl.Bullet, style)
+	}
 }
 
 // background places a colored rectangle
@@ -235,7 +395,7 @@ func dopoly(doc *svg.SVG, xc, yc string, cw, ch float64, color string, opacity f
 }
 
 // dotext places text elements on the canvas according to type
-func dotext(doc *svg.SVG, cw, x, y, fs, wp, ls float64, tdata, font, align, ttype, color string, opacity float64) {
+func dotext(doc *svg.SVG, cw, x, y, fs, wp, ls float64, tdata, font, align, ttype, color, dir string, opacity float64, ts deck.TextStyle, wrapper wrapping) {
 	var tw float64
 	ls *= fs
 	td := strings.Split(tdata, "\n")
@@ -251,15 +411,118 @@ func dotext(doc *svg.SVG, cw, x, y, fs, wp, ls float64, tdata, font, align, ttyp
 		} else {
 			tw = (cw * (wp / 100.0))
 		}
-		textwrap(doc, x, y, tw, fs, ls, tdata, font, color, opacity)
+		textwrap(doc, x, y, tw, fs, ls, tdata, font, color, dir, opacity, ts, wrapper, deck.Justified(align))
 	} else {
 		for _, t := range td {
-			showtext(doc, x, y, t, fs, font, color, align)
+			showtext(doc, x, y, t, fs, font, color, align, dir, ts)
 			y += ls
 		}
 	}
 }
 
+// docode draws code line by line on its background: highlighted by the syntax of its language, in the colors
+// of the scheme, with line numbers, and marked lines on a band, or others dimmed
+func docode(doc *svg.SVG, cw, x, y, fs float64, tdata string, t deck.Text) {
+	lines := t.Code(codemap.Replace(tdata))
+	numbers := t.Numbers(lines)
+	colors := deck.Scheme(t.Scheme)
+	lang := deck.Lang(t.Lang)
+	color := t.Color
+	if lang != "" {
+		color = colors.Text
+	}
+	if t.MarkColor == "" {
+		t.MarkColor = colors.Mark
+	}
+	ls := t.Lp * fs
+	tw := deck.Pwidth(t.Wp, cw, cw-x-20)
+	dorect(doc, x-fs, y-fs, tw, float64(len(lines))*ls, colors.Background, t.Opacity)
+	for _, line := range lines {
+		if line.Band {
+			dorect(doc, x-fs, y-0.3*fs-ls/2, tw, ls, t.MarkColor, t.Opacity)
+		}
+		y += ls
+	}
+	y -= ls * float64(len(lines))
+	// mono text is measured by estimate
+	cx := x
+	if len(numbers) > 0 {
+		cx += float64(len(numbers[0])+1) * fs * 0.6
+	}
+	doc.Gstyle(fmt.Sprintf("fill:%s;font-family:%s;font-size:%.2fpx", color, fontlookup("mono"), fs))
+	for i, line := range lines {
+		opacity := fmt.Sprintf("fill-opacity:%.2f", setop(t.Opacity)*line.Opacity())
+		if len(numbers) > 0 {
+			doc.Text(x, y, numbers[i], `xml:space="preserve"`, opacity+";fill:"+colors.Color(deck.Comment))
+		}
+		doc.Textspan(cx, y, "", `xml:space="preserve"`, opacity)
+		for _, tok := range line.Tokens {
+			if len(tok.Kind) > 0 && lang != "" {
+				doc.Span(tok.Text, "fill:"+colors.Color(tok.Kind))
+			} else {
+				doc.Span(tok.Text)
+			}
+		}
+		doc.TextEnd()
+		y += ls
+	}
+	doc.Gend()
+}
+
+// doqrcode draws a QR code centered at x, y, w wide with its quiet zone, as a path with a rectangle
+// for each run of dark modules, with crisp edges
+func doqrcode(doc *svg.SVG, x, y, w float64, q deck.QRCode) error {
+	modules, err := q.Modules()
+	if err != nil {
+		return err
+	}
+	m := w / float64(len(modules)+2*deck.QuietZone)
+	left, top := x-w/2+m*deck.QuietZone, y-w/2+m*deck.QuietZone
+	dorect(doc, x-w/2, y-w/2, w, w, q.Bg, q.Opacity)
+	var d strings.Builder
+	for _, r := range deck.QRRuns(modules) {
+		fmt.Fprintf(&d, "M%.2f,%.2fh%.2fv%.2fh%.2fz", left+m*float64(r.Col), top+m*float64(r.Row), m*float64(r.Len), m, -m*float64(r.Len))
+	}
+	doc.Path(d.String(), fillop(q.Color, q.Opacity)+";shape-rendering:crispEdges")
+	return nil
+}
+
+// dotextpath draws text along its path, defined with the id for the text to refer to
+func dotextpath(doc *svg.SVG, cw, ch, fs float64, t deck.TextPath, id string) {
+	var d string
+	if t.IsCurve() {
+		x1, y1, _ := dimen(cw, ch, t.Xp1, t.Yp1, 0)
+		x2, y2, _ := dimen(cw, ch, t.Xp2, t.Yp2, 0)
+		x3, y3, _ := dimen(cw, ch, t.Xp3, t.Yp3, 0)
+		d = fmt.Sprintf("M%.2f,%.2f Q%.2f,%.2f %.2f,%.2f", x1, y1, x2, y2, x3, y3)
+	} else {
+		// the arc is drawn in halves, neither of them large, clockwise if the angles decrease
+		x, y, _ := dimen(cw, ch, t.Xp, t.Yp, 0)
+		rx, ry := t.Radii(cw)
+		a1, a2 := t.Angles()
+		sweep := 0
+		if a2 < a1 {
+			sweep = 1
+		}
+		at := func(a float64) (float64, float64) {
+			a *= math.Pi / 180
+			return x + rx*math.Cos(a), y - ry*math.Sin(a)
+		}
+		sx, sy := at(a1)
+		mx, my := at((a1 + a2) / 2)
+		ex, ey := at(a2)
+		d = fmt.Sprintf("M%.2f,%.2f A%.2f,%.2f 0 0 %d %.2f,%.2f A%.2f,%.2f 0 0 %d %.2f,%.2f",
+			sx, sy, rx, ry, sweep, mx, my, rx, ry, sweep, ex, ey)
+	}
+	doc.Def()
+	doc.Path(d, attr("id", id))
+	doc.DefEnd()
+	style := fmt.Sprintf("fill:%s;fill-opacity:%.2f;font-size:%.2fpx;font-family:%s;text-anchor:%s",
+		t.Color, setop(t.Opacity), fs, fontlookup(t.Font), t.Anchor()) + decoration(doc, t.TextStyle, fs)
+	fmt.Fprintf(doc.Writer, "<text %s><textPath %s startOffset=\"%.2f%%\">%s</textPath></text>\n",
+		attr("style", style), attr("xlink:href", "#"+id), t.Offset, html.EscapeString(t.Text()))
+}
+
 // textalign returns the SVG text alignment operator
 func textalign(s string) string {
 	switch s {
@@ -273,71 +536,376 @@ func textalign(s string) string {
 	return "start"
 }
 
+// direction returns the style for right to left text, which the viewer lays out
+// from the anchor leftwards, mirroring the alignment
+func direction(s, dir string) string {
+	if deck.RTL(s, dir) {
+		return ";direction:rtl"
+	}
+	return ""
+}
+
 // showtext places fully attributed text at the specified location
-func showtext(doc *svg.SVG, x, y float64, s string, fs float64, font, color, align string) {
-	doc.Text(x, y, s, `xml:space="preserve"`, fmt.Sprintf("fill:%s;font-size:%.2fpx;font-family:%s;text-anchor:%s", color, fs, fontlookup(font), textalign(align)))
+func showtext(doc *svg.SVG, x, y float64, s string, fs float64, font, color, align, dir string, ts deck.TextStyle) {
+	doc.Text(x, y, s, `xml:space="preserve"`, fmt.Sprintf("fill:%s;font-size:%.2fpx;font-family:%s;text-anchor:%s", color, fs, fontlookup(font), textalign(align))+direction(s, dir)+decoration(doc, ts, fs))
+}
+
+// decoration returns the style of decorated text: the lines under or through it, and the spacing of its letters.
+// A highlight is a filter, defined here, that fills the box of each text element behind the text.
+func decoration(doc *svg.SVG, ts deck.TextStyle, fs float64) string {
+	var lines []string
+	if ts.Underline() {
+		lines = append(lines, "underline")
+	}
+	if ts.Strike() {
+		lines = append(lines, "line-through")
+	}
+	style := ""
+	if len(lines) > 0 {
+		style += ";text-decoration:" + strings.Join(lines, " ")
+	}
+	if ts.LetterSpacing != 0 {
+		style += fmt.Sprintf(";letter-spacing:%.2fpx", ts.Tracking(fs))
+	}
+	if ts.Highlighted() {
+		id, ok := highlights[ts.Highlight]
+		if !ok {
+			id = fmt.Sprintf("highlight-%d", len(highlights)+1)
+			highlights[ts.Highlight] = id
+			doc.Def()
+			fmt.Fprintf(doc.Writer, "<filter id=%q x=\"-0.05\" y=\"0\" width=\"1.1\" height=\"1\"><feFlood flood-color=%q/><feComposite in=\"SourceGraphic\"/></filter>\n", id, ts.Highlight)
+			doc.DefEnd()
+		}
+		style += ";filter:url(#" + id + ")"
+	}
+	return style
 }
 
 // dolists places lists on the canvas
-func dolist(doc *svg.SVG, x, y, fs, lwidth, spacing float64, tlist []deck.ListItem, font, ltype, align, color string, opacity float64) {
+func dolist(doc *svg.SVG, cw, x, y, fs, lwidth, spacing float64, tlist []deck.ListEntry, font, ltype, align, color, dir, outname string, opacity float64, assets *deck.Resolver, wrap wrapping) {
 	if font == "" {
 		font = "sans"
 	}
-	doc.Gstyle(fmt.Sprintf("fill-opacity:%.2f;fill:%s;font-family:%s;font-size:%.2fpx", setop(opacity), color, fontlookup(font), fs))
-	if ltype == "bullet" {
-		x += fs
+	// right to left lists start at the right, with bullets to the right of the text
+	var rtl string
+	if len(tlist) > 0 {
+		rtl = direction(tlist[0].ListText, dir)
+	}
+	doc.Gstyle(fmt.Sprintf("fill-opacity:%.2f;fill:%s;font-family:%s;font-size:%.2fpx", setop(opacity), color, fontlookup(font), fs) + rtl)
+	if ltype == "bullet" || ltype == "check" {
+		if rtl != "" {
+			x -= fs
+		} else {
+			x += fs
+		}
 	}
 	ls := spacing * fs
+	tw := deck.Pwidth(lwidth, cw, cw/2)
+	centered := align == "center" || align == "c"
 	var t string
-	for i, tl := range tlist {
-		if ltype == "number" {
-			t = fmt.Sprintf("%d. ", i+1) + tl.ListText
+	for _, tl := range tlist {
+		// nested levels are indented, and sized relative to the list
+		ifs, ix, iw := fs*tl.Scale, x+fs*tl.Indent, tw-fs*tl.Indent
+		if rtl != "" {
+			ix = x - fs*tl.Indent
+		}
+		ils := ls * tl.Scale
+		if len(tl.Number) > 0 {
+			t = tl.Number + " " + tl.ListText
 		} else {
 			t = tl.ListText
 		}
-		if ltype == "bullet" {
-			bullet(doc, x, y, fs, color)
+		// bullets are centered where the text's bullet would be, sized on their own
+		if len(tl.Bullet) > 0 && rtl != "" {
+			bullet(doc, tl, ix+ifs, y-ifs/3, fs*tl.BulletScale/2, color, assets, outname)
+		} else if len(tl.Bullet) > 0 {
+			bullet(doc, tl, ix-ifs, y-ifs/3, fs*tl.BulletScale/2, color, assets, outname)
 		}
 		lifmt := ""
+		if tl.Scale != 1 {
+			lifmt += fmt.Sprintf("font-size:%.2fpx", ifs)
+		}
 		if len(tl.Color) > 0 {
-			lifmt += "fill:" + tl.Color
+			lifmt += ";fill:" + tl.Color
 		}
+		ifont := font
 		if len(tl.Font) > 0 {
-			lifmt += ";font-family:" + tl.Font
+			lifmt += ";font-family:" + fontlookup(tl.Font)
+			ifont = tl.Font
 		}
-		if align == "center" || align == "c" {
+		if centered {
 			lifmt += ";text-anchor:middle"
 		}
+		lifmt += decoration(doc, tl.TextStyle, ifs)
+		link := href(tl.Link, outname)
+		if len(link) > 0 {
+			doc.Link(html.EscapeString(link), tl.ListText)
+		}
+		// items are wrapped within the list's width, as they are fitted, but for centered lists
+		wordspacing, measure := measures(ifont, ifs, tl.TextStyle)
+		var attrs []string
 		if len(lifmt) > 0 {
-			doc.Text(x, y, t, `xml:space="preserve"`, lifmt)
+			attrs = append(attrs, strings.TrimPrefix(lifmt, ";"))
+		}
+		if len(tl.Spans) > 0 {
+			spans := tl.Spans
+			if len(tl.Number) > 0 {
+				spans = append([]deck.Span{{Text: tl.Number + " "}}, spans...)
+			}
+			lines := [][]deck.Word{deck.Words(spans)}
+			if !centered {
+				lines = deck.WrapWords(lines[0], iw, wordspacing, func(word deck.Word) float64 { return measure(deck.Plain(word)) })
+			}
+			for _, line := range lines {
+				spanline(doc, ix, y, line, outname, attrs...)
+				y += ils
+			}
 		} else {
-			doc.Text(x, y, t, `xml:space="preserve"`)
+			lines := []string{t}
+			if !centered {
+				lines = wrap(t, iw, wordspacing, measure)
+			}
+			for _, line := range lines {
+				doc.Text(ix, y, line, append([]string{`xml:space="preserve"`}, attrs...)...)
+				y += ils
+			}
+		}
+		if len(link) > 0 {
+			doc.LinkEnd()
 		}
-		y += ls
 	}
 	doc.Gend()
 }
 
-// textwrap draws text at location, wrapping at the specified width
-func textwrap(doc *svg.SVG, x, y, w, fs float64, leading float64, s, font, color string, opacity float64) {
-	doc.Gstyle(fmt.Sprintf("fill-opacity:%.2f;fill:%s;font-family:%s;font-size:%.2fpx", setop(opacity), color, fontlookup(font), fs))
-	words := strings.FieldsFunc(s, whitespace)
-	xp := x
-	yp := y
+// textwrap draws text at location, wrapping at the specified width, by the wrapping function if not nil.
+// Lines of justified text, but the last, are stretched to the width.
+func textwrap(doc *svg.SVG, x, y, w, fs float64, leading float64, s, font, color, dir string, opacity float64, ts deck.TextStyle, wrapper wrapping, justify bool) {
+	doc.Gstyle(fmt.Sprintf("fill-opacity:%.2f;fill:%s;font-family:%s;font-size:%.2fpx", setop(opacity), color, fontlookup(font), fs) + direction(s, dir))
 	//fmt.Fprintf(os.Stderr, "x=%.2f y=%.2f w=%.2f fs=%.2f leading=%.2f\n", x, y, w, fs, leading)
+	// text in a declared font is wrapped by its metrics
+	if _, ok := metrics[font]; ok && wrapper == nil {
+		wrapper = deck.Wrap
+	}
+	lines := wrap(s, x+w, fs, ts)
+	if wrapper != nil {
+		wordspacing, measure := measures(font, fs, ts)
+		lines = wrapper(s, w, wordspacing, measure)
+	}
+	// each line is decorated on its own
+	for i, line := range lines {
+		if justify && i < len(lines)-1 && strings.Contains(line, " ") {
+			textline(doc, x, y+float64(i)*leading, line, fs, ts, fmt.Sprintf(`textLength="%.2f" lengthAdjust="spacing"`, w))
+			continue
+		}
+		textline(doc, x, y+float64(i)*leading, line, fs, ts)
+	}
+	doc.Gend()
+}
+
+// wrapping breaks text into lines for a width, given the space between words and the measure of words
+type wrapping func(s string, width, space float64, measure func(string) float64) []string
+
+// spanwrap draws styled text at location, wrapping at the specified width by estimate, a text element for each line.
+// Lines of justified text, but the last, are stretched to the width.
+func spanwrap(doc *svg.SVG, x, y, w, fs, leading float64, spans []deck.Span, font, color, outname string, opacity float64, ts deck.TextStyle, justify bool) {
+	doc.Gstyle(fmt.Sprintf("fill-opacity:%.2f;fill:%s;font-family:%s;font-size:%.2fpx", setop(opacity), color, fontlookup(font), fs))
+	wordspacing, measure := measures(font, fs, ts)
+	lines := deck.WrapWords(deck.Words(spans), w, wordspacing, func(word deck.Word) float64 { return measure(deck.Plain(word)) })
+	style := strings.TrimPrefix(decoration(doc, ts, fs), ";")
+	for i, line := range lines {
+		var attrs []string
+		if len(style) > 0 {
+			attrs = append(attrs, style)
+		}
+		if justify && i < len(lines)-1 && len(line) > 1 {
+			attrs = append(attrs, fmt.Sprintf(`textLength="%.2f" lengthAdjust="spacing"`, w))
+		}
+		spanline(doc, x, y+float64(i)*leading, line, outname, attrs...)
+	}
+	doc.Gend()
+}
+
+// spanline draws a line of styled words: strong text bold, emphasized text italic, code in the mono font,
+// and links underlined
+func spanline(doc *svg.SVG, x, y float64, words []deck.Word, outname string, attrs ...string) {
+	doc.Textspan(x, y, "", append(attrs, `xml:space="preserve"`)...)
+	for i, word := range words {
+		if i > 0 {
+			doc.Span(" ")
+		}
+		for _, s := range word {
+			var style []string
+			if s.Strong {
+				style = append(style, "font-weight:bold")
+			}
+			if s.Emphasis {
+				style = append(style, "font-style:italic")
+			}
+			if s.Code {
+				style = append(style, "font-family:"+fontlookup("mono"))
+			}
+			link := href(s.Link, outname)
+			if len(link) > 0 {
+				style = append(style, "text-decoration:underline")
+				fmt.Fprintf(doc.Writer, "<a %s>", attr("xlink:href", link))
+			}
+			if len(style) > 0 {
+				doc.Span(s.Text, strings.Join(style, ";"))
+			} else {
+				doc.Span(s.Text)
+			}
+			if len(link) > 0 {
+				fmt.Fprint(doc.Writer, "</a>")
+			}
+		}
+	}
+	doc.TextEnd()
+}
+
+// wrap breaks text into lines for the width, estimating the width of the letters
+func wrap(s string, w, fs float64, ts deck.TextStyle) []string {
+	var lines []string
 	var line string
-	for _, s := range words {
+	for _, s := range strings.FieldsFunc(s, whitespace) {
 		line += s + " "
-		if fs*float64(len(line))*0.65 > (w + x) {
-			doc.Text(xp, yp, line)
-			yp += leading
+		if fs*0.65*float64(len(line))+gaps(line, ts.Tracking(fs)) > w {
+			lines = append(lines, line)
 			line = ""
 		}
 	}
 	if len(line) > 0 {
-		doc.Text(xp, yp, line)
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// measures returns the space between words of text in a font, and the measure of its words.
+// Text in a font declared by the deck is measured by the font's metrics, as the other renderers measure it;
+// the viewer finds the other fonts, so their letters are estimated, at 0.65 of the size.
+func measures(font string, fs float64, ts deck.TextStyle) (float64, func(string) float64) {
+	if font == "" {
+		font = "sans"
+	}
+	tracking := ts.Tracking(fs)
+	if f, ok := metrics[font]; ok {
+		factor := 0.3
+		if font == "mono" {
+			factor = 1.0
+		}
+		face := truetype.NewFace(f, &truetype.Options{Size: fs})
+		width := func(s string) float64 { return float64(xfont.MeasureString(face, s)) / 64 }
+		return width("M") * factor, func(s string) float64 { return width(s) + gaps(s, tracking) }
+	}
+	letter := fs*0.65 + tracking
+	return letter, func(s string) float64 { return fs*0.65*float64(utf8.RuneCountInString(s)) + gaps(s, tracking) }
+}
+
+// gaps returns the tracking between the letters of text
+func gaps(s string, tracking float64) float64 {
+	if n := utf8.RuneCountInString(s); n > 1 {
+		return tracking * float64(n-1)
+	}
+	return 0
+}
+
+// textbox draws a text box at x, y (its top left corner): the box, filled, bordered, and rounded,
+// and its text, wrapped within the padding and aligned to the top, middle, or bottom.
+// Fitted text is sized to fit the box; textbox reports whether it does.
+func textbox(doc *svg.SVG, cw, ch, x, y, fs float64, tdata string, t deck.Text, wrapper wrapping) bool {
+	w, h, pad := deck.Pwidth(t.Wp, cw, cw/2), pct(t.Hp, ch), pct(t.Padding, cw)
+	_, _, smallest := dimen(cw, ch, 0, 0, t.Smallest())
+	largest := h
+	if h <= 0 {
+		largest = w
+	}
+	fs, fits := t.Size(fs, smallest, largest, func(fs float64) bool {
+		wordspacing, measure := measures(t.Font, fs, t.TextStyle)
+		lines := wrapper(tdata, w-pad*2, wordspacing, measure)
+		return t.Fits(lines, w, h, pad, fs, fs*t.Lp, wordspacing, measure)
+	})
+	leading := fs * t.Lp
+	wordspacing, measure := measures(t.Font, fs, t.TextStyle)
+	lines := wrapper(tdata, w-pad*2, wordspacing, measure)
+	h, ty := t.Box(y, h, pad, lines, fs, leading)
+	if len(t.Fill) > 0 || len(t.Border) > 0 {
+		style := "fill:none"
+		if len(t.Fill) > 0 {
+			style = fmt.Sprintf("fill:%s;fill-opacity:%.2f", t.Fill, setop(t.Opacity))
+		}
+		if len(t.Border) > 0 {
+			style += fmt.Sprintf(";stroke:%s;stroke-width:%.2f", t.Border, deck.Pwidth(t.BorderWidth, cw, pct(0.2, cw)))
+		}
+		r := pct(t.Radius, cw)
+		doc.Roundrect(x, y, w, h, r, r, style)
+	}
+	// right to left text is set against the right of the box
+	tx := x + pad
+	if deck.RTL(tdata, t.Dir) {
+		tx = x + w - pad
+	}
+	doc.Gstyle(fmt.Sprintf("fill-opacity:%.2f;fill:%s;font-family:%s;font-size:%.2fpx", setop(t.Opacity), t.Color, fontlookup(t.Font), fs) + direction(tdata, t.Dir))
+	for i, line := range lines {
+		if deck.Justified(t.Align) && i < len(lines)-1 && strings.Contains(line, " ") {
+			textline(doc, tx, ty+float64(i)*leading, line, fs, t.TextStyle, fmt.Sprintf(`textLength="%.2f" lengthAdjust="spacing"`, w-pad*2))
+			continue
+		}
+		textline(doc, tx, ty+float64(i)*leading, line, fs, t.TextStyle)
 	}
 	doc.Gend()
+	return fits
+}
+
+// fitlist returns the size of the text of a list fitted to its box, and whether the list fits
+func fitlist(cw, ch, fs float64, l deck.List, entries []deck.ListEntry) (float64, bool) {
+	w, h := deck.Pwidth(l.Wp, cw, cw/2), pct(l.Hp, ch)
+	_, _, smallest := dimen(cw, ch, 0, 0, l.Smallest())
+	largest := h
+	if h <= 0 {
+		largest = w
+	}
+	return l.Size(fs, smallest, largest, func(fs float64) bool {
+		return l.Fits(entries, w, h, fs, measures)
+	})
+}
+
+// overflow warns of text that does not fit its box, even at the smallest size
+func overflow(n int, s string) {
+	fmt.Fprintf(os.Stderr, "svgdeck: slide %d: %.40q does not fit its box at the smallest size\n", n+1, strings.TrimSpace(s))
+}
+
+// textline draws a line of wrapped text, with its attributes
+func textline(doc *svg.SVG, x, y float64, line string, fs float64, ts deck.TextStyle, attrs ...string) {
+	if style := decoration(doc, ts, fs); len(style) > 0 {
+		attrs = append(attrs, strings.TrimPrefix(style, ";"))
+	}
+	doc.Text(x, y, line, attrs...)
+}
+
+// declarefonts embeds the fonts declared by a deck, returning the font map for the deck,
+// which adds the declared fonts to the command-line mappings, their @font-face rules, and their metrics
+func declarefonts(d deck.Deck) (map[string]string, string, map[string]*truetype.Font) {
+	fm, metrics := map[string]string{}, map[string]*truetype.Font{}
+	for k, v := range fontmap {
+		fm[k] = v
+	}
+	var rules strings.Builder
+	for _, f := range d.Font {
+		if f.File == "" {
+			continue
+		}
+		data, err := d.Assets.ReadFile(f.File)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "svgdeck: font %s: %v\n", f.Name, err)
+			continue
+		}
+		fmt.Fprintf(&rules, "@font-face{font-family:%q;src:url(data:font/ttf;base64,%s) format(\"truetype\");}\n",
+			f.Name, base64.StdEncoding.EncodeToString(data))
+		fm[f.Name] = "'" + f.Name + "'"
+		if font, err := truetype.Parse(data); err == nil {
+			metrics[f.Name] = font
+		}
+	}
+	return fm, rules.String(), metrics
 }
 
 // doslides reads the deck file, making the SVG version
@@ -345,16 +913,27 @@ func doslides(outname, filename, title string, width, height float64, gp float64
 	var d deck.Deck
 	var err error
 
-	d, err = deck.Read(filename, int(width), int(height))
+	d, err = deck.Read(filename, int(width), int(height), tags...)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "svgdeck: %v\n", err)
 		return
 	}
+	// warn of the assets that could not be found or read, once the slides are made
+	defer func() {
+		for _, err := range d.Assets.Errors() {
+			fmt.Fprintf(os.Stderr, "svgdeck: %v\n", err)
+		}
+	}()
 	d.Canvas.Width = int(width)
 	d.Canvas.Height = int(height)
 
+	// the fonts declared by the deck apply only to this deck
+	defer func(fm map[string]string) { fontmap, fontfaces, metrics = fm, "", map[string]*truetype.Font{} }(fontmap)
+	fontmap, fontfaces, metrics = declarefonts(d)
+	fallbacks = d.Fallbacks()
+
 	for i := 0; i < len(d.Slide); i++ {
-		if i+1 >= begin && i+1 <= end {
+		if i+1 >= begin && i+1 <= end && (includehidden || !d.Slide[i].IsHidden()) {
 			out, err := os.Create(fmt.Sprintf(namefmt, outname, i+1))
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "svgdeck: %v\n", err)
@@ -373,19 +952,32 @@ func svgslide(doc *svg.SVG, d deck.Deck, n int, cw, ch, gp float64, outname, tit
 	}
 	var x, y, fs float64
 
-	doc.Start(cw, ch)
+	d.Canvas = d.SlideCanvas(n)
+	cw, ch = float64(d.Canvas.Width), float64(d.Canvas.Height)
+	attrs := readingorder(d, n, title)
+	if len(d.Language) > 0 {
+		attrs = append(attrs, attr("xml:lang", d.Language))
+	}
+	doc.Start(cw, ch, attrs...)
+	highlights = map[string]string{}
+	if len(d.Description) > 0 {
+		doc.Desc(d.Description)
+	}
+	if len(d.Properties()) > 0 {
+		fmt.Fprintf(doc.Writer, "<metadata>\n%s</metadata>\n", d.RDF())
+	}
+	if len(fontfaces) > 0 {
+		doc.Style("text/css", fontfaces)
+	}
 	slide := d.Slide[n]
+	// the blocks of markdown text are placed by the lines they wrap into, in their fonts
+	deck.Flow(slide.Text, slide.List, cw, ch, measures)
 
 	// insert navigation links:
-	// the full slide links to the next one in sequence,
+	// the full slide links to the next one in sequence (skipping hidden slides),
 	// the last slide links to the first
 	if len(outname) > 0 {
-		var link int
-		if n < len(d.Slide)-1 {
-			link = n + 2
-		} else {
-			link = 1
-		}
+		link := d.Next(n, includehidden) + 1
 		doc.Link(fmt.Sprintf(namefmt, outname, link), fmt.Sprintf("Link to slide %03d", link))
 	}
 	// insert title, if specified
@@ -412,7 +1004,7 @@ func svgslide(doc *svg.SVG, d deck.Deck, n int, cw, ch, gp float64, outname, tit
 		slide.Fg = "black"
 	}
 	// for every image on the slide...
-	for _, im := range slide.Image {
+	for i, im := range slide.Image {
 		x, y, _ = dimen(cw, ch, im.Xp, im.Yp, 0)
 		iw, ih := float64(im.Width), float64(im.Height)
 
@@ -428,7 +1020,12 @@ func svgslide(doc *svg.SVG, d deck.Deck, n int, cw, ch, gp float64, outname, tit
 
 		midx := iw / 2
 		midy := ih / 2
-		doc.Image(x-midx, y-midy, int(iw), int(ih), im.Name)
+		alt := im.Alt
+		if alt == "" {
+			alt = im.Caption
+		}
+		g := describe(doc, fmt.Sprintf("image-%d", i), alt, im.Title)
+		doc.Image(x-midx, y-midy, int(iw), int(ih), imageref(d.Assets, im.Name, outname))
 		if len(im.Caption) > 0 {
 			capsize := deck.Pwidth(im.Sp, float64(cw), float64(pct(2.0, cw)))
 			if im.Font == "" {
@@ -440,13 +1037,16 @@ func svgslide(doc *svg.SVG, d deck.Deck, n int, cw, ch, gp float64, outname, tit
 			if im.Align == "" {
 				im.Align = "center"
 			}
-			showtext(doc, x, y+midy+(capsize*2), im.Caption, capsize, im.Font, im.Color, im.Align)
+			showtext(doc, x, y+midy+(capsize*2), im.Caption, capsize, im.Font, im.Color, im.Align, im.Dir, im.TextStyle)
+		}
+		if g {
+			doc.Gend()
 		}
 	}
 	// every graphic on the slide
 	const defaultColor = "rgb(127,127,127)"
 	// rect
-	for _, rect := range slide.Rect {
+	for i, rect := range slide.Rect {
 		x, y, _ := dimen(cw, ch, rect.Xp, rect.Yp, 0)
 		var w, h float64
 		w = pct(rect.Wp, cw)
@@ -458,10 +1058,14 @@ func svgslide(doc *svg.SVG, d deck.Deck, n int, cw, ch, gp float64, outname, tit
 		if rect.Color == "" {
 			rect.Color = defaultColor
 		}
+		g := describe(doc, fmt.Sprintf("rect-%d", i), rect.Alt, rect.Title)
 		dorect(doc, x-(w/2), y-(h/2), w, h, rect.Color, rect.Opacity)
+		if g {
+			doc.Gend()
+		}
 	}
 	// ellipse
-	for _, ellipse := range slide.Ellipse {
+	for i, ellipse := range slide.Ellipse {
 		x, y, _ := dimen(cw, ch, ellipse.Xp, ellipse.Yp, 0)
 		var w, h float64
 		w = pct(ellipse.Wp, cw)
@@ -473,10 +1077,14 @@ func svgslide(doc *svg.SVG, d deck.Deck, n int, cw, ch, gp float64, outname, tit
 		if ellipse.Color == "" {
 			ellipse.Color = defaultColor
 		}
+		g := describe(doc, fmt.Sprintf("ellipse-%d", i), ellipse.Alt, ellipse.Title)
 		doellipse(doc, x, y, w/2, h/2, ellipse.Color, ellipse.Opacity)
+		if g {
+			doc.Gend()
+		}
 	}
 	// curve
-	for _, curve := range slide.Curve {
+	for i, curve := range slide.Curve {
 		if curve.Color == "" {
 			curve.Color = defaultColor
 		}
@@ -486,10 +1094,14 @@ func svgslide(doc *svg.SVG, d deck.Deck, n int, cw, ch, gp float64, outname, tit
 		if sw == 0 {
 			sw = 2.0
 		}
+		g := describe(doc, fmt.Sprintf("curve-%d", i), curve.Alt, curve.Title)
 		docurve(doc, x1, y1, x2, y2, x3, y3, sw, curve.Color, curve.Opacity)
+		if g {
+			doc.Gend()
+		}
 	}
 	// arc
-	for _, arc := range slide.Arc {
+	for i, arc := range slide.Arc {
 		if arc.Color == "" {
 			arc.Color = defaultColor
 		}
@@ -499,10 +1111,14 @@ func svgslide(doc *svg.SVG, d deck.Deck, n int, cw, ch, gp float64, outname, tit
 		if sw == 0 {
 			sw = 2.0
 		}
+		g := describe(doc, fmt.Sprintf("arc-%d", i), arc.Alt, arc.Title)
 		doarc(doc, x, y, w/2, h/2, arc.A1, arc.A2, sw, arc.Color, arc.Opacity)
+		if g {
+			doc.Gend()
+		}
 	}
 	// line
-	for _, line := range slide.Line {
+	for i, line := range slide.Line {
 		if line.Color == "" {
 			line.Color = defaultColor
 		}
@@ -511,17 +1127,46 @@ func svgslide(doc *svg.SVG, d deck.Deck, n int, cw, ch, gp float64, outname, tit
 		if sw == 0 {
 			sw = 2.0
 		}
+		g := describe(doc, fmt.Sprintf("line-%d", i), line.Alt, line.Title)
 		doline(doc, x1, y1, x2, y2, sw, line.Color, line.Opacity)
+		if g {
+			doc.Gend()
+		}
 	}
-	for _, poly := range slide.Polygon {
+	for i, poly := range slide.Polygon {
 		if poly.Color == "" {
 			poly.Color = defaultColor
 		}
+		g := describe(doc, fmt.Sprintf("polygon-%d", i), poly.Alt, poly.Title)
 		dopoly(doc, poly.XC, poly.YC, cw, ch, poly.Color, poly.Opacity)
+		if g {
+			doc.Gend()
+		}
+	}
+	// QR codes are read by their text alternative, or their data
+	for i, q := range slide.QRCode {
+		if q.Color == "" {
+			q.Color = "black"
+		}
+		if q.Bg == "" {
+			q.Bg = "white"
+		}
+		alt := q.Alt
+		if alt == "" {
+			alt = q.Data
+		}
+		x, y, _ := dimen(cw, ch, q.Xp, q.Yp, 0)
+		g := describe(doc, fmt.Sprintf("qrcode-%d", i), alt, q.Title)
+		if err := doqrcode(doc, x, y, pct(q.Wp, cw), q); err != nil {
+			fmt.Fprintf(os.Stderr, "svgdeck: slide %d: %v\n", n+1, err)
+		}
+		if g {
+			doc.Gend()
+		}
 	}
 	// for every text element...
 	var tdata string
-	for _, t := range slide.Text {
+	for i, t := range slide.Text {
 		if t.Color == "" {
 			t.Color = slide.Fg
 		}
@@ -529,7 +1174,7 @@ func svgslide(doc *svg.SVG, d deck.Deck, n int, cw, ch, gp float64, outname, tit
 			t.Font = "sans"
 		}
 		if t.File != "" {
-			tdata = includefile(t.File)
+			tdata = includefile(d.Assets, t.File)
 		} else {
 			tdata = t.Tdata
 		}
@@ -537,10 +1182,45 @@ func svgslide(doc *svg.SVG, d deck.Deck, n int, cw, ch, gp float64, outname, tit
 			t.Lp = linespacing
 		}
 		x, y, fs = dimen(cw, ch, t.Xp, t.Yp, t.Sp)
-		dotext(doc, cw, x, y, fs, t.Wp, t.Lp, tdata, t.Font, t.Align, t.Type, t.Color, t.Opacity)
+		doc.Gid(fmt.Sprintf("text-%d", i))
+		h := d.Assets.Hyphenator(t.Hyphenate)
+		if t.IsBox() {
+			if !textbox(doc, cw, ch, x, y, fs, tdata, t, t.Wrapper(h)) {
+				overflow(n, tdata)
+			}
+		} else if t.IsListing() {
+			docode(doc, cw, x, y, fs, tdata, t)
+		} else if t.Type == "block" && len(t.Spans) > 0 {
+			spanwrap(doc, x, y, deck.Pwidth(t.Wp, cw, cw/2), fs, fs*t.Lp, t.Spans, t.Font, t.Color, outname, t.Opacity, t.TextStyle, deck.Justified(t.Align))
+		} else {
+			// plain block text is wrapped by estimate
+			var wrapper wrapping
+			if deck.Justified(t.Align) || h != nil {
+				wrapper = t.Wrapper(h)
+			}
+			dotext(doc, cw, x, y, fs, t.Wp, t.Lp, tdata, t.Font, t.Align, t.Type, t.Color, t.Dir, t.Opacity, t.TextStyle, wrapper)
+		}
+		doc.Gend()
+	}
+	// text on paths
+	for i, t := range slide.TextPath {
+		if t.Color == "" {
+			t.Color = slide.Fg
+		}
+		if t.Font == "" {
+			t.Font = "sans"
+		}
+		if t.Sp == 0 {
+			t.Sp = pathsize
+		}
+		_, _, fs = dimen(cw, ch, 0, 0, t.Sp)
+		id := fmt.Sprintf("textpath-%d", i)
+		doc.Gid(id)
+		dotextpath(doc, cw, ch, fs, t, id+"-path")
+		doc.Gend()
 	}
 	// for every list element...
-	for _, l := range slide.List {
+	for i, l := range slide.List {
 		if l.Color == "" {
 			l.Color = slide.Fg
 		}
@@ -551,7 +1231,16 @@ func svgslide(doc *svg.SVG, d deck.Deck, n int, cw, ch, gp float64, outname, tit
 			l.Wp = listwrap
 		}
 		x, y, fs = dimen(cw, ch, l.Xp, l.Yp, l.Sp)
-		dolist(doc, x, y, fs, l.Wp, l.Lp, l.Li, l.Font, l.Type, l.Align, l.Color, l.Opacity)
+		entries := l.Entries()
+		if len(l.Fit) > 0 {
+			var fits bool
+			if fs, fits = fitlist(cw, ch, fs, l, entries); !fits {
+				overflow(n, l.Li[0].ListText)
+			}
+		}
+		doc.Gid(fmt.Sprintf("list-%d", i))
+		dolist(doc, cw, x, y, fs, l.Wp, l.Lp, entries, l.Font, l.Type, l.Align, l.Color, l.Dir, outname, l.Opacity, d.Assets, l.Wrapping())
+		doc.Gend()
 	}
 	// add a grid, if specified
 	if gp > 0 {
@@ -569,8 +1258,8 @@ func svgslide(doc *svg.SVG, d deck.Deck, n int, cw, ch, gp float64, outname, tit
 func dodeck(files []string, pw, ph float64, outdir, title string, gp float64, begin, end int) {
 	// output to individual files
 	for _, filename := range files {
-		base := strings.Split(filepath.Base(filename), ".xml")
-		outname := filepath.Join(outdir, base[0])
+		base := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(filename), deck.BundleExt), ".xml")
+		outname := filepath.Join(outdir, base)
 		doslides(outname, filename, title, pw, ph, gp, begin, end)
 	}
 }
@@ -586,6 +1275,8 @@ func main() {
 		title    = flag.String("title", "", "document title")
 		gridpct  = flag.Float64("grid", 0, "place percentage grid on each slide")
 		pr       = flag.String("pages", "1-1000000", "page range (first-last)")
+		variant  = flag.String("tags", "", "comma separated tags selecting a variant of the deck")
+		hidden   = flag.Bool("include-hidden", false, "render hidden slides")
 	)
 	flag.Parse()
 	begin, end := pagerange(*pr)
@@ -602,6 +1293,8 @@ func main() {
 		pw = (p.width * p.unit)
 		ph = (p.height * p.unit)
 	}
+	tags = deck.Tags(*variant)
+	includehidden = *hidden
 	fontmap["sans"] = *sansfont
 	fontmap["serif"] = *serifont
 	fontmap["mono"] = *monofont
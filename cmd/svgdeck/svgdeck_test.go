@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ajstarks/deck"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestDeclareFonts(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"deck.xml":  `<deck><font name="brand" file="Brand.ttf"/><font name="serif" file="Serif.ttf"/><font name="gone" file="Gone.ttf"/><slide/></deck>`,
+		"Brand.ttf": "brand",
+		"Serif.ttf": "serif",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d, err := deck.Read(filepath.Join(dir, "deck.xml"), 1024, 768)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(fm map[string]string) { fontmap, fontfaces, metrics = fm, "", map[string]*truetype.Font{} }(fontmap)
+	fontmap = map[string]string{"sans": "Helvetica", "serif": "Times-Roman", "mono": "Courier"}
+	fontmap, fontfaces, metrics = declarefonts(d)
+
+	tests := []struct {
+		name, want string
+	}{
+		{"brand", "'brand'"},     // a new name
+		{"serif", "'serif'"},     // a declaration replacing a generic font
+		{"mono", "Courier"},      // an undeclared generic font
+		{"gone", "Helvetica"},    // a declared font that cannot be read
+		{"unknown", "Helvetica"}, // an unknown name
+	}
+	for _, tc := range tests {
+		if got := fontlookup(tc.name); got != tc.want {
+			t.Errorf("fontlookup(%q) = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+	for _, name := range []string{"brand", "serif"} {
+		face := `font-family:"` + name + `";src:url(data:font/ttf;base64,` + base64.StdEncoding.EncodeToString([]byte(name))
+		if !strings.Contains(fontfaces, face) {
+			t.Errorf("no font face for %s in %q", name, fontfaces)
+		}
+	}
+	if strings.Contains(fontfaces, `"gone"`) {
+		t.Errorf("font face for an unreadable font: %q", fontfaces)
+	}
+	if len(metrics) != 0 {
+		t.Errorf("metrics for fonts that are not TrueType: %v", metrics)
+	}
+}
+
+func TestMeasures(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Go.ttf"), goregular.TTF, 0644); err != nil {
+		t.Fatal(err)
+	}
+	deckfile := filepath.Join(dir, "deck.xml")
+	if err := os.WriteFile(deckfile, []byte(`<deck><font name="go" file="Go.ttf"/><slide/></deck>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := deck.Read(deckfile, 1024, 768)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(fm map[string]string) { fontmap, fontfaces, metrics = fm, "", map[string]*truetype.Font{} }(fontmap)
+	fontmap, fontfaces, metrics = declarefonts(d)
+
+	// a declared font is measured by its metrics: narrow letters are narrower than wide ones
+	_, measure := measures("go", 20, deck.TextStyle{})
+	if narrow, wide := measure("iiii"), measure("MMMM"); narrow >= wide/2 {
+		t.Errorf("declared font: iiii measures %.2f, MMMM %.2f", narrow, wide)
+	}
+	// other fonts are estimated, every letter alike
+	space, measure := measures("sans", 20, deck.TextStyle{})
+	if got := measure("iiii"); got != 4*13 || space != 13 || measure("MMMM") != got {
+		t.Errorf("estimated font: iiii measures %.2f, MMMM %.2f, space %.2f", got, measure("MMMM"), space)
+	}
+	// tracking is added between letters, not after the last
+	_, tracked := measures("sans", 20, deck.TextStyle{LetterSpacing: 10})
+	if got := tracked("iiii"); got != 4*13+3*2 || tracked("") != 0 || tracked("i") != 13 {
+		t.Errorf("tracked: iiii measures %.2f, want %.2f", got, 4*13+3*2.0)
+	}
+	_, tracked = measures("go", 20, deck.TextStyle{LetterSpacing: 10})
+	if _, plain := measures("go", 20, deck.TextStyle{}); tracked("MMMM")-plain("MMMM") != 3*2 {
+		t.Errorf("tracked declared font: MMMM measures %.2f, untracked %.2f", tracked("MMMM"), plain("MMMM"))
+	}
+}
+
+func TestFitList(t *testing.T) {
+	l := deck.List{Fitting: deck.Fitting{Fit: deck.Fill}}
+	l.Wp, l.Hp, l.Lp = 50, 50, 1.5
+	l.Li = []deck.ListItem{{ListText: "one two three four five six seven eight"}, {ListText: "nine"}}
+	entries := l.Entries()
+	// wrapped items fill the box with larger text than items that are lines
+	fs, fits := fitlist(1000, 1000, 10, l, entries)
+	if !fits {
+		t.Fatalf("list does not fit")
+	}
+	l.Align = "center"
+	centered, _ := fitlist(1000, 1000, 10, l, entries)
+	if fs <= centered {
+		t.Errorf("wrapped list %.2f, not larger than the centered list %.2f", fs, centered)
+	}
+}
@@ -12,6 +12,8 @@ To run vgdeck, specify one or more files (marked up in deck xml) on the command
 The -slide option begins with the specified slide (slide numbers beyond the size of the deck will start at the end,
 negative slide number starts at the beginning).
 The -g (grid) option specified te scale of the x-ray grid.
+Hidden slides (hidden="true") are skipped when moving through the deck, unless the -include-hidden option is set;
+they may still be shown with -slide or search.
 The loop option pauses the specified duration between slides. If loop is not specified, then vgdeck enters
 an interactive mode using these commands:
 
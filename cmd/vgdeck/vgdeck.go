@@ -10,7 +10,6 @@ import (
 	"image"
 	_ "image/jpeg"
 	_ "image/png"
-	"io/ioutil"
 	"os"
 	"strconv"
 	"strings"
@@ -25,6 +24,9 @@ var StartTime = time.Now()
 var firstrun int
 var codemap = strings.NewReplacer("\t", "    ")
 
+// includehidden shows hidden slides in the flow of the deck
+var includehidden bool
+
 // dodeck sets up the graphics environment and kicks off the interaction
 func dodeck(filename, searchterm string, pausetime time.Duration, slidenum, cw, ch int, gp float64) {
 	firstrun = 0
@@ -70,11 +72,11 @@ func loadimage(d deck.Deck, m map[string]image.Image) {
 	mfg := "black"
 	for ns, s := range d.Slide {
 		for ni, i := range s.Image {
-			if !modfile(i.Name, StartTime) {
+			if !modfile(d.Assets.Resolve(i.Name), StartTime) {
 				continue
 			}
 			openvg.Start(w, h)
-			f, err := os.Open(i.Name)
+			f, err := d.Assets.Open(i.Name)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "%v\n", err)
 				continue
@@ -170,29 +172,23 @@ func interact(filename, searchterm string, w, h, slidenum int, gp float64) {
 				loadimage(d, imap)
 				n = slidenum
 			} else {
-				n = 0
+				n = d.Next(lastslide, includehidden)
 			}
 			showslide(d, imap, n)
 
 		// last slide
 		case '*', 5, '$': // *, Crtl-E, $
-			n = lastslide
+			n = d.Prev(0, includehidden)
 			showslide(d, imap, n)
 
 		// next slide
 		case '+', 'n', '\n', ' ', '\t', '=', 14: // +,n,newline,space,tab,equal,Crtl-N
-			n++
-			if n > lastslide {
-				n = 0
-			}
+			n = d.Next(n, includehidden)
 			showslide(d, imap, n)
 
 		// previous slide
 		case '-', 'p', 8, 16, 127: // -,p,Backspace,Ctrl-P,Del
-			n--
-			if n < 0 {
-				n = lastslide
-			}
+			n = d.Prev(n, includehidden)
 			showslide(d, imap, n)
 
 		// x-ray
@@ -214,16 +210,10 @@ func interact(filename, searchterm string, w, h, slidenum int, gp float64) {
 					openvg.End()
 
 				case '5': // back
-					n--
-					if n < 0 {
-						n = lastslide
-					}
+					n = d.Prev(n, includehidden)
 					showslide(d, imap, n)
 				case '6': // forward
-					n++
-					if n > lastslide {
-						n = 0
-					}
+					n = d.Next(n, includehidden)
 					showslide(d, imap, n)
 				}
 			}
@@ -270,6 +260,9 @@ func loop(filename string, w, h, slidenum int, n time.Duration) {
 			start = 0
 		}
 		for i := start; i < len(d.Slide); i++ {
+			if d.Slide[i].IsHidden() && !includehidden {
+				continue
+			}
 			if readcmd(r) == 'q' {
 				return
 			}
@@ -359,6 +352,45 @@ func showtext(x, y openvg.VGfloat, t, align, font string, fs openvg.VGfloat) {
 	}
 }
 
+// bullet draws the bullet of a list item: a disc, circle, square, dash, checkbox, or glyph.
+// Image bullets are drawn as discs.
+func bullet(tl deck.ListEntry, cx, cy, size openvg.VGfloat, font, color string, opacity openvg.VGfloat) {
+	if len(tl.BulletColor) > 0 {
+		color = tl.BulletColor
+	}
+	if len(tl.BulletFont) > 0 {
+		font = tl.BulletFont
+	}
+	openvg.FillColor(color, opacity)
+	switch tl.Bullet {
+	case "disc", "image":
+		openvg.Ellipse(cx, cy, size, size)
+	case "circle":
+		openvg.FillColor(color, 0)
+		openvg.StrokeColor(color, opacity)
+		openvg.StrokeWidth(size / 6)
+		openvg.Ellipse(cx, cy, size*0.85, size*0.85)
+		openvg.StrokeWidth(0)
+	case "square":
+		openvg.Rect(cx-size*0.4, cy-size*0.4, size*0.8, size*0.8)
+	case "dash":
+		openvg.Rect(cx-size/2, cy-size/8, size, size/4)
+	case "checkbox", "checked":
+		openvg.FillColor(color, 0)
+		openvg.StrokeColor(color, opacity)
+		openvg.StrokeWidth(size / 10)
+		openvg.Rect(cx-size/2, cy-size/2, size, size)
+		if tl.Bullet == "checked" {
+			openvg.StrokeWidth(size / 6)
+			openvg.Line(cx-size*0.3, cy, cx-size*0.05, cy-size*0.25)
+			openvg.Line(cx-size*0.05, cy-size*0.25, cx+size*0.35, cy+size*0.3)
+		}
+		openvg.StrokeWidth(0)
+	default: // a glyph
+		showtext(cx, cy-size, tl.Bullet, "center", font, size*2)
+	}
+}
+
 // dimen returns device dimemsion from percentages
 func dimen(d deck.Deck, x, y, s float64) (xo, yo, so openvg.VGfloat) {
 	xf, yf, sf := deck.Dimen(d.Canvas, x, y, s)
@@ -592,11 +624,6 @@ func showslide(d deck.Deck, imap map[string]image.Image, n int) {
 			l.Font = "sans"
 		}
 		x, y, fs = dimen(d, l.Xp, l.Yp, l.Sp)
-		if l.Type == "bullet" {
-			offset = 1.2 * fs
-		} else {
-			offset = 0
-		}
 		if l.Lp == 0 {
 			l.Lp = blinespacing
 		}
@@ -607,19 +634,29 @@ func showslide(d deck.Deck, imap map[string]image.Image, n int) {
 		}
 		// every list item
 		var li, lifont string
-		for ln, tl := range l.Li {
+		for _, tl := range l.Entries() {
+			// nested levels are indented, and sized relative to the list
+			ifs := fs * openvg.VGfloat(tl.Scale)
+			ix := x + fs*openvg.VGfloat(tl.Indent)
+			if len(tl.Bullet) > 0 {
+				offset = 1.2 * ifs
+				// bullets are centered where the text's bullet would be, sized on their own
+				bsize := fs * openvg.VGfloat(tl.BulletScale) / 2
+				if len(l.Color) > 0 {
+					bullet(tl, ix, y+ifs/2, bsize, l.Font, l.Color, textopacity)
+				} else {
+					bullet(tl, ix, y+ifs/2, bsize, l.Font, slide.Fg, 1)
+				}
+			} else {
+				offset = 0
+			}
 			if len(l.Color) > 0 {
 				openvg.FillColor(l.Color, textopacity)
 			} else {
 				openvg.FillColor(slide.Fg)
 			}
-			if l.Type == "bullet" {
-				boffset := fs / 2
-				openvg.Ellipse(x, y+boffset, boffset, boffset)
-				//openvg.Rect(x, y+boffset/2, boffset, boffset)
-			}
-			if l.Type == "number" {
-				li = fmt.Sprintf("%d. ", ln+1) + tl.ListText
+			if len(tl.Number) > 0 {
+				li = tl.Number + " " + tl.ListText
 			} else {
 				li = tl.ListText
 			}
@@ -631,8 +668,8 @@ func showslide(d deck.Deck, imap map[string]image.Image, n int) {
 			} else {
 				lifont = l.Font
 			}
-			showtext(x+offset, y, li, l.Align, lifont, fs)
-			y -= fs * openvg.VGfloat(l.Lp)
+			showtext(ix+offset, y, li, l.Align, lifont, ifs)
+			y -= ifs * openvg.VGfloat(l.Lp)
 		}
 	}
 	openvg.FillColor(slide.Fg)
@@ -643,7 +680,7 @@ func showslide(d deck.Deck, imap map[string]image.Image, n int) {
 	var tdata string
 	for _, t := range slide.Text {
 		if t.File != "" {
-			tdata = includefile(t.File)
+			tdata = includefile(d.Assets, t.File)
 		} else {
 			tdata = t.Tdata
 		}
@@ -659,6 +696,13 @@ func showslide(d deck.Deck, imap map[string]image.Image, n int) {
 			t.Lp = linespacing
 		}
 		x, y, fs = dimen(d, t.Xp, t.Yp, t.Sp)
+		if t.Color == "" {
+			t.Color = slide.Fg
+		}
+		if t.IsListing() {
+			docode(cw, x, y, fs, textopacity, tdata, t)
+			continue
+		}
 		td := strings.Split(tdata, "\n")
 		if t.Type == "code" {
 			ls := fs * openvg.VGfloat(t.Lp)
@@ -687,6 +731,49 @@ func showslide(d deck.Deck, imap map[string]image.Image, n int) {
 	openvg.End()
 }
 
+// docode draws code line by line on its background: highlighted by the syntax of its language, in the colors
+// of the scheme, with line numbers, and marked lines on a band, or others dimmed
+func docode(cw, x, y, fs, opacity openvg.VGfloat, tdata string, t deck.Text) {
+	lines := t.Code(codemap.Replace(tdata))
+	numbers := t.Numbers(lines)
+	colors := deck.Scheme(t.Scheme)
+	lang := deck.Lang(t.Lang)
+	if t.MarkColor == "" {
+		t.MarkColor = colors.Mark
+	}
+	size := int(fs)
+	ls := fs * openvg.VGfloat(t.Lp)
+	tw := pctwidth(t.Wp, cw, cw-x-20)
+	openvg.FillColor(colors.Background)
+	openvg.Rect(x-fs, y+fs-ls*openvg.VGfloat(len(lines)), tw, ls*openvg.VGfloat(len(lines)))
+	cx := x
+	if len(numbers) > 0 {
+		cx += openvg.TextWidth(numbers[0]+" ", "mono", size)
+	}
+	for i, line := range lines {
+		if line.Band {
+			openvg.FillColor(t.MarkColor)
+			openvg.Rect(x-fs, y+0.3*fs-ls/2, tw, ls)
+		}
+		alpha := opacity * openvg.VGfloat(line.Opacity())
+		if len(numbers) > 0 {
+			openvg.FillColor(colors.Color(deck.Comment), alpha)
+			openvg.Text(x, y, numbers[i], "mono", size)
+		}
+		xp := cx
+		for _, tok := range line.Tokens {
+			if lang != "" {
+				openvg.FillColor(colors.Color(tok.Kind), alpha)
+			} else {
+				openvg.FillColor(t.Color, alpha)
+			}
+			openvg.Text(xp, y, tok.Text, "mono", size)
+			xp += openvg.TextWidth(tok.Text, "mono", size)
+		}
+		y -= ls
+	}
+}
+
 // whitespace determines if a rune is whitespace
 func whitespace(r rune) bool {
 	return r == ' ' || r == '\n' || r == '\t' || r == '-'
@@ -715,8 +802,8 @@ func textwrap(x, y, w openvg.VGfloat, s string, font string, fs, leading, factor
 }
 
 // includefile returns the contents of a file as string
-func includefile(filename string) string {
-	data, err := ioutil.ReadFile(filename)
+func includefile(assets *deck.Resolver, filename string) string {
+	data, err := assets.ReadFile(filename)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		return ""
@@ -741,7 +828,9 @@ func main() {
 	var slidenum = flag.Int("slide", 0, "initial slide")
 	var cw = flag.Int("w", 0, "canvas width")
 	var ch = flag.Int("h", 0, "canvas height")
+	var hidden = flag.Bool("include-hidden", false, "show hidden slides in the flow of the deck")
 	flag.Parse()
+	includehidden = *hidden
 	for _, f := range flag.Args() {
 		dodeck(f, *search, *pause, *slidenum, *cw, *ch, *gridpct)
 	}
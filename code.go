@@ -0,0 +1,153 @@
+package deck
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DefaultElide is the marker of elided regions of code, when elide is on
+const DefaultElide = "ELIDE"
+
+// dimmed is the opacity of the lines of code that are not marked, when marked lines are set off by dimming
+const dimmed = 0.35
+
+// CodeLine is a line of code as shown: its tokens, its line number in the source, or 0 for the line
+// standing in for an elided region, and whether it is marked by a band, or dimmed
+type CodeLine struct {
+	Text   string
+	Number int
+	Tokens []Token
+	Band   bool
+	Dim    bool
+}
+
+// span is a range of line numbers
+type span struct {
+	from, to int
+}
+
+// lineranges parses line numbers and ranges, i.e. "7 10-12" or "5-20"; a range without an end runs to the last line
+func lineranges(s string) []span {
+	var ranges []span
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' }) {
+		from, to := f, ""
+		if i := strings.Index(f, "-"); i > 0 {
+			from, to = f[:i], f[i+1:]
+		}
+		a, err := strconv.Atoi(from)
+		if err != nil {
+			continue
+		}
+		b := a
+		if from != f {
+			if b, err = strconv.Atoi(to); err != nil {
+				b = int(^uint(0) >> 1)
+			}
+		}
+		ranges = append(ranges, span{a, b})
+	}
+	return ranges
+}
+
+// within determines if a line number is in one of the ranges
+func within(ranges []span, n int) bool {
+	for _, r := range ranges {
+		if n >= r.from && n <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsListing reports whether code text is drawn line by line from its tokens: highlighted, numbered,
+// marked, ranged or elided
+func (t Text) IsListing() bool {
+	return t.Type == "code" && (Lang(t.Lang) != "" || t.LineNumbers == "on" || t.Mark != "" || t.Lines != "" || t.Elide != "")
+}
+
+// Code returns the lines of code shown from its source: highlighted by its language, within the range of lines,
+// with each region between elide markers shown as a single line, and the marked lines banded, the others
+// dimmed, by the style of the marks (band, dim or both). The lines of code in an unknown language have
+// a single token. The empty line ending the source has no number.
+func (t Text) Code(src string) []CodeLine {
+	text := strings.Split(src, "\n")
+	tokens := Highlight(src, t.Lang)
+	first, last := 1, len(text)
+	if r := lineranges(t.Lines); len(r) > 0 {
+		if r[0].from > first {
+			first = r[0].from
+		}
+		if r[0].to < last {
+			last = r[0].to
+		}
+	}
+	marks := lineranges(t.Mark)
+	band := len(marks) > 0 && t.MarkStyle != "dim"
+	dim := len(marks) > 0 && (t.MarkStyle == "dim" || t.MarkStyle == "both")
+	marker := t.Elide
+	if marker == "on" {
+		marker = DefaultElide
+	}
+	var lines []CodeLine
+	eliding := false
+	for n := first; n <= last; n++ {
+		s := text[n-1]
+		if marker != "" && strings.Contains(s, marker) {
+			if !eliding {
+				ellipsis := s[:len(s)-len(strings.TrimLeft(s, " \t"))] + "..."
+				lines = append(lines, CodeLine{Text: ellipsis, Tokens: []Token{{Text: ellipsis, Kind: Comment}}, Dim: dim})
+			}
+			eliding = !eliding
+			continue
+		}
+		if eliding {
+			continue
+		}
+		line := CodeLine{Text: s, Number: n}
+		if n == len(text) && s == "" {
+			line.Number = 0
+		}
+		if tokens != nil {
+			line.Tokens = tokens[n-1]
+		} else if s != "" {
+			line.Tokens = []Token{{Text: s}}
+		}
+		if within(marks, n) {
+			line.Band = band
+		} else {
+			line.Dim = dim
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Numbers returns the line numbers of code lines, padded to the same width, or nil if they are not shown
+func (t Text) Numbers(lines []CodeLine) []string {
+	if t.LineNumbers != "on" {
+		return nil
+	}
+	width := 0
+	for _, l := range lines {
+		if n := len(strconv.Itoa(l.Number)); n > width {
+			width = n
+		}
+	}
+	numbers := make([]string, len(lines))
+	for i, l := range lines {
+		numbers[i] = strings.Repeat(" ", width)
+		if l.Number > 0 {
+			s := strconv.Itoa(l.Number)
+			numbers[i] = numbers[i][len(s):] + s
+		}
+	}
+	return numbers
+}
+
+// Opacity returns the opacity of a line of code, a fraction of the opacity of its text
+func (l CodeLine) Opacity() float64 {
+	if l.Dim {
+		return dimmed
+	}
+	return 1
+}
@@ -2,9 +2,11 @@
 package deck
 
 import (
+	"bytes"
 	"encoding/xml"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"strings"
 )
@@ -12,104 +14,207 @@ import (
 // Deck defines the structure of a presentation deck
 // The size of the canvas, and series of slides
 type Deck struct {
-	Title       string  `xml:"title"`
-	Creator     string  `xml:"creator"`
-	Subject     string  `xml:"subject"`
-	Publisher   string  `xml:"publisher"`
-	Description string  `xml:"description"`
-	Date        string  `xml:"date"`
-	Canvas      canvas  `xml:"canvas"`
-	Slide       []Slide `xml:"slide"`
+	Title       string    `xml:"title,omitempty"`
+	Creator     string    `xml:"creator,omitempty"`
+	Subject     string    `xml:"subject,omitempty"`
+	Publisher   string    `xml:"publisher,omitempty"`
+	Description string    `xml:"description,omitempty"`
+	Date        string    `xml:"date,omitempty"`
+	Keywords    string    `xml:"keywords,omitempty"` // comma separated
+	Language    string    `xml:"language,omitempty"` // language tag, i.e. "en" or "pt-BR"
+	License     string    `xml:"license,omitempty"`
+	Version     string    `xml:"version,omitempty"`
+	Event       string    `xml:"event,omitempty"`
+	Venue       string    `xml:"venue,omitempty"`
+	Meta        []Meta    `xml:"meta,omitempty"`
+	Canvas      canvas    `xml:"canvas,omitempty"`
+	Font        []Font    `xml:"font,omitempty"`
+	Header      Overlay   `xml:"header,omitempty"` // content drawn on every slide
+	Footer      Overlay   `xml:"footer,omitempty"`
+	Divider     *Slide    `xml:"divider,omitempty"` // style of section divider slides
+	Slide       []Slide   `xml:"slide,omitempty"`
+	Section     []Section `xml:"section,omitempty"` // sections, placed in sequence with the slides when read
+	Assets      *Resolver `xml:"-"`                 // locates images and files named in the deck
+	source      []byte    // markup of a decoded deck
+	decoded     []byte    // markup of the elements of a decoded deck, as decoded
 }
 
 type canvas struct {
-	Width  int `xml:"width,attr"`
-	Height int `xml:"height,attr"`
+	Width  int `xml:"width,attr,omitempty"`
+	Height int `xml:"height,attr,omitempty"`
+}
+
+// Font declares a TrueType font for the deck. The name may be used in font attributes,
+// or may replace one of the generic fonts (sans, serif, mono, symbol).
+// Characters missing from the font are drawn with the first of its fallback fonts that has them;
+// a declaration without a file adds fallbacks to an existing font.
+// <font name="brand" file="fonts/Brand.ttf" fallback="cjk,symbol"/>
+// <font name="cjk" file="fonts/NotoSansJP-Regular.ttf"/>
+type Font struct {
+	Name     string `xml:"name,attr,omitempty"`
+	File     string `xml:"file,attr,omitempty"`
+	Fallback string `xml:"fallback,attr,omitempty"`
 }
 
 // Slide is the structure of an individual slide within a deck
 // <slide bg="black" fg="rgb(255,255,255)" duration="2s" note="hello, world">
 // <slide gradcolor1="black" gradcolor2="white" gp="20" duration="2s" note="wassup">
+// <slide header="off" footer="off">
+// <slide only="long">
+// <slide hidden="true">
+// <slide width="612" height="792"> or <slide orientation="portrait">
 type Slide struct {
-	Bg          string    `xml:"bg,attr"`
-	Fg          string    `xml:"fg,attr"`
-	Gradcolor1  string    `xml:"gradcolor1,attr"`
-	Gradcolor2  string    `xml:"gradcolor2,attr"`
-	GradPercent float64   `xml:"gp,attr"`
-	Duration    string    `xml:"duration,attr"`
-	Note        string    `xml:"note"`
-	List        []List    `xml:"list"`
-	Text        []Text    `xml:"text"`
-	Image       []Image   `xml:"image"`
-	Ellipse     []Ellipse `xml:"ellipse"`
-	Line        []Line    `xml:"line"`
-	Rect        []Rect    `xml:"rect"`
-	Curve       []Curve   `xml:"curve"`
-	Arc         []Arc     `xml:"arc"`
-	Polygon     []Polygon `xml:"polygon"`
+	Variant
+	Bg          string     `xml:"bg,attr,omitempty"`
+	Fg          string     `xml:"fg,attr,omitempty"`
+	Gradcolor1  string     `xml:"gradcolor1,attr,omitempty"`
+	Gradcolor2  string     `xml:"gradcolor2,attr,omitempty"`
+	GradPercent float64    `xml:"gp,attr,omitempty"`
+	Duration    string     `xml:"duration,attr,omitempty"`
+	Header      string     `xml:"header,attr,omitempty"`      // "off" leaves out the deck's header
+	Footer      string     `xml:"footer,attr,omitempty"`      // "off" leaves out the deck's footer
+	Section     string     `xml:"section,attr,omitempty"`     // title of the slide's section
+	Hidden      string     `xml:"hidden,attr,omitempty"`      // "true" keeps the slide out of the flow of the deck
+	Width       int        `xml:"width,attr,omitempty"`       // canvas width of the slide, if not the deck's
+	Height      int        `xml:"height,attr,omitempty"`      // canvas height of the slide, if not the deck's
+	Orientation string     `xml:"orientation,attr,omitempty"` // portrait or landscape, turning the canvas of the slide
+	Note        string     `xml:"note,omitempty"`
+	List        []List     `xml:"list,omitempty"`
+	Text        []Text     `xml:"text,omitempty"`
+	Image       []Image    `xml:"image,omitempty"`
+	Ellipse     []Ellipse  `xml:"ellipse,omitempty"`
+	Line        []Line     `xml:"line,omitempty"`
+	Rect        []Rect     `xml:"rect,omitempty"`
+	Curve       []Curve    `xml:"curve,omitempty"`
+	Arc         []Arc      `xml:"arc,omitempty"`
+	Polygon     []Polygon  `xml:"polygon,omitempty"`
+	TextPath    []TextPath `xml:"textpath,omitempty"`
+	QRCode      []QRCode   `xml:"qrcode,omitempty"`
+	TOC         []TOC      `xml:"toc,omitempty"`
 }
 
 // CommonAttr are the common attributes for text and list
 type CommonAttr struct {
-	Xp       float64 `xml:"xp,attr"`       // X coordinate
-	Yp       float64 `xml:"yp,attr"`       // Y coordinate
-	Sp       float64 `xml:"sp,attr"`       // size
-	Lp       float64 `xml:"lp,attr"`       // linespacing (leading) percentage
-	Rotation float64 `xml:"rotation,attr"` // Rotation (0-360 degrees)
-	Type     string  `xml:"type,attr"`     // type: block, plain, code, number, bullet
-	Align    string  `xml:"align,attr"`    // alignment: center, end, begin
-	Color    string  `xml:"color,attr"`    // item color
-	Opacity  float64 `xml:"opacity,attr"`  // opacity percentage
-	Font     string  `xml:"font,attr"`     // font type: i.e. sans, serif, mono
-	Link     string  `xml:"link,attr"`     // reference to other content (i.e. http:// or mailto:)
+	Variant
+	TextStyle
+	Xp       float64 `xml:"xp,attr,omitempty"`       // X coordinate
+	Yp       float64 `xml:"yp,attr,omitempty"`       // Y coordinate
+	Sp       float64 `xml:"sp,attr,omitempty"`       // size
+	Lp       float64 `xml:"lp,attr,omitempty"`       // linespacing (leading) percentage
+	Rotation float64 `xml:"rotation,attr,omitempty"` // Rotation (0-360 degrees)
+	Type     string  `xml:"type,attr,omitempty"`     // type: block, plain, code, number, bullet
+	Align    string  `xml:"align,attr,omitempty"`    // alignment: center, end, begin
+	Color    string  `xml:"color,attr,omitempty"`    // item color
+	Opacity  float64 `xml:"opacity,attr,omitempty"`  // opacity percentage
+	Font     string  `xml:"font,attr,omitempty"`     // font type: i.e. sans, serif, mono
+	Link     string  `xml:"link,attr,omitempty"`     // reference to other content (i.e. http:// or mailto:)
+	Dir      string  `xml:"dir,attr,omitempty"`      // text direction: ltr, rtl, auto
+}
+
+// TextStyle decorates text: lines under or through it, a highlight behind it, and the spacing of its letters
+// <text decoration="underline" highlight="yellow" letterspacing="10">
+type TextStyle struct {
+	Decoration    string  `xml:"decoration,attr,omitempty"`    // underline, strike, or both; none
+	Highlight     string  `xml:"highlight,attr,omitempty"`     // color drawn behind the text
+	LetterSpacing float64 `xml:"letterspacing,attr,omitempty"` // space added between letters, percentage of the text size
 }
 
 // Dimension describes a graphics object with width and height
 type Dimension struct {
 	CommonAttr
-	Wp float64 `xml:"wp,attr"` // width percentage
-	Hp float64 `xml:"hp,attr"` // height percentage
-	Hr float64 `xml:"hr,attr"` // height relative percentage
-	Hw float64 `xml:"hw,attr"` // height by width
+	Wp    float64 `xml:"wp,attr,omitempty"`    // width percentage
+	Hp    float64 `xml:"hp,attr,omitempty"`    // height percentage
+	Hr    float64 `xml:"hr,attr,omitempty"`    // height relative percentage
+	Hw    float64 `xml:"hw,attr,omitempty"`    // height by width
+	Alt   string  `xml:"alt,attr,omitempty"`   // text alternative for screen readers
+	Title string  `xml:"title,attr,omitempty"` // short title, shown as a tooltip
 }
 
 // ListItem describes a list item
 // <list xp="20" yp="70" sp="1.5">
+//
 //	<li>canvas<li>
 //	<li>slide</li>
+//	<li level="1">nested item</li>
+//
 // </list>
 type ListItem struct {
-	Color    string  `xml:"color,attr"`
-	Opacity  float64 `xml:"opacity,attr"`
-	Font     string  `xml:"font,attr"`
+	Variant
+	TextStyle
+	Color    string  `xml:"color,attr,omitempty"`
+	Opacity  float64 `xml:"opacity,attr,omitempty"`
+	Font     string  `xml:"font,attr,omitempty"`
+	Link     string  `xml:"link,attr,omitempty"`    // URL, or "#n" for slide n of the deck
+	Level    int     `xml:"level,attr,omitempty"`   // nesting level, 0 for the top level
+	Type     string  `xml:"type,attr,omitempty"`    // bullet, number, plain; the list's type if not set
+	Bullet   string  `xml:"bullet,attr,omitempty"`  // the item's own bullet
+	Checked  string  `xml:"checked,attr,omitempty"` // "true" or "false": the item is a checked or unchecked box
+	List     []List  `xml:"list,omitempty"`         // nested lists, made items of the next level when read
 	ListText string  `xml:",chardata"`
+	Spans    []Span  `xml:"-"` // styled text of markdown items
 }
 
-// List describes the list element
+// List describes the list element. Items of nested levels use the bullets and numbers
+// of their level, i.e. <list type="bullet" bullets="disc dash" numbers="1. a) i.">
+// Items of check lists are boxes, ticked by checked="true".
 type List struct {
 	CommonAttr
-	Wp float64    `xml:"wp,attr"`
-	Li []ListItem `xml:"li"`
+	Fitting
+	Wp          float64    `xml:"wp,attr,omitempty"`
+	Hp          float64    `xml:"hp,attr,omitempty"`          // height of the box of a fitted list, percentage of the canvas height
+	Bullets     string     `xml:"bullets,attr,omitempty"`     // bullet of each level: disc, circle, square, dash, or a glyph
+	Numbers     string     `xml:"numbers,attr,omitempty"`     // numbering format of each level: 1., a), i., A., ...
+	Indent      float64    `xml:"indent,attr,omitempty"`      // indentation of each level, percentage of the canvas width
+	Scale       float64    `xml:"scale,attr,omitempty"`       // text size of each level, percentage of the level above
+	BulletFont  string     `xml:"bulletfont,attr,omitempty"`  // font of glyph bullets
+	BulletColor string     `xml:"bulletcolor,attr,omitempty"` // color of bullets, the list's color if not set
+	BulletSize  float64    `xml:"bulletsize,attr,omitempty"`  // size of bullets, percentage of the usual size
+	BulletImage string     `xml:"bulletimage,attr,omitempty"` // image drawn as the bullet
+	Li          []ListItem `xml:"li,omitempty"`
+	flow        flowing    // place of a list made from markdown text in its flow
 }
 
-// Text describes the text element
+// Text describes the text element. Block text with a height, padding, fill, border or fit is a text box,
+// placed by its top left corner, with the text wrapped within its padding:
+// <text type="block" xp="10" yp="80" wp="30" hp="20" padding="2" fill="lightyellow" border="gray" radius="1" valign="middle">
 type Text struct {
 	CommonAttr
-	Wp    float64 `xml:"wp,attr"`
-	File  string  `xml:"file,attr"`
-	Tdata string  `xml:",chardata"`
+	Fitting
+	Wp          float64 `xml:"wp,attr,omitempty"`
+	Hp          float64 `xml:"hp,attr,omitempty"`          // height of a text box, percentage of the canvas height; fits the text if not set
+	Padding     float64 `xml:"padding,attr,omitempty"`     // space between a text box and its text, percentage of the canvas width
+	Fill        string  `xml:"fill,attr,omitempty"`        // background color of a text box
+	Border      string  `xml:"border,attr,omitempty"`      // border color of a text box
+	BorderWidth float64 `xml:"borderwidth,attr,omitempty"` // border width, percentage of the canvas width
+	Radius      float64 `xml:"radius,attr,omitempty"`      // radius of the corners of a text box, percentage of the canvas width
+	VAlign      string  `xml:"valign,attr,omitempty"`      // vertical alignment of the text in a text box: top, middle, bottom
+	Hyphenate   string  `xml:"hyphenate,attr,omitempty"`   // hyphenation of block text: on (English), a language, or a dictionary file
+	Lang        string  `xml:"lang,attr,omitempty"`        // language of code, highlighted by its syntax: go, shell, json, xml, sql
+	Scheme      string  `xml:"scheme,attr,omitempty"`      // color scheme of highlighted code: light, dark, solarized, solarized-dark
+	LineNumbers string  `xml:"linenumbers,attr,omitempty"` // line numbers of code: on
+	Mark        string  `xml:"mark,attr,omitempty"`        // marked lines of code, i.e. "7 10-12"
+	MarkStyle   string  `xml:"markstyle,attr,omitempty"`   // marking of lines of code: band (the default), dim (the others), or both
+	MarkColor   string  `xml:"markcolor,attr,omitempty"`   // color of the band of marked lines, the scheme's if not set
+	Lines       string  `xml:"lines,attr,omitempty"`       // range of the lines of code shown, i.e. "5-20"
+	Elide       string  `xml:"elide,attr,omitempty"`       // elision of code between marker comments: on (ELIDE), or the marker
+	File        string  `xml:"file,attr,omitempty"`
+	Tdata       string  `xml:",chardata"`
+	Spans       []Span  `xml:"-"` // styled text made from markdown
+	flow        flowing // place of a block of markdown text in its flow
 }
 
 // Image describes an image
-// <image xp="20" yp="30" width="256" height="256" scale="50" name="picture.png" caption="Pretty picture"/>
+// <image xp="20" yp="30" width="256" height="256" scale="50" name="picture.png" caption="Pretty picture" alt="A red barn at dusk"/>
 type Image struct {
 	CommonAttr
-	Width     int     `xml:"width,attr"`     // image width
-	Height    int     `xml:"height,attr"`    // image height
-	Scale     float64 `xml:"scale,attr"`     // image scale percentage
-	Autoscale string  `xml:"autoscale,attr"` // scale the image to the canvas
-	Name      string  `xml:"name,attr"`      // image file name
-	Caption   string  `xml:"caption,attr"`   // image caption
+	Width     int     `xml:"width,attr,omitempty"`     // image width
+	Height    int     `xml:"height,attr,omitempty"`    // image height
+	Scale     float64 `xml:"scale,attr,omitempty"`     // image scale percentage
+	Autoscale string  `xml:"autoscale,attr,omitempty"` // scale the image to the canvas
+	Name      string  `xml:"name,attr,omitempty"`      // image file name
+	Caption   string  `xml:"caption,attr,omitempty"`   // image caption
+	Alt       string  `xml:"alt,attr,omitempty"`       // text alternative for screen readers
+	Title     string  `xml:"title,attr,omitempty"`     // short title, shown as a tooltip
 }
 
 // Ellipse describes a rectangle with x,y,w,h
@@ -124,31 +229,47 @@ type Rect struct {
 	Dimension
 }
 
+// QRCode describes a QR code of data, centered at xp, yp, wp wide with its quiet zone,
+// in the color (black if not set) on the background (white if not set)
+// <qrcode data="https://example.com/feedback" xp="80" yp="30" wp="15" level="Q"/>
+type QRCode struct {
+	Dimension
+	Data  string `xml:"data,attr,omitempty"`  // data encoded
+	Level string `xml:"level,attr,omitempty"` // error correction: L, M (the default), Q, H
+	Bg    string `xml:"bg,attr,omitempty"`    // background color of the code and its quiet zone
+}
+
 // Line defines a straight line
 // <line xp1="20" yp1="10" xp2="30" yp2="10"/>
 type Line struct {
-	Xp1     float64 `xml:"xp1,attr"`     // begin x coordinate
-	Yp1     float64 `xml:"yp1,attr"`     // begin y coordinate
-	Xp2     float64 `xml:"xp2,attr"`     // end x coordinate
-	Yp2     float64 `xml:"yp2,attr"`     // end y coordinate
-	Sp      float64 `xml:"sp,attr"`      // line thickness
-	Color   string  `xml:"color,attr"`   // line color
-	Opacity float64 `xml:"opacity,attr"` // line opacity (1-100)
+	Variant
+	Xp1     float64 `xml:"xp1,attr,omitempty"`     // begin x coordinate
+	Yp1     float64 `xml:"yp1,attr,omitempty"`     // begin y coordinate
+	Xp2     float64 `xml:"xp2,attr,omitempty"`     // end x coordinate
+	Yp2     float64 `xml:"yp2,attr,omitempty"`     // end y coordinate
+	Sp      float64 `xml:"sp,attr,omitempty"`      // line thickness
+	Color   string  `xml:"color,attr,omitempty"`   // line color
+	Opacity float64 `xml:"opacity,attr,omitempty"` // line opacity (1-100)
+	Alt     string  `xml:"alt,attr,omitempty"`     // text alternative for screen readers
+	Title   string  `xml:"title,attr,omitempty"`   // short title, shown as a tooltip
 }
 
 // Curve defines a quadratic Bezier curve
 // The begining, ending, and control points are required:
 // <curve xp1="60" yp1="10" xp2="75" yp2="20" xp3="70" yp3="10" />
 type Curve struct {
-	Xp1     float64 `xml:"xp1,attr"`
-	Yp1     float64 `xml:"yp1,attr"`
-	Xp2     float64 `xml:"xp2,attr"`
-	Yp2     float64 `xml:"yp2,attr"`
-	Xp3     float64 `xml:"xp3,attr"`
-	Yp3     float64 `xml:"yp3,attr"`
-	Sp      float64 `xml:"sp,attr"`
-	Color   string  `xml:"color,attr"`
-	Opacity float64 `xml:"opacity,attr"`
+	Variant
+	Xp1     float64 `xml:"xp1,attr,omitempty"`
+	Yp1     float64 `xml:"yp1,attr,omitempty"`
+	Xp2     float64 `xml:"xp2,attr,omitempty"`
+	Yp2     float64 `xml:"yp2,attr,omitempty"`
+	Xp3     float64 `xml:"xp3,attr,omitempty"`
+	Yp3     float64 `xml:"yp3,attr,omitempty"`
+	Sp      float64 `xml:"sp,attr,omitempty"`
+	Color   string  `xml:"color,attr,omitempty"`
+	Opacity float64 `xml:"opacity,attr,omitempty"`
+	Alt     string  `xml:"alt,attr,omitempty"`
+	Title   string  `xml:"title,attr,omitempty"`
 }
 
 // Arc defines an elliptical arc
@@ -156,47 +277,127 @@ type Curve struct {
 // <arc xp="55"  yp="10" wp="4" hr="75" a1="0" a2="180"/>
 type Arc struct {
 	Dimension
-	A1      float64 `xml:"a1,attr"`
-	A2      float64 `xml:"a2,attr"`
-	Sp      float64 `xml:"sp,attr"`
-	Opacity float64 `xml:"opacity,attr"`
+	A1      float64 `xml:"a1,attr,omitempty"`
+	A2      float64 `xml:"a2,attr,omitempty"`
+	Sp      float64 `xml:"sp,attr,omitempty"`
+	Opacity float64 `xml:"opacity,attr,omitempty"`
+}
+
+// TextPath describes text laid out along a path: an arc of the circle (or ellipse) centered at xp, yp,
+// with the width (and height) of the arc element, from angle a1 to a2 (around the whole circle if they are the same),
+// or a quadratic Bezier curve through the points of the curve element. The text begins at the offset, a percentage
+// of the length of the path, or is centered on it or ends at it, by its alignment.
+// <textpath xp="50" yp="50" wp="40" a1="180" a2="0" sp="3">text over an arc</textpath>
+// <textpath xp1="10" yp1="20" xp2="50" yp2="80" xp3="90" yp3="20" offset="50" align="center">text on a curve</textpath>
+type TextPath struct {
+	CommonAttr
+	Wp     float64 `xml:"wp,attr,omitempty"`  // width of the arc
+	Hp     float64 `xml:"hp,attr,omitempty"`  // height of the arc, percentage of the canvas width, the width if not set
+	A1     float64 `xml:"a1,attr,omitempty"`  // beginning angle of the arc, in degrees
+	A2     float64 `xml:"a2,attr,omitempty"`  // ending angle of the arc
+	Xp1    float64 `xml:"xp1,attr,omitempty"` // beginning of the curve
+	Yp1    float64 `xml:"yp1,attr,omitempty"`
+	Xp2    float64 `xml:"xp2,attr,omitempty"` // control point of the curve
+	Yp2    float64 `xml:"yp2,attr,omitempty"`
+	Xp3    float64 `xml:"xp3,attr,omitempty"` // end of the curve
+	Yp3    float64 `xml:"yp3,attr,omitempty"`
+	Offset float64 `xml:"offset,attr,omitempty"` // start of the text, percentage of the length of the path
+	Tdata  string  `xml:",chardata"`
 }
 
 // Polygon defines a polygon, x and y coordinates are specified by
 // strings of space-separated percentages:
 // <polygon xc="10 20 30" yc="30 40 50"/>
 type Polygon struct {
-	XC      string  `xml:"xc,attr"`
-	YC      string  `xml:"yc,attr"`
-	Color   string  `xml:"color,attr"`
-	Opacity float64 `xml:"opacity,attr"`
+	Variant
+	XC      string  `xml:"xc,attr,omitempty"`
+	YC      string  `xml:"yc,attr,omitempty"`
+	Color   string  `xml:"color,attr,omitempty"`
+	Opacity float64 `xml:"opacity,attr,omitempty"`
+	Alt     string  `xml:"alt,attr,omitempty"`
+	Title   string  `xml:"title,attr,omitempty"`
 }
 
-// ReadDeck reads the deck description file from a io.Reader
-func ReadDeck(r io.ReadCloser, w, h int) (Deck, error) {
+// ReadDeck reads the deck description file from a io.Reader.
+// With tags, the deck is cut to the content selected by the tags (see Variant).
+func ReadDeck(r io.ReadCloser, w, h int, tags ...string) (Deck, error) {
+	return readdeck(r, w, h, NewResolver(""), tags)
+}
+
+// readdeck reads a deck whose assets, such as the files of markdown text, are located by the resolver
+func readdeck(r io.ReadCloser, w, h int, assets *Resolver, tags []string) (Deck, error) {
 	var d Deck
-	err := xml.NewDecoder(r).Decode(&d)
+	data, err := ioutil.ReadAll(r)
+	if err == nil {
+		err = xml.NewDecoder(bytes.NewReader(data)).Decode(&d)
+	}
+	if err == nil {
+		err = d.sections(data, tags)
+	}
 	if d.Canvas.Width == 0 {
 		d.Canvas.Width = w
 	}
 	if d.Canvas.Height == 0 {
 		d.Canvas.Height = h
 	}
+	d.Assets = assets
+	if err == nil {
+		d.nest()
+		d.cut(tags)
+		d.markdown()
+		d.contents()
+		d.expand()
+	}
 	r.Close()
 	return d, err
 }
 
-// Read reads the deck description file
-func Read(filename string, w, h int) (Deck, error) {
+// Read reads the deck description file, resolving its assets relative to the file's directory.
+// Bundles are read with ReadBundle.
+func Read(filename string, w, h int, tags ...string) (Deck, error) {
 	var d Deck
 	if filename == "-" {
-		return ReadDeck(os.Stdin, w, h)
+		return ReadDeck(os.Stdin, w, h, tags...)
+	}
+	if IsBundle(filename) {
+		return ReadBundle(filename, w, h, tags...)
 	}
 	r, err := os.Open(filename)
 	if err != nil {
 		return d, err
 	}
-	return ReadDeck(r, w, h)
+	return readdeck(r, w, h, NewResolver(filename), tags)
+}
+
+// SlideCanvas returns the canvas of slide n: the deck's canvas, with the slide's width and height
+// if it has them, turned to the slide's orientation. Percentages on the slide refer to this canvas.
+func (d Deck) SlideCanvas(n int) canvas {
+	if n < 0 || n >= len(d.Slide) {
+		return d.Canvas
+	}
+	return d.canvas(d.Slide[n])
+}
+
+// canvas returns the canvas of a slide of the deck
+func (d Deck) canvas(s Slide) canvas {
+	c := d.Canvas
+	if s.Width > 0 {
+		c.Width = s.Width
+	}
+	if s.Height > 0 {
+		c.Height = s.Height
+	}
+	switch s.Orientation {
+	case "portrait":
+		if c.Width > c.Height {
+			c.Width, c.Height = c.Height, c.Width
+		}
+	case "landscape":
+		if c.Height > c.Width {
+			c.Width, c.Height = c.Height, c.Width
+		}
+	}
+	return c
 }
 
 // Dimen computes the coordinates and size of an object
@@ -233,10 +434,44 @@ func Search(d Deck, s string) int {
 				return i
 			}
 		}
+		for _, t := range slide.TextPath {
+			if strings.Contains(t.Tdata, s) {
+				return i
+			}
+		}
 	}
 	return -1
 }
 
+// IsHidden reports whether the slide is hidden: kept in the deck, but out of its flow
+func (s Slide) IsHidden() bool {
+	return on(s.Hidden)
+}
+
+// Next returns the slide after slide n in the flow of the deck, the last slide leading to the first.
+// Hidden slides are skipped, unless all is set. If all slides are hidden, n is returned.
+func (d Deck) Next(n int, all bool) int {
+	return d.step(n, 1, all)
+}
+
+// Prev returns the slide before slide n in the flow of the deck, the first slide leading to the last.
+// Hidden slides are skipped, unless all is set. If all slides are hidden, n is returned.
+func (d Deck) Prev(n int, all bool) int {
+	return d.step(n, -1, all)
+}
+
+// step moves through the slides of the deck in the direction given by the sign of dir
+func (d Deck) step(n, dir int, all bool) int {
+	ns := len(d.Slide)
+	for i := 1; i <= ns; i++ {
+		m := ((n+dir*i)%ns + ns) % ns
+		if all || !d.Slide[m].IsHidden() {
+			return m
+		}
+	}
+	return n
+}
+
 // Dump shows the decoded description
 func Dump(d Deck) {
 	fmt.Printf("Title: %#v\nCreator: %#v\nDescription: %#v\nDate: %#v\nPublisher: %#v\nSubject: %#v\n",
@@ -271,5 +506,11 @@ func Dump(d Deck) {
 		for p, polygon := range s.Polygon {
 			fmt.Printf("\tPolygon [%d] = %+v\n", p, polygon)
 		}
+		for p, tp := range s.TextPath {
+			fmt.Printf("\tTextPath [%d] = %+v\n", p, tp)
+		}
+		for q, qr := range s.QRCode {
+			fmt.Printf("\tQRCode [%d] = %+v\n", q, qr)
+		}
 	}
 }
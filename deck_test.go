@@ -0,0 +1,1088 @@
+package deck
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	lib := t.TempDir()
+	for _, f := range []string{filepath.Join(dir, "local.png"), filepath.Join(lib, "shared.png")} {
+		if err := os.WriteFile(f, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := NewResolver(filepath.Join(dir, "deck.xml"))
+	r.Path = []string{lib}
+
+	tests := []struct {
+		name, want string
+	}{
+		{"local.png", filepath.Join(dir, "local.png")},
+		{"shared.png", filepath.Join(lib, "shared.png")},
+		{"missing.png", "missing.png"},
+		{"http://example.com/a.png", "http://example.com/a.png"},
+	}
+	for _, tc := range tests {
+		if got := r.Resolve(tc.name); got != tc.want {
+			t.Errorf("Resolve(%q) = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+	if m := r.Missing(); len(m) != 1 || m[0] != "missing.png" {
+		t.Errorf("Missing() = %v", m)
+	}
+	if h := r.Hyphenator("missing.dic"); h != nil {
+		t.Errorf("Hyphenator(missing.dic) = %v, want nil", h)
+	}
+	errs := r.Errors()
+	if len(errs) != 2 || !strings.Contains(errs[0].Error(), `"missing.dic"`) || !strings.Contains(errs[1].Error(), `"missing.png"`) {
+		t.Errorf("Errors() = %v", errs)
+	}
+}
+
+func TestReadBundle(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "talk"+BundleExt)
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	files := map[string]string{
+		"deck.xml":          `<deck><font name="brand" file="fonts/Charter.ttf"/><slide><image name="images/a.png"/></slide><slide/></deck>`,
+		"images/a.png":      "png",
+		"fonts/Charter.ttf": "ttf",
+	}
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	f.Close()
+
+	d, err := Read(filename, 1024, 768)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Slide) != 2 {
+		t.Errorf("got %d slides, want 2", len(d.Slide))
+	}
+	data, err := d.Assets.ReadFile(d.Slide[0].Image[0].Name)
+	if err != nil || string(data) != "png" {
+		t.Errorf("ReadFile = %q, %v", data, err)
+	}
+	if len(d.Font) != 1 || d.Font[0].Name != "brand" {
+		t.Errorf("fonts = %v", d.Font)
+	}
+	if _, ok := d.Assets.Font("Charter.ttf"); !ok {
+		t.Error("bundled font not found")
+	}
+	if _, ok := d.Assets.Font("Helvetica.ttf"); ok {
+		t.Error("found a font not in the bundle")
+	}
+}
+
+func TestFont(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "fonts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"deck.xml":        `<deck><font name="brand" file="fonts/Brand.ttf"/><font name="sans" file="fonts/Sans.ttf"/><slide><text font="brand">hi</text></slide></deck>`,
+		"fonts/Brand.ttf": "brand",
+		"fonts/Sans.ttf":  "sans",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d, err := Read(filepath.Join(dir, "deck.xml"), 1024, 768)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Font{{Name: "brand", File: "fonts/Brand.ttf"}, {Name: "sans", File: "fonts/Sans.ttf"}}
+	if !reflect.DeepEqual(d.Font, want) {
+		t.Errorf("fonts = %v, want %v", d.Font, want)
+	}
+	// font files are found relative to the deck, wherever it is read from
+	for _, f := range d.Font {
+		data, err := d.Assets.ReadFile(f.File)
+		if err != nil || string(data) != f.Name {
+			t.Errorf("font %s: ReadFile(%q) = %q, %v", f.Name, f.File, data, err)
+		}
+	}
+	if d.Slide[0].Text[0].Font != "brand" {
+		t.Errorf("text font = %q", d.Slide[0].Text[0].Font)
+	}
+}
+
+func TestRuns(t *testing.T) {
+	upper := func(r rune) bool { return r >= 'A' && r <= 'Z' }
+	runs := Runs("AB cd日E", []func(rune) bool{upper, nil})
+	want := []Run{{"AB ", 0}, {"cd日", 1}, {"E", 0}}
+	if len(runs) != len(want) {
+		t.Fatalf("Runs = %v, want %v", runs, want)
+	}
+	for i := range want {
+		if runs[i] != want[i] {
+			t.Errorf("run %d = %v, want %v", i, runs[i], want[i])
+		}
+	}
+}
+
+func TestVisual(t *testing.T) {
+	tests := []struct {
+		s    string
+		rtl  bool
+		want string
+	}{
+		{"plain text", false, "plain text"},
+		{"one שלום two", false, "one םולש two"},
+		{"שלום world", true, "world םולש"},
+		{"(שלום)", true, "(םולש)"},
+		{"שלום 123", false, "123 םולש"},
+		{"שלום world", false, "םולש world"},
+		{"שלום (abc) עולם", true, "םלוע (abc) םולש"},
+		{"a (ש) b", false, "a (ש) b"},
+		{"abc שלום 123 עולם def", false, "abc םלוע 123 םולש def"},
+		{"שלום 12% (x)", false, "12% םולש (x)"},
+		{"see \u2067שלום world\u2069 now", false, "see world םולש now"},
+		{"abc \u202bשלום def\u202c ghi", false, "abc def םולש ghi"},
+		{"\u2066abc שלום\u2069 עולם", true, "םלוע abc םולש"},
+		{"x \u202e(abc)\u202c y", false, "x (cba) y"},
+		{"one \u200fשלום\u200e two", false, "one םולש two"},
+	}
+	for _, tc := range tests {
+		if got := Visual(tc.s, tc.rtl); got != tc.want {
+			t.Errorf("Visual(%q, %v) = %q, want %q", tc.s, tc.rtl, got, tc.want)
+		}
+	}
+	if RTL("hello", "auto") || !RTL("123 שלום", "auto") || !RTL("hello", "rtl") {
+		t.Error("RTL direction")
+	}
+	if Align("end", true) != "left" || Align("", true) != "right" || Align("c", true) != "c" {
+		t.Error("Align mirroring")
+	}
+}
+
+func TestWrap(t *testing.T) {
+	measure := func(s string) float64 { return float64(len(s)) }
+	lines := Wrap("a bb\nccc", 3, 1, measure)
+	want := []string{"a bb", "ccc", ""}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("Wrap = %q, want %q", lines, want)
+	}
+}
+
+func TestReadingOrder(t *testing.T) {
+	d, err := ReadDeck(io.NopCloser(strings.NewReader(`<deck><slide>
+<rect xp="50" yp="20" wp="10" hp="5"/>
+<text xp="60" yp="50">right</text>
+<image xp="10" yp="50" name="a.png" caption="Figure" />
+<rect xp="30" yp="20" wp="10" hp="5" alt="Bar" title="Sales"/>
+<text xp="10" yp="90">Title</text>
+<list xp="10" yp="30"><li>one</li><li>two</li></list>
+</slide></deck>`)), 100, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, it := range d.ReadingOrder(0) {
+		got = append(got, it.Kind+":"+it.Label())
+	}
+	want := "text:Title image:Figure text:right list:one rect:Sales"
+	if strings.Join(got, " ") != want {
+		t.Errorf("ReadingOrder = %q, want %q", strings.Join(got, " "), want)
+	}
+}
+
+func TestContrast(t *testing.T) {
+	white, _ := ParseColor("white")
+	gray, ok := ParseColor("#ccc")
+	if !ok || gray != (RGB{204, 204, 204}) {
+		t.Fatalf("ParseColor(#ccc) = %v, %v", gray, ok)
+	}
+	if c := Contrast(RGB{}, white); c < 20.99 || c > 21.01 {
+		t.Errorf("black on white contrast = %v, want 21", c)
+	}
+	if c := Contrast(gray, white); c > 1.7 {
+		t.Errorf("gray on white contrast = %v", c)
+	}
+}
+
+func TestLint(t *testing.T) {
+	d, err := ReadDeck(io.NopCloser(strings.NewReader(`<deck><slide bg="white">
+<text xp="10" yp="90" sp="4" color="lightgray">faint</text>
+<text xp="10" yp="50" sp="0.8">tiny</text>
+<text xp="10" yp="50.5" sp="2">overlap</text>
+<text xp="95" yp="20" sp="3">off the edge</text>
+<rect xp="50" yp="10" wp="40" hp="10" color="navy"/>
+<text xp="45" yp="9" sp="2" color="white">on navy</text>
+<text type="block" xp="10" yp="40" wp="40" hp="20" padding="2" sp="2" fill="navy" color="white">callout</text>
+<text xp="20" yp="25" sp="2">in the box</text>
+</slide></deck>`)), 792, 612)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range Lint(d, DefaultLimits) {
+		got = append(got, p.Element+" "+p.Kind)
+	}
+	// the text box is a background, placed by its top left corner
+	want := "text 0 contrast|text 1 size|text 1 overlap|text 3 outside|text 5 overlap|text 6 contrast"
+	if strings.Join(got, "|") != want {
+		t.Errorf("Lint = %q, want %q", strings.Join(got, "|"), want)
+	}
+	d.Slide[0].Text = append(d.Slide[0].Text, Text{Tdata: strings.Repeat("word ", 80)})
+	if p := Lint(d, DefaultLimits); p[0].Kind != "words" {
+		t.Errorf("word budget not reported: %v", p)
+	}
+}
+
+func TestProperties(t *testing.T) {
+	d, err := ReadDeck(io.NopCloser(strings.NewReader(`<deck><title>Go &amp; Decks</title><keywords>go, slides,,</keywords>
+<language>en</language><license>CC-BY-4.0</license><event>GopherCon</event>
+<meta name="recording" value="https://example.com/v?a=1&amp;b=2"/></deck>`)), 100, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range d.Properties() {
+		got = append(got, p.Name+"="+p.Value)
+	}
+	want := "title=Go & Decks|keywords=go, slides,,|language=en|license=CC-BY-4.0|event=GopherCon|recording=https://example.com/v?a=1&b=2"
+	if strings.Join(got, "|") != want {
+		t.Errorf("Properties = %q, want %q", strings.Join(got, "|"), want)
+	}
+	if kw := d.KeywordList(); len(kw) != 2 || kw[1] != "slides" {
+		t.Errorf("KeywordList = %q", kw)
+	}
+	// the packet is well formed XML
+	dec := xml.NewDecoder(bytes.NewReader(d.XMP()))
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("XMP: %v", err)
+		}
+	}
+	if !bytes.Contains(d.XMP(), []byte("<dc:rights><rdf:Alt><rdf:li xml:lang=\"x-default\">CC-BY-4.0</rdf:li>")) {
+		t.Errorf("XMP missing rights:\n%s", d.XMP())
+	}
+}
+
+func TestExpand(t *testing.T) {
+	d, err := ReadDeck(io.NopCloser(strings.NewReader(`<deck><title>Talk</title>
+<meta name="event" value="GopherCon"/>
+<footer><text>{slide} / {slides}</text><list><li>{title}</li></list></footer>
+<slide><text>{title} at {event}</text><text type="code">{slide}</text></slide>
+<slide footer="off"><text>{unknown} {slide}</text></slide>
+<slide hidden="true" footer="off"><text>{slide}</text></slide>
+<slide hidden="true" footer="off"><text>{slide}</text></slide>
+<slide/>
+</deck>`)), 100, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range d.Slide {
+		for _, t := range s.Text {
+			got = append(got, t.Tdata)
+		}
+		for _, l := range s.List {
+			got = append(got, l.Li[0].ListText)
+		}
+	}
+	want := "Talk at GopherCon|{slide}|1 / 3|Talk|{unknown} 2|2a|2b|3 / 3|Talk"
+	if strings.Join(got, "|") != want {
+		t.Errorf("expanded = %q, want %q", strings.Join(got, "|"), want)
+	}
+}
+
+func TestSections(t *testing.T) {
+	d, err := ReadDeck(io.NopCloser(strings.NewReader(`<deck>
+<divider><text>Part: {section}</text></divider>
+<footer><text>{slide}</text></footer>
+<slide><toc/></slide>
+<section title="Intro" divider="on"><slide><text>a</text></slide><slide><text>b</text></slide></section>
+<slide><text>c</text></slide>
+<section title="Details"><slide><text>d</text></slide></section>
+</deck>`)), 100, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range d.Slide {
+		var text []string
+		for _, t := range s.Text {
+			text = append(text, t.Tdata)
+		}
+		got = append(got, s.Section+":"+strings.Join(text, ","))
+	}
+	want := ":1|Intro:Part: Intro,2|Intro:a,3|Intro:b,4|:c,5|Details:d,6"
+	if strings.Join(got, "|") != want {
+		t.Errorf("slides = %q, want %q", strings.Join(got, "|"), want)
+	}
+	sr := d.Sections()
+	if len(sr) != 2 || sr[0] != (SectionRange{"Intro", 1, 3}) || sr[1] != (SectionRange{"Details", 5, 5}) {
+		t.Errorf("sections = %v", sr)
+	}
+	if len(d.Slide[0].List) != 1 {
+		t.Fatalf("table of contents not expanded")
+	}
+	li := d.Slide[0].List[0].Li
+	if len(li) != 2 || li[0].ListText != "Intro" || li[0].Link != "#2" || li[1].Link != "#6" {
+		t.Errorf("table of contents = %v", li)
+	}
+	if n, ok := SlideLink(li[1].Link); !ok || n != 6 {
+		t.Errorf("SlideLink(%q) = %d, %v", li[1].Link, n, ok)
+	}
+}
+
+func TestVariant(t *testing.T) {
+	src := `<deck>
+<footer><text except="public">{slide} / {slides}</text></footer>
+<slide><text>a</text><text only="long">b</text><list><li>c</li><li only="long,workshop">d</li></list></slide>
+<slide only="long"><text>e</text></slide>
+<section title="Q&amp;A" except="short"><slide><text>f</text></slide></section>
+</deck>`
+	for _, test := range []struct {
+		tags []string
+		want string
+	}{
+		{nil, "a,b,1 / 3,c,d|e,2 / 3|f,3 / 3"},
+		{[]string{"short"}, "a,1 / 1,c"},
+		{[]string{"long", "public"}, "a,b,c,d|e|f"},
+		{[]string{"workshop"}, "a,1 / 2,c,d|f,2 / 2"},
+	} {
+		d, err := ReadDeck(io.NopCloser(strings.NewReader(src)), 100, 100, test.tags...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var slides []string
+		for _, s := range d.Slide {
+			var content []string
+			for _, t := range s.Text {
+				content = append(content, t.Tdata)
+			}
+			for _, l := range s.List {
+				for _, li := range l.Li {
+					content = append(content, li.ListText)
+				}
+			}
+			slides = append(slides, strings.Join(content, ","))
+		}
+		if got := strings.Join(slides, "|"); got != test.want {
+			t.Errorf("tags %v: %q, want %q", test.tags, got, test.want)
+		}
+	}
+	// a section whose slides are all left out is left out with its divider, and out of the table of contents
+	src = `<deck><slide><toc/></slide>
+<section title="Long only" divider="on"><slide only="long"><text>g</text></slide></section>
+<section title="All" divider="on"><slide><text>h</text></slide></section>
+</deck>`
+	for _, test := range []struct {
+		tags []string
+		want string
+	}{
+		{nil, "Long only #2,All #4|Long only|g|All|h"},
+		{[]string{"short"}, "All #2|All|h"},
+	} {
+		d, err := ReadDeck(io.NopCloser(strings.NewReader(src)), 100, 100, test.tags...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var slides []string
+		for _, s := range d.Slide {
+			var content []string
+			for _, t := range s.Text {
+				content = append(content, t.Tdata)
+			}
+			for _, l := range s.List {
+				for _, li := range l.Li {
+					content = append(content, li.ListText+" "+li.Link)
+				}
+			}
+			slides = append(slides, strings.Join(content, ","))
+		}
+		if got := strings.Join(slides, "|"); got != test.want {
+			t.Errorf("sections, tags %v: %q, want %q", test.tags, got, test.want)
+		}
+	}
+	if tags := Tags(" long, ,public"); len(tags) != 2 || tags[0] != "long" || tags[1] != "public" {
+		t.Errorf("Tags = %q", tags)
+	}
+}
+
+func TestNext(t *testing.T) {
+	d := Deck{Slide: []Slide{{}, {Hidden: "true"}, {}, {Hidden: "true"}}}
+	for _, test := range []struct {
+		n, next, prev int
+		all           bool
+	}{
+		{0, 2, 2, false},
+		{2, 0, 0, false},
+		{1, 2, 0, false},
+		{0, 1, 3, true},
+		{3, 0, 2, true},
+	} {
+		if got := d.Next(test.n, test.all); got != test.next {
+			t.Errorf("Next(%d, %v) = %d, want %d", test.n, test.all, got, test.next)
+		}
+		if got := d.Prev(test.n, test.all); got != test.prev {
+			t.Errorf("Prev(%d, %v) = %d, want %d", test.n, test.all, got, test.prev)
+		}
+	}
+	if got := (Deck{Slide: []Slide{{Hidden: "true"}}}).Next(0, false); got != 0 {
+		t.Errorf("Next with all slides hidden = %d, want 0", got)
+	}
+}
+
+func TestSlideCanvas(t *testing.T) {
+	d := Deck{Canvas: canvas{792, 612}, Slide: []Slide{
+		{},
+		{Orientation: "portrait"},
+		{Width: 1584},
+		{Width: 400, Height: 600, Orientation: "landscape"},
+	}}
+	want := []canvas{{792, 612}, {612, 792}, {1584, 612}, {600, 400}}
+	for i, c := range want {
+		if got := d.SlideCanvas(i); got != c {
+			t.Errorf("SlideCanvas(%d) = %v, want %v", i, got, c)
+		}
+	}
+	if got := d.SlideCanvas(10); got != d.Canvas {
+		t.Errorf("SlideCanvas(10) = %v, want the deck's canvas", got)
+	}
+}
+
+func TestReflow(t *testing.T) {
+	d := Deck{Canvas: canvas{1000, 750}, Slide: []Slide{{
+		Text: []Text{{CommonAttr: CommonAttr{Xp: 10, Yp: 80, Sp: 4}}},
+		Rect: []Rect{{Dimension{CommonAttr: CommonAttr{Xp: 50, Yp: 50}, Wp: 30, Hp: 40}}},
+		Line: []Line{{Xp1: 40, Yp1: 20, Xp2: 60, Yp2: 20}},
+	}}}
+	for _, test := range []struct {
+		mode string
+		want string
+	}{
+		{Stretch, "text 10 80 4|rect 50 50 30x40|line 40 20 60 20"},
+		{Letterbox, "text 18 80 3.2|rect 50 50 24x40|line 42 20 58 20"},
+		{Smart, "text 10 80 3.2|rect 50 50 24x40|line 42 20 58 20"},
+	} {
+		r, err := Reflow(d, 1000, 600, test.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := r.Slide[0]
+		got := fmt.Sprintf("text %v %v %v|rect %v %v %vx%v|line %v %v %v %v",
+			s.Text[0].Xp, s.Text[0].Yp, s.Text[0].Sp,
+			s.Rect[0].Xp, s.Rect[0].Yp, s.Rect[0].Wp, s.Rect[0].Hp,
+			s.Line[0].Xp1, s.Line[0].Yp1, s.Line[0].Xp2, s.Line[0].Yp2)
+		if got != test.want {
+			t.Errorf("%s: %s, want %s", test.mode, got, test.want)
+		}
+	}
+	if d.Slide[0].Text[0].Sp != 4 {
+		t.Errorf("Reflow changed the original deck")
+	}
+	if _, err := Reflow(d, 1000, 600, "squash"); err == nil {
+		t.Errorf("Reflow accepted an unknown mode")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	dir := t.TempDir()
+	src, out := filepath.Join(dir, "talk"), filepath.Join(dir, "wide")
+	for _, d := range []string{src, out} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		"deck.xml": `<deck><canvas width="1000" height="750"/>
+<footer><text xp="95" yp="3" sp="1">{slide} / {slides}</text></footer>
+<slide><toc xp="10" yp="80" sp="2"/><image xp="50" yp="50" width="10" height="10" name="a.png"/></slide>
+<section title="One"><slide><text xp="10" yp="80" sp="2" type="markdown">*hi*</text></slide></section>
+<slide><text xp="10" yp="80" sp="2" file="notes.txt"/><image xp="50" yp="50" width="10" height="10" name="elsewhere.png"/></slide>
+</deck>`,
+		"a.png":     "png",
+		"notes.txt": "notes",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d, err := Decode(filepath.Join(src, "deck.xml"), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Slide) != 2 || len(d.Section) != 1 || len(d.Slide[0].Text) != 0 || len(d.Slide[0].TOC) != 1 {
+		t.Fatalf("decoded %d slides, %d sections, %d texts, %d tocs", len(d.Slide), len(d.Section), len(d.Slide[0].Text), len(d.Slide[0].TOC))
+	}
+	d, err = Reflow(d, 1000, 600, Smart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Rebase(src, out)
+	var b bytes.Buffer
+	if err := d.Encode(&b); err != nil {
+		t.Fatal(err)
+	}
+	xml := b.String()
+	for _, want := range []string{"{slide} / {slides}", "<toc ", `type="markdown">*hi*<`, `name="../talk/a.png"`, `file="../talk/notes.txt"`, `name="elsewhere.png"`, `height="600"`} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("encoded deck lacks %s:\n%s", want, xml)
+		}
+	}
+	if a, b, c := strings.Index(xml, "a.png"), strings.Index(xml, "<section"), strings.Index(xml, "notes.txt"); !(a < b && b < c) {
+		t.Errorf("slides and sections out of order:\n%s", xml)
+	}
+	// read again, the deck has one footer on each slide
+	r, err := ReadDeck(io.NopCloser(&b), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Slide) != 3 || len(r.Slide[2].Text) != 2 || r.Slide[2].Text[1].Tdata != "3 / 3" {
+		t.Errorf("read %d slides, last with texts %v", len(r.Slide), r.Slide[len(r.Slide)-1].Text)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	src := `<?xml version="1.0" encoding="UTF-8"?>
+<!-- talk for the meetup -->
+<deck>
+  <slide bg="white">
+    <!-- speaker: slow down -->
+    <image xp="50" yp="50" width="10" height="10" name="a.png"/>
+    <text xp="10" yp="80" sp="2">Title &amp; more</text>
+    <list xp="10.0" yp="50" sp="2"><li>one<list><li>two</li></list></li></list>
+  </slide>
+</deck>
+`
+	file := filepath.Join(t.TempDir(), "deck.xml")
+	if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := Decode(file, 1000, 750)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a deck that is not changed is written as it was
+	var b bytes.Buffer
+	if err := d.Encode(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != src {
+		t.Errorf("unchanged deck rewritten:\n%s", b.String())
+	}
+	// a changed deck has only the changes rewritten
+	if d, err = Reflow(d, 1000, 600, Smart); err != nil {
+		t.Fatal(err)
+	}
+	b.Reset()
+	if err := d.Encode(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{"<!-- talk for the meetup -->\n<deck>\n  <slide bg=\"white\">\n    <!-- speaker: slow down -->\n    <image ",
+		`<text xp="10" yp="80" sp="`, `">Title &amp; more</text>`, `<list xp="10.0" yp="50" sp="`, "><li>one<list><li>two</li></list></li></list>\n  </slide>\n",
+		`<canvas width="1000" height="600">`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("reflowed deck lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "<header") || strings.Contains(out, "<footer") {
+		t.Errorf("reflowed deck has an empty header or footer:\n%s", out)
+	}
+	// a deck that was not decoded is written afresh, without an empty header or footer
+	b.Reset()
+	if err := (Deck{Slide: []Slide{{Bg: "white"}}}).Encode(&b); err != nil {
+		t.Fatal(err)
+	}
+	if out := b.String(); !strings.Contains(out, `<slide bg="white">`) || strings.Contains(out, "<header") || strings.Contains(out, "<footer") {
+		t.Errorf("new deck encoded as:\n%s", out)
+	}
+}
+
+func TestEntries(t *testing.T) {
+	d, err := ReadDeck(io.NopCloser(strings.NewReader(`<deck><slide>
+<list type="number">
+<li>one
+  <list type="number"><li>a</li><li>b<list type="bullet"><li>dot</li></list></li></list>
+</li>
+<li>two</li>
+<li level="1">c</li>
+</list>
+</slide></deck>`)), 100, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range d.Slide[0].List[0].Entries() {
+		got = append(got, fmt.Sprintf("%s%s%s@%g,%.4g", e.Number, e.Bullet, e.ListText, e.Indent, e.Scale))
+	}
+	want := "1.one@0,1 a)a@2,0.85 b)b@2,0.85 squaredot@4,0.7225 2.two@0,1 a)c@2,0.85"
+	if s := strings.Join(got, " "); s != want {
+		t.Errorf("entries = %q, want %q", s, want)
+	}
+	for _, test := range []struct {
+		n         int
+		format, s string
+	}{
+		{3, "1.", "3."}, {2, "(a)", "(b)"}, {28, "a)", "ab)"}, {14, "i.", "xiv."}, {1994, "I", "MCMXCIV"}, {4, "-", "4-"},
+	} {
+		if got := Numeral(test.n, test.format); got != test.s {
+			t.Errorf("Numeral(%d, %q) = %q, want %q", test.n, test.format, got, test.s)
+		}
+	}
+}
+
+func TestBullets(t *testing.T) {
+	for _, test := range []struct {
+		list List
+		want string
+	}{
+		{List{CommonAttr: CommonAttr{Type: "check"}, Li: []ListItem{{Checked: "true"}, {}}}, "checked checkbox"},
+		{List{CommonAttr: CommonAttr{Type: "bullet"}, Bullets: "★", Li: []ListItem{{}, {Bullet: "✓"}, {Checked: "false"}}}, "★ ✓ checkbox"},
+		{List{CommonAttr: CommonAttr{Type: "bullet"}, BulletImage: "dot.png", Li: []ListItem{{}}}, "image:dot.png"},
+		{List{CommonAttr: CommonAttr{Type: "number"}, Li: []ListItem{{}, {Checked: "no"}}}, " checkbox"},
+	} {
+		var got []string
+		for _, e := range test.list.Entries() {
+			if e.BulletImage != "" {
+				e.Bullet += ":" + e.BulletImage
+			}
+			got = append(got, e.Bullet)
+		}
+		if s := strings.Join(got, " "); s != test.want {
+			t.Errorf("bullets = %q, want %q", s, test.want)
+		}
+	}
+	e := List{CommonAttr: CommonAttr{Type: "bullet"}, BulletSize: 150, Li: []ListItem{{Level: 1}}}.Entries()[0]
+	if e.BulletScale != 1.5*0.85 {
+		t.Errorf("bullet scale = %g, want %g", e.BulletScale, 1.5*0.85)
+	}
+}
+
+func TestTextStyle(t *testing.T) {
+	d, err := ReadDeck(io.NopCloser(strings.NewReader(`<deck><slide>
+<text decoration="underline strike" letterspacing="10">a</text>
+<list highlight="yellow" decoration="underline"><li>b</li><li highlight="none" decoration="none" letterspacing="5">c</li></list>
+</slide></deck>`)), 100, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := d.Slide[0].Text[0].TextStyle
+	if !ts.Underline() || !ts.Strike() || ts.Highlighted() || ts.Tracking(20) != 2 {
+		t.Errorf("text style %+v: underline %v, strike %v, highlighted %v, tracking %g", ts, ts.Underline(), ts.Strike(), ts.Highlighted(), ts.Tracking(20))
+	}
+	var got []string
+	for _, e := range d.Slide[0].List[0].Entries() {
+		got = append(got, fmt.Sprintf("%v,%v,%g", e.Underline(), e.Highlighted(), e.LetterSpacing))
+	}
+	if s, want := strings.Join(got, " "), "true,true,0 false,false,5"; s != want {
+		t.Errorf("list item styles = %q, want %q", s, want)
+	}
+}
+
+func TestTextBox(t *testing.T) {
+	measure := func(s string) float64 { return float64(len(s)) }
+	if got, want := strings.Join(WrapWithin("aaa bb cccc d", 7, 1, measure), "|"), "aaa bb|cccc d"; got != want {
+		t.Errorf("WrapWithin = %q, want %q", got, want)
+	}
+	if (Text{CommonAttr: CommonAttr{Type: "block"}}).IsBox() || !(Text{CommonAttr: CommonAttr{Type: "block"}, Fill: "gray"}).IsBox() || (Text{Fill: "gray"}).IsBox() {
+		t.Error("IsBox: only blocks with a height, padding, fill or border are boxes")
+	}
+	lines := []string{"one", "two", ""}
+	for _, test := range []struct {
+		valign       string
+		height       float64
+		wantH, wantY float64
+	}{
+		{"", 0, 14.15, 3.4},
+		{"top", 50, 50, 3.4},
+		{"middle", 50, 50, 21.33},
+		{"bottom", 50, 50, 39.25},
+	} {
+		tx := Text{VAlign: test.valign}
+		h, y := tx.Box(0, test.height, 1, lines, 3, 9)
+		if fmt.Sprintf("%.4g %.4g", h, y) != fmt.Sprintf("%.4g %.4g", test.wantH, test.wantY) {
+			t.Errorf("Box valign %q, height %g = %.4g, %.4g, want %g, %g", test.valign, test.height, h, y, test.wantH, test.wantY)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	// text fits at sizes up to 10
+	fits := func(fs float64) bool { return fs <= 10 }
+	for _, test := range []struct {
+		fit       string
+		fs        float64
+		smallest  float64
+		wantSize  float64
+		wantFits  bool
+		tolerance float64
+	}{
+		{"", 20, 1, 20, true, 0},
+		{Shrink, 20, 1, 10, true, 0.05},
+		{Shrink, 5, 1, 5, true, 0},
+		{Fill, 5, 1, 10, true, 0.05},
+		{Fill, 20, 12, 12, false, 0},
+	} {
+		size, ok := Fitting{Fit: test.fit}.Size(test.fs, test.smallest, 50, fits)
+		if ok != test.wantFits || math.Abs(size-test.wantSize) > test.tolerance {
+			t.Errorf("fit %q, size %g = %g, %v, want %g, %v", test.fit, test.fs, size, ok, test.wantSize, test.wantFits)
+		}
+	}
+	measure := func(s string) float64 { return float64(len(s)) }
+	tx := Text{}
+	lines := WrapWithin("aaa bb cccc", 7, 1, measure)
+	if !tx.Fits(lines, 9, 0, 1, 1, 2, 1, measure) || tx.Fits(lines, 9, 3, 1, 1, 2, 1, measure) || tx.Fits([]string{"aaaaaaaa"}, 9, 0, 1, 1, 2, 1, measure) {
+		t.Errorf("Fits %q: wrong fit of the width or height", lines)
+	}
+	// list items wrap within the width, but for centered lists
+	measures := func(font string, fs float64, ts TextStyle) (float64, func(string) float64) {
+		return fs, func(s string) float64 { return fs * float64(len(s)) }
+	}
+	l := List{Li: []ListItem{{ListText: "aa bb"}, {ListText: "cccc"}}}
+	l.Lp = 1
+	entries := l.Entries()
+	if !l.Fits(entries, 5, 2, 1, measures) || l.Fits(entries, 5, 1.5, 1, measures) {
+		t.Errorf("list of lines: wrong fit of the height")
+	}
+	if !l.Fits(entries, 4, 3, 1, measures) || l.Fits(entries, 4, 2, 1, measures) {
+		t.Errorf("wrapped list: wrong fit of the height")
+	}
+	l.Align = "center"
+	if l.Fits(entries, 4, 3, 1, measures) {
+		t.Errorf("centered list: wrong fit of the width")
+	}
+}
+
+func TestHyphenate(t *testing.T) {
+	en := English()
+	for word, want := range map[string]string{
+		"hyphenation":   "hy-phen-a-tion",
+		"associate":     "as-so-ciate",
+		"presentation,": "pre-sen-ta-tion,",
+		"deck":          "deck",
+		"(Typography)":  "(ty-pog-ra-phy)",
+	} {
+		if got := strings.Join(en.Syllables(strings.ToLower(word)), "-"); got != strings.ToLower(want) {
+			t.Errorf("Syllables(%q) = %q, want %q", word, got, want)
+		}
+	}
+	h := NewHyphenator("% a dictionary\n\\patterns{ 1ba }\n\\hyphenation{ta-bl-e}")
+	h.LeftMin, h.RightMin = 1, 1
+	if got := fmt.Sprint(h.Points("abab"), h.Points("table")); got != "[1] [2 4]" {
+		t.Errorf("dictionary points = %s, want [1] [2 4]", got)
+	}
+}
+
+func TestParagraph(t *testing.T) {
+	measure := func(s string) float64 { return float64(len(s)) }
+	text := "In justified text the spaces between words are stretched so that the lines align with both margins without rivers"
+	for _, h := range []*Hyphenator{nil, English()} {
+		lines := Paragraph(h)(text, 24, 1, measure)
+		if !Within(lines, 24, 1, measure) {
+			t.Errorf("lines wider than the width: %q", lines)
+		}
+		words := strings.Replace(strings.Join(lines, " "), "- ", "", -1)
+		if words != text {
+			t.Errorf("lines %q do not hold the text", lines)
+		}
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	spans := Inline("a **bold** *and `code`* [link](#2) snake_case \\*")
+	want := []Span{{Text: "a "}, {Text: "bold", Strong: true}, {Text: " "}, {Text: "and ", Emphasis: true},
+		{Text: "code", Emphasis: true, Code: true}, {Text: " "}, {Text: "link", Link: "#2"}, {Text: " snake_case *"}}
+	if !reflect.DeepEqual(spans, want) {
+		t.Errorf("Inline = %+v, want %+v", spans, want)
+	}
+	if got := len(Words(spans)); got != 7 {
+		t.Errorf("Words = %d words, want 7", got)
+	}
+	d, err := ReadDeck(io.NopCloser(strings.NewReader(`<deck><slide>
+	<text type="markdown" xp="10" yp="90" sp="2">
+		# Title {slide}
+
+		Some *text*.
+
+		- one
+		  - two
+		1. three
+
+		    code
+	</text>
+	<list><li type="markdown">**four**</li></list>
+	</slide></deck>`)), 1000, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := d.Slide[0]
+	if len(s.Text) != 2 || len(s.List) != 3 {
+		t.Fatalf("markdown made %d texts and %d lists, want 2 and 3", len(s.Text), len(s.List))
+	}
+	if h := s.Text[0]; h.Tdata != "Title 1" || h.Sp != 4 || !h.Spans[0].Strong || h.Yp != 90 {
+		t.Errorf("heading = %q size %v at %v", h.Tdata, h.Sp, h.Yp)
+	}
+	if p := s.Text[1]; p.Tdata != "Some text." || p.Yp >= s.Text[0].Yp {
+		t.Errorf("paragraph = %q at %v", p.Tdata, p.Yp)
+	}
+	bullets, numbers := s.List[1], s.List[2]
+	if bullets.Type != "bullet" || len(bullets.Li) != 2 || bullets.Li[1].Level != 1 || numbers.Type != "number" || numbers.Li[0].ListText != "three code" {
+		t.Errorf("lists = %+v, %+v", bullets, numbers)
+	}
+	if numbers.Yp >= bullets.Yp || bullets.Yp >= s.Text[1].Yp {
+		t.Errorf("blocks are not placed down the slide")
+	}
+	if li := s.List[0].Li[0]; li.ListText != "four" || !li.Spans[0].Strong || li.Type != "" {
+		t.Errorf("markdown item = %+v", li)
+	}
+
+	// a paragraph that wraps into more lines, measured by a renderer, moves the blocks below it down
+	d, err = ReadDeck(io.NopCloser(strings.NewReader(`<deck><slide>
+	<text type="markdown" xp="10" yp="90" wp="50" sp="2">
+		aaaa bbbb cccc dddd eeee ffff gggg hhhh
+
+		After
+
+		- item
+	</text>
+	</slide></deck>`)), 1000, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = d.Slide[0]
+	// by the estimate the paragraph is a line: the next block is its line, and half the size, below it
+	if p, after := s.Text[0], s.Text[1]; p.Yp != 90 || math.Abs(after.Yp-86.2) > 1e-9 {
+		t.Errorf("estimated: paragraph at %v, next block at %v, want 90 and 86.2", p.Yp, after.Yp)
+	}
+	wide := func(font string, fs float64, ts TextStyle) (float64, func(string) float64) {
+		return fs, func(s string) float64 { return fs * float64(len(s)) }
+	}
+	for _, test := range []struct {
+		cw, ch float64
+		want   float64
+	}{
+		{1000, 1000, 83.4}, // two lines
+		{2000, 1000, 76.8}, // two lines, sizes twice the height
+	} {
+		Flow(s.Text, s.List, test.cw, test.ch, wide)
+		if p, after := s.Text[0], s.Text[1]; p.Yp != 90 || math.Abs(after.Yp-test.want) > 1e-9 || s.List[0].Yp >= after.Yp {
+			t.Errorf("%vx%v: paragraph at %v, next blocks at %v and %v, want 90 and %v", test.cw, test.ch, p.Yp, after.Yp, s.List[0].Yp, test.want)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	kinds := func(code, lang string) string {
+		var s []string
+		for _, line := range Highlight(code, lang) {
+			for _, tok := range line {
+				if tok.Kind != "" {
+					s = append(s, tok.Kind+":"+tok.Text)
+				}
+			}
+		}
+		return strings.Join(s, " ")
+	}
+	for _, test := range []struct{ lang, code, want string }{
+		{"go", "func f(s string) { // hi\n\treturn len(`a\nb`) + 0x1F }", "keyword:func function:f type:string comment:// hi keyword:return builtin:len string:`a string:b` number:0x1F"},
+		{"bash", "echo \"$HOME\" $1 # done", "builtin:echo string:\"$HOME\" variable:$1 comment:# done"},
+		{"json", `{"n": 1.5, "ok": true}`, `attribute:"n" number:1.5 attribute:"ok" builtin:true`},
+		{"xml", `<!-- c --><a href="x">&amp;</a>`, `comment:<!-- c --> tag:<a attribute:href string:"x" tag:> builtin:&amp; tag:</a>`},
+		{"xml", "<a / b=\"1\">\nrest</a>", `tag:<a attribute:b string:"1" tag:> tag:</a>`},
+		{"sql", "Select id from t where n = 'a''b' -- c", "keyword:Select keyword:from keyword:where string:'a''b' comment:-- c"},
+	} {
+		if got := kinds(test.code, test.lang); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.lang, got, test.want)
+		}
+	}
+	if Highlight("x", "cobol") != nil || len(Highlight("a\nb", "go")) != 2 {
+		t.Errorf("unknown languages are not highlighted, and lines are kept")
+	}
+	if Scheme("dark").Color(Keyword) == Scheme("nonesuch").Color(Keyword) || Scheme("").Background != "rgb(240,240,240)" {
+		t.Errorf("schemes are not distinct")
+	}
+}
+
+func TestCode(t *testing.T) {
+	src := "package main\n\n// ELIDE\nfunc f() {}\n// ELIDE\nfunc main() {\n\tf()\n}\n"
+	shown := func(lines []CodeLine) string {
+		var s []string
+		for _, l := range lines {
+			mark := ""
+			if l.Band {
+				mark = "+"
+			}
+			if l.Dim {
+				mark = "-"
+			}
+			s = append(s, fmt.Sprintf("%d%s:%s", l.Number, mark, strings.TrimSpace(l.Text)))
+		}
+		return strings.Join(s, " ")
+	}
+	for _, test := range []struct {
+		text Text
+		want string
+	}{
+		{Text{Elide: "on", Mark: "6 7"}, "1:package main 2: 0:... 6+:func main() { 7+:f() 8:} 0:"},
+		{Text{Lines: "6-7", Mark: "7", MarkStyle: "dim"}, "6-:func main() { 7:f()"},
+		{Text{Lines: "7-", Mark: "7-8", MarkStyle: "both"}, "7+:f() 8+:} 0-:"},
+	} {
+		if got := shown(test.text.Code(src)); got != test.want {
+			t.Errorf("%+v: got %s, want %s", test.text, got, test.want)
+		}
+	}
+	lines := Text{Lang: "go", Lines: "6"}.Code(src)
+	if len(lines) != 1 || len(lines[0].Tokens) == 0 || lines[0].Tokens[0].Kind != Keyword {
+		t.Errorf("ranged lines are not highlighted: %v", lines)
+	}
+	numbers := Text{LineNumbers: "on", Lines: "8-"}.Numbers(Text{Lines: "8-"}.Code(src))
+	if strings.Join(numbers, "|") != "8| " {
+		t.Errorf("line numbers: got %q", numbers)
+	}
+}
+
+func TestTextPath(t *testing.T) {
+	var s Slide
+	err := xml.Unmarshal([]byte(`<slide><textpath xp1="10" yp1="50" xp2="50" yp2="50" xp3="90" yp3="50" align="end" offset="50">a
+	b</textpath></slide>`), &s)
+	if err != nil || len(s.TextPath) != 1 {
+		t.Fatalf("textpath is not read: %v", err)
+	}
+	tp := s.TextPath[0]
+	measure := func(string) float64 { return 10 }
+	glyphs := tp.Glyphs(100, 100, measure)
+	if tp.Text() != "a b" || len(glyphs) != 3 {
+		t.Fatalf("got %d glyphs of %q, want 3", len(glyphs), tp.Text())
+	}
+	// the text ends at the middle of a level line across the canvas
+	if g := glyphs[2]; math.Abs(g.X-40) > 0.01 || math.Abs(g.Y-50) > 0.01 || math.Abs(g.Angle) > 0.01 {
+		t.Errorf("last glyph: got %+v, want at 40, 50, level", g)
+	}
+	tp.Offset, tp.Align = 95, ""
+	if n := len(tp.Glyphs(100, 100, measure)); n != 0 {
+		t.Errorf("glyphs beyond the end of the path: got %d, want 0", n)
+	}
+	arc := TextPath{Wp: 50, A1: 90, A2: 90, Tdata: "x"}
+	arc.Xp, arc.Yp = 50, 50
+	if a1, a2 := arc.Angles(); a1 != 90 || a2 != -270 || arc.IsCurve() {
+		t.Errorf("a circle: got %v to %v", a1, a2)
+	}
+	// the first glyph of a circle is at its top, turning clockwise
+	if g := arc.Glyphs(100, 100, measure); len(g) != 1 || math.Abs(g[0].Y-25) > 1 || g[0].Angle > -1 {
+		t.Errorf("glyph on a circle: got %+v", g)
+	}
+}
+
+func TestQR(t *testing.T) {
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsremainder(data, rsdivisor(len(want))); !bytes.Equal(got, want) {
+		t.Errorf("error correction: got %v, want %v", got, want)
+	}
+	// HELLO WORLD at level M with mask 3, module by module: the data, the error correction,
+	// their placement, the mask and the format information
+	golden := []string{
+		"#######.#...#.#######",
+		"#.....#.#...#.#.....#",
+		"#.###.#.......#.###.#",
+		"#.###.#.#.#.#.#.###.#",
+		"#.###.#..###..#.###.#",
+		"#.....#...###.#.....#",
+		"#######.#.#.#.#######",
+		"........#####........",
+		"#.##.###.#.##.#..#.##",
+		".##....#.#######.##..",
+		".....#####.#.#.#...##",
+		"#.#.##.##..#...#.#.#.",
+		"#...#.##.##.##....#.#",
+		"........#.##..##..#.#",
+		"#######.#.#######....",
+		"#.....#.###..#.#.####",
+		"#.###.#..#..#.#..#...",
+		"#.###.#.###...#..###.",
+		"#.###.#.##..#..#..#..",
+		"#.....#..###.####...#",
+		"#######.##.#.#.#.....",
+	}
+	q, format, err := qrencode("HELLO WORLD", "M")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.mask(3)
+	q.format(format, 3)
+	modules, _ := QR("HELLO WORLD", "M")
+	for y, row := range golden {
+		for x, c := range row {
+			if q.modules[y][x] != (c == '#') || modules[y][x] != (c == '#') {
+				t.Fatalf("HELLO WORLD: module %d, %d is not as in the golden code", x, y)
+			}
+		}
+	}
+	for _, test := range []struct {
+		data, level string
+		size        int
+	}{
+		{"HELLO WORLD", "", 21},
+		{"https://example.com/feedback?talk=deck", "q", 33},
+		{strings.Repeat("x", 300), "L", 61},
+	} {
+		modules, err := QR(test.data, test.level)
+		if err != nil || len(modules) != test.size {
+			t.Errorf("%.20q: got %d modules across (%v), want %d", test.data, len(modules), err, test.size)
+			continue
+		}
+		n := len(modules)
+		// finder patterns in three corners, and the dark module by the one at the bottom
+		for _, c := range [][2]int{{0, 0}, {0, n - 7}, {n - 7, 0}} {
+			if !modules[c[0]][c[1]] || modules[c[0]+1][c[1]+1] || !modules[c[0]+3][c[1]+3] {
+				t.Errorf("%.20q: no finder pattern at %v", test.data, c)
+			}
+		}
+		if !modules[n-8][8] {
+			t.Errorf("%.20q: no dark module", test.data)
+		}
+		dark := 0
+		for _, row := range modules {
+			for _, m := range row {
+				if m {
+					dark++
+				}
+			}
+		}
+		for _, r := range QRRuns(modules) {
+			dark -= r.Len
+		}
+		if dark != 0 {
+			t.Errorf("%.20q: runs leave out %d dark modules", test.data, dark)
+		}
+	}
+	if _, err := QR(strings.Repeat("x", 1300), "H"); err == nil {
+		t.Errorf("data beyond the capacity of QR codes is encoded")
+	}
+	if _, err := QR("x", "Z"); err == nil {
+		t.Errorf("an unknown level is accepted")
+	}
+}
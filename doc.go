@@ -10,13 +10,15 @@ Here are the elements of a deck:
 
 	deck: enclosing element
 	canvas: describe the dimensions of the drawing canvas, one per deck
-	metadata elements: title, creator, date, publisher, subject, description
+	font: declare a TrueType font used by the deck
+	metadata elements: title, creator, date, publisher, subject, description,
+	keywords, language, license, version, event, venue, and meta (custom name and value properties)
 	slide: within a deck, any number of slides, specify the slide duration, gradient colors, background and text colors.
 
 within slides an number of:
 
 	text: plain, textblock, or code
-	list: plain, bullet, number
+	list: plain, bullet, number, check, nested
 	image: JPEG or PNG images
 	line: straight line
 	rect: rectangle
@@ -24,6 +26,8 @@ within slides an number of:
 	curve: Quadratic Bezier curve
 	arc: elliptical arc
 	polygon: polygon
+	textpath: text along an arc or curve
+	qrcode: QR code of data
 
 Markup
 
@@ -59,13 +63,59 @@ The list, text, rect, and ellipse elements have common attributes:
 	yp: vertical percentage
 	sp: font size percentage
 	lp: line spacing percentage
-	type: "bullet", "number" (list), "block", "code" (text)
-	align: "left", "middle", "end"
+	type: "bullet", "number" (list), "block", "code", "markdown" (text), "markdown" (list item)
+	align: "left", "middle", "end", "justify" (block text)
 	rotation: degree of rotation (0-360)
 	opacity: 0.0-1.0 (fully transparent - opaque)
 	color: SVG names ("maroon"), or RGB "rgb(127,0,0)"
 	font: "sans", "serif", "mono", "symbol"
 	link: url
+	dir: "ltr", "rtl", "auto" (text direction; rtl text starts at xp and extends left)
+	decoration: "underline", "strike", or both (text, lists, list items)
+	highlight: color drawn behind text, line by line
+	letterspacing: space between letters, percentage of the font size
+	hp, padding, fill, border, borderwidth, radius, valign: text box of a block (valign: "top", "middle", "bottom")
+	fit: "shrink" or "fill", sizes block text and lists to fit wp and hp, down to minsp
+	hyphenate: "on" (English), or a dictionary of hyphenation patterns, for block text
+	lang, scheme: code highlighted by its language ("go", "shell", "json", "xml", "sql"), in a color scheme ("light", "dark", "solarized")
+	linenumbers, mark, markstyle, markcolor: line numbers of code ("on"), and marked lines ("7 10-12") on a band, dimming the others, or both
+	lines, elide: range of the lines of code shown ("5-20"), and regions between marker comments ("on" for ELIDE) shown as "..."
+
+Markdown text (type="markdown", inline or from a file) is laid out down the slide from xp, yp: its headings and
+paragraphs become block text, its lists become lists, and its code blocks become code, with **strong**, *emphasis*,
+`code` and [links](url) kept as styled spans.
+
+Text on a path (textpath) follows an arc centered at xp, yp (wp, hp, a1, a2 as for arcs; the whole circle
+if a1 and a2 are the same), or the curve through xp1, yp1, xp2, yp2, xp3, yp3, beginning at the offset
+(percentage of the path length), or centered on it or ending at it by its alignment.
+
+A QR code (qrcode) encodes its data at an error correction level ("L", "M", "Q", "H"), centered at xp, yp,
+wp wide with its quiet zone, in its color (black) on its background (bg, white).
+
+Images and graphics may have alt (a text alternative for screen readers) and title attributes.
+
+The content of the deck's header and footer elements is drawn on every slide, unless the slide
+has header="off" or footer="off". Text may contain placeholders, such as {slide}, {slides}, {section}, {title}, and {date}.
+
+A slide may have its own canvas, with width and height attributes, or an orientation ("portrait", "landscape")
+that turns the deck's canvas; percentages on the slide refer to the slide's canvas.
+
+Reflow converts a deck to a canvas of a different size or aspect ratio (letterbox, stretch, or smart).
+
+A slide with hidden="true" stays in the deck but out of its flow: renderers leave it out
+and players skip it, unless asked to include hidden slides.
+
+Slides, sections, and elements may have only and except attributes, lists of tags naming the variants
+of the deck that have them: only="long" content is in the variant made with the tag long, except="long"
+content is left out of it. Read makes a variant when given tags.
+
+Slides may be grouped in section elements; a section with divider="on" begins with a slide made from the
+deck's divider element. A toc element becomes a list of the sections, linked to their first slides (link="#n").
+
+Lists may be nested, by lists within list items or by the level attribute of items; each level is indented,
+sized, bulleted, and numbered on its own (bullets="disc circle", numbers="1. a) i.").
+Bullets may be glyphs or images, colored and sized apart from the text; items of check lists
+(type="check"), and items with a checked attribute, are checkboxes.
 
 Layout
 
@@ -0,0 +1,65 @@
+package deck
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Run is a piece of text drawn with one font of a fallback chain
+type Run struct {
+	Text string
+	Font int // index of the font in the chain
+}
+
+// Fallbacks returns the fallback fonts declared for each font, in order of preference
+func (d Deck) Fallbacks() map[string][]string {
+	m := map[string][]string{}
+	for _, f := range d.Font {
+		names := strings.FieldsFunc(f.Fallback, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+		if len(names) > 0 {
+			m[f.Name] = names
+		}
+	}
+	return m
+}
+
+// Runs splits text into runs for a chain of fonts. covers reports, in order of preference,
+// whether each font has a glyph for a character (a nil function covers everything).
+// Each character uses the first font that has it, or the first font if none do;
+// spaces and combining marks stay with the run they follow.
+func Runs(s string, covers []func(rune) bool) []Run {
+	if len(covers) < 2 {
+		if s == "" {
+			return nil
+		}
+		return []Run{{Text: s}}
+	}
+	var runs []Run
+	start, cur := 0, -1
+	for i, r := range s {
+		f := cur
+		if cur < 0 || !(unicode.IsSpace(r) || unicode.Is(unicode.Mn, r)) {
+			f = firstcover(r, covers)
+		}
+		if f != cur {
+			if cur >= 0 {
+				runs = append(runs, Run{Text: s[start:i], Font: cur})
+			}
+			start, cur = i, f
+		}
+	}
+	if cur >= 0 {
+		runs = append(runs, Run{Text: s[start:], Font: cur})
+	}
+	return runs
+}
+
+// firstcover returns the index of the first font having a glyph for r
+func firstcover(r rune, covers []func(rune) bool) int {
+	for i, has := range covers {
+		if has == nil || has(r) {
+			return i
+		}
+	}
+	return 0
+}
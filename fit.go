@@ -0,0 +1,125 @@
+package deck
+
+import "strings"
+
+// Fit modes of block text and lists
+const (
+	Shrink = "shrink" // make the text smaller, as far as the smallest size, until it fits its box
+	Fill   = "fill"   // make the text as large as fits its box
+)
+
+// minsp is the default smallest size of fitted text, percentage of the canvas width
+const minsp = 1.0
+
+// Fitting sizes block text and lists to fit a box, wp wide and hp high:
+// fit="shrink" makes text that overflows smaller, fit="fill" makes text as large as fits.
+// <text type="block" xp="10" yp="90" wp="80" hp="15" sp="6" fit="shrink">{title}</text>
+type Fitting struct {
+	Fit   string  `xml:"fit,attr,omitempty"`   // shrink or fill
+	MinSp float64 `xml:"minsp,attr,omitempty"` // smallest size of fitted text, percentage of the canvas width
+}
+
+// Smallest returns the smallest size of fitted text, percentage of the canvas width
+func (f Fitting) Smallest() float64 {
+	if f.MinSp > 0 {
+		return f.MinSp
+	}
+	return minsp
+}
+
+// Wrapping returns the wrapping of fitted text, which is kept within its width, or of other text
+func (f Fitting) Wrapping() func(s string, width, space float64, measure func(string) float64) []string {
+	if f.Fit == Shrink || f.Fit == Fill {
+		return WrapWithin
+	}
+	return Wrap
+}
+
+// Size returns the size of text of size fs fitted to its box: shrinking, the largest size from the
+// smallest to fs, filling, from the smallest to the largest, at which fits reports that the text fits.
+// Text that does not fit at the smallest size has the smallest size, and Size reports false.
+// Text that is not fitted keeps its size.
+func (f Fitting) Size(fs, smallest, largest float64, fits func(fs float64) bool) (float64, bool) {
+	switch f.Fit {
+	case Shrink:
+		largest = fs
+	case Fill:
+	default:
+		return fs, true
+	}
+	if smallest > largest {
+		smallest = largest
+	}
+	if fits(largest) {
+		return largest, true
+	}
+	if !fits(smallest) {
+		return smallest, false
+	}
+	// the text fits at lo, and not at hi
+	lo, hi := smallest, largest
+	for hi-lo > 0.05 {
+		mid := (lo + hi) / 2
+		if fits(mid) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo, true
+}
+
+// Within reports whether each line of words, measured as they are wrapped, fits the width.
+// The words are summed as WrapWithin sums them, so that the lines it makes are within the width.
+func Within(lines []string, width, space float64, measure func(string) float64) bool {
+	for _, line := range lines {
+		x := 0.0
+		for _, word := range strings.FieldsFunc(line, wrapspace) {
+			w := measure(word)
+			if x+w > width {
+				return false
+			}
+			x += w + space
+		}
+	}
+	return true
+}
+
+// Fits reports whether the wrapped lines of a text box, of size fs with the leading, fit the box
+// of the width and height inside the padding. If the box has no height, only the width is fitted.
+func (t Text) Fits(lines []string, w, h, pad, fs, leading, space float64, measure func(string) float64) bool {
+	if !Within(lines, w-pad*2, space, measure) {
+		return false
+	}
+	th, _ := t.Box(0, 0, pad, lines, fs, leading)
+	return h <= 0 || th <= h
+}
+
+// Fits reports whether the entries of a list of size fs fit the box of the width and height.
+// Each item is wrapped within the width left by its indentation, as renderers draw it, but for
+// centered lists, whose items are lines; measures returns the space between words and the measure
+// of words of a font, size and style. If the box has no height, only the width is fitted.
+func (l List) Fits(entries []ListEntry, w, h, fs float64, measures func(font string, fs float64, ts TextStyle) (float64, func(string) float64)) bool {
+	lh := 0.0
+	for _, e := range entries {
+		ifs, iw := fs*e.Scale, w-fs*e.Indent
+		font := l.Font
+		if len(e.Font) > 0 {
+			font = e.Font
+		}
+		t := e.ListText
+		if len(e.Number) > 0 {
+			t = e.Number + " " + t
+		}
+		space, measure := measures(font, ifs, e.TextStyle)
+		lines := []string{t}
+		if l.Align != "center" && l.Align != "c" {
+			lines = WrapWithin(t, iw, space, measure)
+		}
+		if !Within(lines, iw, space, measure) {
+			return false
+		}
+		lh += l.Lp * ifs * float64(len(lines))
+	}
+	return h <= 0 || lh <= h
+}
@@ -0,0 +1,414 @@
+package deck
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Kinds of the tokens of highlighted code
+const (
+	Keyword   = "keyword"   // reserved words: func, if, select
+	Type      = "type"      // names of types: int, string, varchar
+	Builtin   = "builtin"   // predeclared names and constants: len, nil, true, echo
+	Function  = "function"  // names of called functions
+	String    = "string"    // quoted strings and characters
+	Number    = "number"    // numeric literals
+	Comment   = "comment"   // comments
+	Tag       = "tag"       // XML tags
+	Attribute = "attribute" // XML attributes and JSON keys
+	Variable  = "variable"  // shell variables
+)
+
+// Token is a piece of source code of one kind, or of none, for other text
+type Token struct {
+	Text string
+	Kind string
+}
+
+// ColorScheme colors highlighted code: each kind of token in its color, and other text in the text color,
+// on the background, with marked lines on the band of the mark color
+type ColorScheme struct {
+	Background string
+	Text       string
+	Mark       string
+	Colors     map[string]string
+}
+
+// Color returns the color of a kind of token
+func (c ColorScheme) Color(kind string) string {
+	if color, ok := c.Colors[kind]; ok {
+		return color
+	}
+	return c.Text
+}
+
+// DefaultScheme is the color scheme of code without a scheme, on the usual gray background of code
+const DefaultScheme = "light"
+
+var schemes = map[string]ColorScheme{
+	"light": {Background: "rgb(240,240,240)", Text: "rgb(36,41,47)", Mark: "rgb(255,248,197)", Colors: map[string]string{
+		Keyword: "rgb(207,34,46)", Type: "rgb(149,56,0)", Builtin: "rgb(5,80,174)", Function: "rgb(130,80,223)",
+		String: "rgb(10,48,105)", Number: "rgb(5,80,174)", Comment: "rgb(110,119,129)",
+		Tag: "rgb(17,99,41)", Attribute: "rgb(5,80,174)", Variable: "rgb(149,56,0)",
+	}},
+	"dark": {Background: "rgb(40,44,52)", Text: "rgb(171,178,191)", Mark: "rgb(62,68,81)", Colors: map[string]string{
+		Keyword: "rgb(198,120,221)", Type: "rgb(229,192,123)", Builtin: "rgb(86,182,194)", Function: "rgb(97,175,239)",
+		String: "rgb(152,195,121)", Number: "rgb(209,154,102)", Comment: "rgb(127,132,142)",
+		Tag: "rgb(224,108,117)", Attribute: "rgb(209,154,102)", Variable: "rgb(224,108,117)",
+	}},
+	"solarized": {Background: "rgb(253,246,227)", Text: "rgb(88,110,117)", Mark: "rgb(238,232,213)", Colors: map[string]string{
+		Keyword: "rgb(133,153,0)", Type: "rgb(181,137,0)", Builtin: "rgb(203,75,22)", Function: "rgb(38,139,210)",
+		String: "rgb(42,161,152)", Number: "rgb(211,54,130)", Comment: "rgb(147,161,161)",
+		Tag: "rgb(38,139,210)", Attribute: "rgb(181,137,0)", Variable: "rgb(203,75,22)",
+	}},
+	"solarized-dark": {Background: "rgb(0,43,54)", Text: "rgb(131,148,150)", Mark: "rgb(7,54,66)", Colors: map[string]string{
+		Keyword: "rgb(133,153,0)", Type: "rgb(181,137,0)", Builtin: "rgb(203,75,22)", Function: "rgb(38,139,210)",
+		String: "rgb(42,161,152)", Number: "rgb(211,54,130)", Comment: "rgb(88,110,117)",
+		Tag: "rgb(38,139,210)", Attribute: "rgb(181,137,0)", Variable: "rgb(203,75,22)",
+	}},
+}
+
+// Scheme returns the named color scheme: light (the default), dark, solarized, solarized-dark,
+// or a scheme registered by RegisterScheme. Unknown names have the default scheme.
+func Scheme(name string) ColorScheme {
+	if s, ok := schemes[name]; ok {
+		return s
+	}
+	return schemes[DefaultScheme]
+}
+
+// RegisterScheme makes a color scheme available to code by name, i.e. scheme="brand"
+func RegisterScheme(name string, s ColorScheme) {
+	schemes[name] = s
+}
+
+// language describes the tokens of a language, for a lexer of C-like languages
+type language struct {
+	keywords map[string]bool
+	types    map[string]bool
+	builtins map[string]bool
+	fold     bool      // names are not case sensitive
+	comments []string  // starts of comments to the end of the line
+	block    [2]string // start and end of block comments
+	quotes   string    // quotes of strings with escapes
+	raw      string    // quotes of strings without escapes, which may span lines
+	vars     bool      // $name and ${name} are variables
+	calls    bool      // names followed by ( are functions
+	keys     bool      // strings followed by : are keys
+}
+
+// words makes a set of words
+func words(s string) map[string]bool {
+	m := map[string]bool{}
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var languages = map[string]*language{
+	"go": {
+		keywords: words("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var"),
+		types:    words("any bool byte comparable complex64 complex128 error float32 float64 int int8 int16 int32 int64 rune string uint uint8 uint16 uint32 uint64 uintptr"),
+		builtins: words("append cap clear close complex copy delete imag len make max min new panic print println real recover true false nil iota"),
+		comments: []string{"//"},
+		block:    [2]string{"/*", "*/"},
+		quotes:   `"'`,
+		raw:      "`",
+		calls:    true,
+	},
+	"shell": {
+		keywords: words("if then else elif fi for while until do done case esac in function return select time break continue local export readonly declare"),
+		builtins: words("echo cd printf read set unset source exit test shift trap eval exec alias true false"),
+		comments: []string{"#"},
+		quotes:   `"`,
+		raw:      "'",
+		vars:     true,
+	},
+	"json": {
+		builtins: words("true false null"),
+		quotes:   `"`,
+		keys:     true,
+	},
+	"sql": {
+		keywords: words("select from where insert into values update set delete create table drop alter add column index primary key foreign references join inner left right outer full cross on using group by order having limit offset as and or not null is in like between exists distinct union all case when then else end begin commit rollback with returning default unique check view asc desc if replace"),
+		types:    words("int integer bigint smallint serial bigserial text varchar char character boolean bool date time timestamp timestamptz interval numeric decimal real float double precision blob json jsonb uuid"),
+		builtins: words("count sum avg min max coalesce nullif now lower upper length cast true false"),
+		fold:     true,
+		comments: []string{"--"},
+		block:    [2]string{"/*", "*/"},
+		quotes:   "'",
+		calls:    true,
+	},
+}
+
+// Lang returns the canonical name of a language of highlighted code, or "" if it is not known:
+// go, shell (sh, bash, zsh), json, xml (html, svg), sql
+func Lang(name string) string {
+	name = strings.ToLower(name)
+	switch name {
+	case "golang":
+		return "go"
+	case "sh", "bash", "zsh", "console":
+		return "shell"
+	case "html", "svg", "deck":
+		return "xml"
+	}
+	if _, ok := languages[name]; ok || name == "xml" {
+		return name
+	}
+	return ""
+}
+
+// Highlight splits code in a language into lines of tokens, for syntax highlighting.
+// Code in an unknown language has no lines.
+func Highlight(code, lang string) [][]Token {
+	var tokens []Token
+	switch lang = Lang(lang); lang {
+	case "":
+		return nil
+	case "xml":
+		tokens = xmltokens(code)
+	default:
+		tokens = languages[lang].tokens(code)
+	}
+	// tokens are split at the ends of lines, as a comment or string may span lines
+	lines := [][]Token{nil}
+	for _, t := range tokens {
+		for i, s := range strings.Split(t.Text, "\n") {
+			if i > 0 {
+				lines = append(lines, nil)
+			}
+			if len(s) > 0 {
+				lines[len(lines)-1] = appendtoken(lines[len(lines)-1], Token{Text: s, Kind: t.Kind})
+			}
+		}
+	}
+	return lines
+}
+
+// appendtoken appends a token, joined to the last token if they are of the same kind
+func appendtoken(tokens []Token, t Token) []Token {
+	if n := len(tokens); n > 0 && tokens[n-1].Kind == t.Kind {
+		tokens[n-1].Text += t.Text
+		return tokens
+	}
+	return append(tokens, t)
+}
+
+// namechar determines if a character may be part of a name
+func namechar(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// quoted returns the length of the string starting with the quote at the start of s,
+// up to the closing quote, or the end of the line (or of s, for raw strings)
+func quoted(s string, escapes bool) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && escapes:
+			i++
+		case s[i] == q:
+			return i + 1
+		case s[i] == '\n' && escapes:
+			return i
+		}
+	}
+	return len(s)
+}
+
+// tokens splits code into tokens
+func (l *language) tokens(s string) []Token {
+	var tokens []Token
+	add := func(text, kind string) { tokens = appendtoken(tokens, Token{Text: text, Kind: kind}) }
+	for i := 0; i < len(s); {
+		c := s[i]
+		rest := s[i:]
+		// a name or number starts a word only after other than a name
+		r, size := utf8.DecodeRuneInString(rest)
+		prev, _ := utf8.DecodeLastRuneInString(s[:i])
+		boundary := i == 0 || !namechar(prev)
+		switch {
+		case l.block[0] != "" && strings.HasPrefix(rest, l.block[0]):
+			n := strings.Index(rest[len(l.block[0]):], l.block[1])
+			if n < 0 {
+				n = len(rest)
+			} else {
+				n += len(l.block[0]) + len(l.block[1])
+			}
+			add(rest[:n], Comment)
+			i += n
+			continue
+		case l.comment(rest, i == 0 || prev == ' ' || prev == '\t' || prev == '\n' || prev == ';'):
+			n := strings.IndexByte(rest, '\n')
+			if n < 0 {
+				n = len(rest)
+			}
+			add(rest[:n], Comment)
+			i += n
+			continue
+		case strings.IndexByte(l.quotes, c) >= 0 || strings.IndexByte(l.raw, c) >= 0:
+			n := quoted(rest, strings.IndexByte(l.quotes, c) >= 0)
+			kind := String
+			if l.keys && strings.HasPrefix(strings.TrimLeft(rest[n:], " \t"), ":") {
+				kind = Attribute
+			}
+			add(rest[:n], kind)
+			i += n
+			continue
+		case l.vars && c == '$':
+			var n int
+			switch {
+			case strings.HasPrefix(rest, "${"):
+				if n = strings.IndexByte(rest, '}') + 1; n == 0 {
+					n = len(rest)
+				}
+			case len(rest) > 1 && strings.IndexByte("?#@*!$-0123456789", rest[1]) >= 0:
+				n = 2
+			default:
+				for n = 1; n < len(rest) && namechar(rune(rest[n])); n++ {
+				}
+			}
+			if n > 1 {
+				add(rest[:n], Variable)
+				i += n
+				continue
+			}
+		case boundary && unicode.IsDigit(r):
+			n := 1
+			for n < len(rest) && (namechar(rune(rest[n])) || rest[n] == '.' ||
+				((rest[n] == '-' || rest[n] == '+') && (rest[n-1] == 'e' || rest[n-1] == 'E') && !strings.HasPrefix(rest, "0x"))) {
+				n++
+			}
+			add(rest[:n], Number)
+			i += n
+			continue
+		case boundary && namechar(r):
+			n := size
+			for n < len(rest) {
+				r, size := utf8.DecodeRuneInString(rest[n:])
+				if !namechar(r) {
+					break
+				}
+				n += size
+			}
+			name := rest[:n]
+			add(name, l.kind(name, strings.HasPrefix(rest[n:], "(")))
+			i += n
+			continue
+		}
+		add(rest[:size], "")
+		i += size
+	}
+	return tokens
+}
+
+// comment reports whether a comment starts at the start of s; a comment starting with # must start a word
+func (l *language) comment(s string, word bool) bool {
+	for _, c := range l.comments {
+		if strings.HasPrefix(s, c) && (c != "#" || word) {
+			return true
+		}
+	}
+	return false
+}
+
+// kind returns the kind of a name, which is followed by a parenthesis if called
+func (l *language) kind(name string, called bool) string {
+	if l.fold {
+		name = strings.ToLower(name)
+	}
+	switch {
+	case l.keywords[name]:
+		return Keyword
+	case l.types[name]:
+		return Type
+	case l.builtins[name]:
+		return Builtin
+	case l.calls && called:
+		return Function
+	}
+	return ""
+}
+
+// xmltokens splits XML into tokens: comments, tags, attributes and their values, and entities
+func xmltokens(s string) []Token {
+	var tokens []Token
+	add := func(text, kind string) { tokens = appendtoken(tokens, Token{Text: text, Kind: kind}) }
+	intag := false
+	for i := 0; i < len(s); {
+		rest := s[i:]
+		switch {
+		case !intag && strings.HasPrefix(rest, "<!--"):
+			n := strings.Index(rest, "-->")
+			if n < 0 {
+				n = len(rest)
+			} else {
+				n += 3
+			}
+			add(rest[:n], Comment)
+			i += n
+		case !intag && strings.HasPrefix(rest, "<![CDATA["):
+			n := strings.Index(rest, "]]>")
+			if n < 0 {
+				n = len(rest)
+			} else {
+				n += 3
+			}
+			add(rest[:n], String)
+			i += n
+		case !intag && rest[0] == '<':
+			n := 1
+			if n < len(rest) && strings.IndexByte("/?!", rest[n]) >= 0 {
+				n++
+			}
+			for n < len(rest) && strings.IndexByte(" \t\n/>", rest[n]) < 0 {
+				n++
+			}
+			add(rest[:n], Tag)
+			i += n
+			intag = true
+		case intag && (strings.HasPrefix(rest, "/>") || strings.HasPrefix(rest, "?>")):
+			add(rest[:2], Tag)
+			i += 2
+			intag = false
+		case intag && rest[0] == '>':
+			add(">", Tag)
+			i++
+			intag = false
+		case intag && (rest[0] == '"' || rest[0] == '\''):
+			n := quoted(rest, false)
+			add(rest[:n], String)
+			i += n
+		case intag && rest[0] != '=' && rest[0] != ' ' && rest[0] != '\t' && rest[0] != '\n':
+			n := strings.IndexAny(rest, " \t\n=/>")
+			switch {
+			case n < 0:
+				n = len(rest)
+			case n == 0:
+				// a slash not closing the tag
+				add(rest[:1], "")
+				i++
+				continue
+			}
+			add(rest[:n], Attribute)
+			i += n
+		case !intag && rest[0] == '&':
+			n := strings.IndexByte(rest, ';') + 1
+			if n == 0 || strings.ContainsAny(rest[:n], " \t\n<") {
+				n = 1
+				add("&", "")
+			} else {
+				add(rest[:n], Builtin)
+			}
+			i += n
+		default:
+			_, size := utf8.DecodeRuneInString(rest)
+			add(rest[:size], "")
+			i += size
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,204 @@
+package deck
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Hyphenator finds the points at which words may be hyphenated, by Liang's patterns, as TeX does,
+// and by a dictionary of exceptions, words given with their hyphens.
+type Hyphenator struct {
+	LeftMin    int              // fewest letters before a hyphen
+	RightMin   int              // fewest letters after a hyphen
+	patterns   map[string][]int // values of the points between the letters of each pattern
+	exceptions map[string][]int // points of exceptional words
+	longest    int              // letters in the longest pattern
+}
+
+// NewHyphenator makes a hyphenator from a dictionary: patterns, letters with the values of the
+// points between them ("hy3ph"), and exceptions, words with their hyphens ("as-so-ciate"),
+// separated by spaces. Lines starting with % are comments, and the braces of TeX pattern files
+// (\patterns{...} and \hyphenation{...}) are ignored.
+func NewHyphenator(dictionary string) *Hyphenator {
+	h := &Hyphenator{LeftMin: 2, RightMin: 3, patterns: map[string][]int{}, exceptions: map[string][]int{}}
+	h.Add(dictionary)
+	return h
+}
+
+// Add adds the patterns and exceptions of a dictionary to the hyphenator
+func (h *Hyphenator) Add(dictionary string) {
+	for _, line := range strings.Split(dictionary, "\n") {
+		if i := strings.Index(line, "%"); i >= 0 {
+			line = line[:i]
+		}
+		for _, word := range strings.Fields(line) {
+			word = strings.Trim(strings.TrimPrefix(strings.TrimPrefix(word, `\patterns`), `\hyphenation`), "{}")
+			if word == "" {
+				continue
+			}
+			if strings.IndexFunc(word, unicode.IsDigit) >= 0 {
+				h.pattern(strings.ToLower(word))
+			} else {
+				h.exception(strings.ToLower(word))
+			}
+		}
+	}
+}
+
+// pattern adds a pattern: the value of each point, before the letter it precedes, and after the last letter
+func (h *Hyphenator) pattern(p string) {
+	var letters []rune
+	values := []int{0}
+	for _, r := range p {
+		if unicode.IsDigit(r) {
+			values[len(values)-1] = int(r - '0')
+			continue
+		}
+		letters = append(letters, r)
+		values = append(values, 0)
+	}
+	h.patterns[string(letters)] = values
+	if len(letters) > h.longest {
+		h.longest = len(letters)
+	}
+}
+
+// exception adds a word with its hyphens
+func (h *Hyphenator) exception(w string) {
+	var points []int
+	n := 0
+	for _, r := range w {
+		if r == '-' {
+			points = append(points, n)
+			continue
+		}
+		n++
+	}
+	h.exceptions[strings.Replace(w, "-", "", -1)] = points
+}
+
+// Points returns the positions, counted in letters, at which a word may be hyphenated.
+// Words with other than letters are not hyphenated.
+func (h *Hyphenator) Points(word string) []int {
+	w := []rune(strings.ToLower(word))
+	for _, r := range w {
+		if !unicode.IsLetter(r) {
+			return nil
+		}
+	}
+	if points, ok := h.exceptions[string(w)]; ok {
+		return points
+	}
+	if len(w) < h.LeftMin+h.RightMin {
+		return nil
+	}
+	// the word, marked at its start and end, takes the largest value of the patterns at each point
+	s := append(append([]rune{'.'}, w...), '.')
+	values := make([]int, len(s)+1)
+	for i := range s {
+		for j := i + 1; j <= len(s) && j-i <= h.longest; j++ {
+			p, ok := h.patterns[string(s[i:j])]
+			if !ok {
+				continue
+			}
+			for k, v := range p {
+				if v > values[i+k] {
+					values[i+k] = v
+				}
+			}
+		}
+	}
+	// odd values are points of hyphenation; the value before letter k of the word is at k+1
+	var points []int
+	for k := h.LeftMin; k <= len(w)-h.RightMin; k++ {
+		if values[k+1]%2 == 1 {
+			points = append(points, k)
+		}
+	}
+	return points
+}
+
+// Syllables splits a word at its points of hyphenation, i.e. "hy", "phen", "ation".
+// Punctuation before and after the letters of the word stays with the first and last parts.
+func (h *Hyphenator) Syllables(word string) []string {
+	start := strings.IndexFunc(word, unicode.IsLetter)
+	if start < 0 {
+		return []string{word}
+	}
+	end := strings.LastIndexFunc(word, unicode.IsLetter)
+	_, size := utf8.DecodeRuneInString(word[end:])
+	end += size
+	prefix, letters, suffix := word[:start], []rune(word[start:end]), word[end:]
+	var parts []string
+	last := 0
+	for _, p := range h.Points(string(letters)) {
+		parts = append(parts, string(letters[last:p]))
+		last = p
+	}
+	parts = append(parts, string(letters[last:]))
+	parts[0] = prefix + parts[0]
+	parts[len(parts)-1] += suffix
+	return parts
+}
+
+var (
+	english     *Hyphenator
+	englishOnce sync.Once
+	hyphenators = map[string]*Hyphenator{}
+)
+
+// English returns the hyphenator of American English
+func English() *Hyphenator {
+	englishOnce.Do(func() {
+		english = NewHyphenator(englishPatterns)
+		english.Add(englishExceptions)
+	})
+	return english
+}
+
+// RegisterHyphenator makes a hyphenator available to text by name, i.e. hyphenate="de"
+func RegisterHyphenator(name string, h *Hyphenator) {
+	hyphenators[name] = h
+}
+
+// Hyphenator returns the hyphenator of text with the hyphenate attribute: "on" or "en" for
+// English, the name of a registered hyphenator, or the name of a dictionary file, as read by
+// NewHyphenator. It returns nil if the text is not hyphenated, or the dictionary cannot be read;
+// the failure is kept with the errors of the resolver.
+func (r *Resolver) Hyphenator(name string) *Hyphenator {
+	switch name {
+	case "":
+		return nil
+	case "on", "yes", "true", "en", "en-us", "english":
+		return English()
+	}
+	if off(name) {
+		return nil
+	}
+	if h, ok := hyphenators[name]; ok {
+		return h
+	}
+	if r == nil {
+		r = &Resolver{Dir: "."}
+	}
+	if h, ok := r.dictionaries[name]; ok {
+		return h
+	}
+	var h *Hyphenator
+	data, err := r.ReadFile(name)
+	if err != nil {
+		if !r.missing[name] {
+			r.errs = append(r.errs, fmt.Errorf("hyphenation: %v", err))
+		}
+	} else {
+		h = NewHyphenator(string(data))
+	}
+	if r.dictionaries == nil {
+		r.dictionaries = map[string]*Hyphenator{}
+	}
+	r.dictionaries[name] = h
+	return h
+}
@@ -0,0 +1,122 @@
+package deck
+
+import (
+	"math"
+	"strings"
+)
+
+// Justified reports whether text is aligned to both sides
+func Justified(align string) bool {
+	return align == "justify" || align == "j"
+}
+
+// demerits of the lines of a paragraph
+const (
+	linepenalty   = 10.0   // of each line, so that fewer lines are better
+	hyphenpenalty = 50.0   // of a line ending in a hyphen
+	doublehyphen  = 3000.0 // of a line ending in a hyphen, after another
+	overfull      = 1e6    // of a line with a word too wide for it
+)
+
+// piece is a word, or a part of a hyphenated word, with the width of its text
+type piece struct {
+	text   string
+	width  float64
+	hyphen bool // the piece is followed by another part of its word
+}
+
+// Paragraph returns a wrapping that breaks text into lines by the total fit algorithm of Knuth and Plass:
+// of all the ways to break the text, it takes the one with the least demerits, which grow with the square
+// of the badness of each line, the stretching of its spaces, and with its hyphen, rather than filling each
+// line in turn. Lines are filled evenly, so the spaces of justified text are even, without rivers.
+// Lines fit the width; words are hyphenated by h, if not nil, and a line broken within a word ends with a hyphen.
+func Paragraph(h *Hyphenator) func(s string, width, space float64, measure func(string) float64) []string {
+	return func(s string, width, space float64, measure func(string) float64) []string {
+		var pieces []piece
+		for _, word := range strings.FieldsFunc(s, wrapspace) {
+			parts := []string{word}
+			if h != nil {
+				parts = h.Syllables(word)
+			}
+			for i, p := range parts {
+				pieces = append(pieces, piece{text: p, width: measure(p), hyphen: i < len(parts)-1})
+			}
+		}
+		if len(pieces) == 0 {
+			return []string{""}
+		}
+		hyphen := measure("-")
+		// best[end] is the least demerits of the lines of the pieces before end, the last starting at from[end]
+		best := make([]float64, len(pieces)+1)
+		from := make([]int, len(pieces)+1)
+		for end := 1; end <= len(pieces); end++ {
+			best[end] = math.Inf(1)
+			last := end == len(pieces)
+			broken := !last && pieces[end-1].hyphen
+			w, spaces := 0.0, 0
+			if broken {
+				w = hyphen
+			}
+			// lines ending at end, longer as they start earlier, while they fit
+			for start := end - 1; start >= 0; start-- {
+				w += pieces[start].width
+				if start < end-1 && !pieces[start].hyphen {
+					w += space
+					spaces++
+				}
+				d, fits := demerits(w, width, space, spaces, last)
+				if broken {
+					d += hyphenpenalty * hyphenpenalty
+					if start > 0 && pieces[start-1].hyphen {
+						d += doublehyphen
+					}
+				}
+				if best[start]+d < best[end] {
+					best[end], from[end] = best[start]+d, start
+				}
+				if !fits {
+					break
+				}
+			}
+		}
+		var breaks []int
+		for end := len(pieces); end > 0; end = from[end] {
+			breaks = append([]int{end}, breaks...)
+		}
+		lines := make([]string, len(breaks))
+		start := 0
+		for i, end := range breaks {
+			var b strings.Builder
+			for j := start; j < end; j++ {
+				b.WriteString(pieces[j].text)
+				if j < end-1 && !pieces[j].hyphen {
+					b.WriteString(" ")
+				}
+			}
+			if pieces[end-1].hyphen {
+				b.WriteString("-")
+			}
+			lines[i] = b.String()
+			start = end
+		}
+		return lines
+	}
+}
+
+// demerits returns the demerits of a line of the width w, whose spaces stretch as given, and whether
+// it fits the width. The last line need not be stretched; a line of one word stretches as if it had
+// one space, and is overfull if it is too wide.
+func demerits(w, width, space float64, spaces int, last bool) (float64, bool) {
+	if w > width {
+		if spaces == 0 {
+			return overfull, false
+		}
+		return math.Inf(1), false
+	}
+	var r float64
+	if !last {
+		r = (width - w) / (space / 2 * math.Max(float64(spaces), 1))
+	}
+	badness := 100 * r * r * r
+	return (linepenalty + badness) * (linepenalty + badness), true
+}
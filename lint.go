@@ -0,0 +1,294 @@
+package deck
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits are the thresholds checked by Lint
+type Limits struct {
+	Contrast      float64 // minimum contrast ratio of text against its background
+	LargeContrast float64 // minimum contrast ratio of large text
+	LargeSize     float64 // size of large text, as a percentage of the canvas width
+	MinSize       float64 // minimum size of text, as a percentage of the canvas width
+	MaxWords      int     // maximum number of words on a slide
+}
+
+// DefaultLimits uses the WCAG 2 (level AA) contrast ratios, with large text at 18pt on a 792pt wide canvas
+var DefaultLimits = Limits{Contrast: 4.5, LargeContrast: 3, LargeSize: 2.3, MinSize: 1.5, MaxWords: 75}
+
+// Problem is a legibility problem found by Lint
+type Problem struct {
+	Slide   int    `json:"slide"`   // slide number, counting from 1
+	Kind    string `json:"kind"`    // contrast, size, overlap, outside, or words
+	Element string `json:"element"` // element and its index on the slide, i.e. "text 2"
+	Message string `json:"message"`
+}
+
+// String describes a problem, with its slide and element
+func (p Problem) String() string {
+	if p.Element == "" {
+		return fmt.Sprintf("slide %d: %s: %s", p.Slide, p.Kind, p.Message)
+	}
+	return fmt.Sprintf("slide %d: %s: %s: %s", p.Slide, p.Element, p.Kind, p.Message)
+}
+
+// textbox is the estimated extent of text on the canvas, with y increasing upwards
+type textbox struct {
+	name                string
+	x0, x1, bottom, top float64
+	x, y, fs, opacity   float64
+	color, text         string
+	fill                string // fill of a text box, drawn behind its text
+	rotated             bool
+}
+
+// Lint checks the legibility of the deck: the contrast of text with its background (the slide's
+// background or gradient, or a shape or filled text box under the text), the size of text, text that overlaps other text
+// or falls outside the canvas, and slides with too many words. The extent of text is estimated
+// from average character widths, as the fonts are chosen by the renderer. The deck must have a canvas size.
+func Lint(d Deck, lim Limits) []Problem {
+	var problems []Problem
+	if d.Canvas.Width <= 0 || d.Canvas.Height <= 0 {
+		return nil
+	}
+	for n, s := range d.Slide {
+		c := d.SlideCanvas(n)
+		cw, ch := float64(c.Width), float64(c.Height)
+		report := func(kind, element, format string, args ...interface{}) {
+			problems = append(problems, Problem{Slide: n + 1, Kind: kind, Element: element, Message: fmt.Sprintf(format, args...)})
+		}
+		var boxes []textbox
+		words := 0
+		for i, t := range s.Text {
+			text := d.content(t)
+			if t.Type != "code" {
+				words += len(strings.Fields(text))
+			}
+			boxes = append(boxes, measuretext(t, text, fmt.Sprintf("text %d", i), cw, ch))
+		}
+		for i, l := range s.List {
+			for _, li := range l.Li {
+				words += len(strings.Fields(li.ListText))
+			}
+			boxes = append(boxes, measurelist(l, fmt.Sprintf("list %d", i), cw, ch))
+		}
+		if words > lim.MaxWords && lim.MaxWords > 0 {
+			report("words", "", "%d words, more than %d", words, lim.MaxWords)
+		}
+		for i, b := range boxes {
+			if b.text == "" {
+				continue
+			}
+			size := b.fs / cw * 100
+			if size < lim.MinSize {
+				report("size", b.name, "size %.2g%% of the canvas width is less than %.2g%%", size, lim.MinSize)
+			}
+			want := lim.Contrast
+			if size >= lim.LargeSize {
+				want = lim.LargeContrast
+			}
+			if b.color == "" {
+				b.color = s.Fg
+			}
+			if b.color == "" {
+				b.color = "black"
+			}
+			if fg, ok := ParseColor(b.color); ok {
+				for _, bg := range backgrounds(s, boxes, b.x, b.y+b.fs/3, cw, ch) {
+					if c := Contrast(fg.Blend(bg, b.opacity), bg); c < want {
+						report("contrast", b.name, "contrast %.2f:1 of %s on %s is less than %.2g:1", c, b.color, bg, want)
+						break
+					}
+				}
+			}
+			if b.rotated {
+				continue
+			}
+			if b.x0 < 0 || b.x1 > cw || b.bottom < 0 || b.top > ch {
+				report("outside", b.name, "text extends outside the canvas")
+			}
+			for _, o := range boxes[i+1:] {
+				if !o.rotated && o.text != "" && b.x0 < o.x1 && o.x0 < b.x1 && b.bottom < o.top && o.bottom < b.top {
+					report("overlap", b.name, "overlaps %s", o.name)
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// backgrounds returns the colors behind a point: the topmost filled text box containing it, or the topmost
+// opaque shape, or the slide background, which is both ends of a gradient
+func backgrounds(s Slide, boxes []textbox, x, y, cw, ch float64) []RGB {
+	bg, ok := ParseColor(s.Bg)
+	if !ok {
+		bg = RGB{255, 255, 255}
+	}
+	slide := []RGB{bg}
+	if g1, ok1 := ParseColor(s.Gradcolor1); ok1 {
+		if g2, ok2 := ParseColor(s.Gradcolor2); ok2 {
+			slide = []RGB{g1, g2}
+		}
+	}
+	// shapes are drawn after the background, ellipses over rectangles
+	shape := func(d Dimension, inside func(dx, dy, w, h float64) bool) ([]RGB, bool) {
+		w := d.Wp / 100 * cw
+		h := d.Hp / 100 * ch
+		if d.Hr != 0 {
+			h = d.Hr / 100 * w
+		}
+		if !inside(x-d.Xp/100*cw, y-d.Yp/100*ch, w/2, h/2) {
+			return nil, false
+		}
+		color := d.Color
+		if color == "" {
+			color = "rgb(127,127,127)"
+		}
+		c, ok := ParseColor(color)
+		if !ok {
+			return nil, false
+		}
+		colors := make([]RGB, len(slide))
+		for i, bg := range slide {
+			colors[i] = c.Blend(bg, opacity(d.Opacity))
+		}
+		return colors, true
+	}
+	// text boxes are drawn after the shapes, each behind its text
+	for i := len(boxes) - 1; i >= 0; i-- {
+		b := boxes[i]
+		if b.fill == "" || x < b.x0 || x > b.x1 || y < b.bottom || y > b.top {
+			continue
+		}
+		if c, ok := ParseColor(b.fill); ok {
+			colors := make([]RGB, len(slide))
+			for i, bg := range slide {
+				colors[i] = c.Blend(bg, b.opacity)
+			}
+			return colors
+		}
+	}
+	for i := len(s.Ellipse) - 1; i >= 0; i-- {
+		if c, ok := shape(s.Ellipse[i].Dimension, func(dx, dy, rx, ry float64) bool {
+			return rx > 0 && ry > 0 && (dx*dx)/(rx*rx)+(dy*dy)/(ry*ry) <= 1
+		}); ok {
+			return c
+		}
+	}
+	for i := len(s.Rect) - 1; i >= 0; i-- {
+		if c, ok := shape(s.Rect[i].Dimension, func(dx, dy, hw, hh float64) bool {
+			return math.Abs(dx) <= hw && math.Abs(dy) <= hh
+		}); ok {
+			return c
+		}
+	}
+	return slide
+}
+
+// opacity converts an opacity percentage to a fraction, as the renderers do: zero is opaque
+func opacity(op float64) float64 {
+	switch {
+	case op < 0:
+		return 0
+	case op == 0 || op > 100:
+		return 1
+	}
+	return op / 100
+}
+
+// charwidth is the average width of a character, relative to the font size
+func charwidth(font string) float64 {
+	if font == "mono" {
+		return 0.6
+	}
+	return 0.5
+}
+
+// extent returns the left and right of text of width w, aligned to x
+func extent(x, w float64, align string, rtl bool) (float64, float64) {
+	switch Align(align, rtl) {
+	case "center", "middle", "mid", "c":
+		return x - w/2, x + w/2
+	case "right", "end", "e":
+		return x - w, x
+	}
+	return x, x + w
+}
+
+// measuretext estimates the extent of a text element
+func measuretext(t Text, text, name string, cw, ch float64) textbox {
+	b := textbox{name: name, color: t.Color, text: strings.TrimSpace(text), rotated: t.Rotation != 0}
+	b.x, b.y, b.fs = t.Xp/100*cw, t.Yp/100*ch, t.Sp/100*cw
+	b.opacity = opacity(t.Opacity)
+	lp := t.Lp
+	if lp == 0 {
+		lp = 1.4
+	}
+	lines := strings.Split(text, "\n")
+	var w float64
+	for _, line := range lines {
+		if lw := float64(utf8.RuneCountInString(line)) * b.fs * charwidth(t.Font); lw > w {
+			w = lw
+		}
+	}
+	rtl := RTL(text, t.Dir)
+	// text boxes are placed by their top left corner, their text wrapped within the padding
+	if t.IsBox() {
+		w, pad := Pwidth(t.Wp, cw, cw/2), t.Padding/100*cw
+		letter := b.fs * charwidth(t.Font)
+		wrapped := WrapWithin(text, w-pad*2, letter, func(s string) float64 { return float64(utf8.RuneCountInString(s)) * letter })
+		h, baseline := t.Box(0, t.Hp/100*ch, pad, wrapped, b.fs, b.fs*lp)
+		b.x0, b.x1, b.top, b.bottom = b.x, b.x+w, b.y, b.y-h
+		b.x, b.y, b.fill = b.x+pad, b.y-baseline, t.Fill
+		return b
+	}
+	switch t.Type {
+	case "block":
+		bw := Pwidth(t.Wp, cw, cw/2)
+		n := math.Ceil(float64(utf8.RuneCountInString(text)) * b.fs * charwidth(t.Font) / bw)
+		lines = make([]string, int(math.Max(n, 1)))
+		w = bw
+		b.x0, b.x1 = extent(b.x, w, "", rtl)
+	case "code":
+		b.x0, b.x1 = b.x-b.fs, cw-20-b.fs
+	default:
+		b.x0, b.x1 = extent(b.x, w, t.Align, rtl)
+	}
+	b.top = b.y + b.fs*0.8
+	b.bottom = b.y - float64(len(lines)-1)*b.fs*lp - b.fs*0.2
+	return b
+}
+
+// measurelist estimates the extent of a list element
+func measurelist(l List, name string, cw, ch float64) textbox {
+	b := textbox{name: name, color: l.Color, rotated: l.Rotation != 0}
+	b.x, b.y, b.fs = l.Xp/100*cw, l.Yp/100*ch, l.Sp/100*cw
+	b.opacity = opacity(l.Opacity)
+	lp := l.Lp
+	if lp == 0 {
+		lp = 2
+	}
+	var w float64
+	for i, li := range l.Li {
+		text := strings.TrimSpace(li.ListText)
+		if l.Type == "number" {
+			text = fmt.Sprintf("%d. %s", i+1, text)
+		}
+		if lw := float64(utf8.RuneCountInString(text)) * b.fs * charwidth(l.Font); lw > w {
+			w = lw
+		}
+		b.text += text
+	}
+	w = math.Min(w, Pwidth(l.Wp, cw, 0.95*cw))
+	if l.Type == "bullet" {
+		w += b.fs * 1.2
+	}
+	rtl := len(l.Li) > 0 && RTL(l.Li[0].ListText, l.Dir)
+	b.x0, b.x1 = extent(b.x, w, l.Align, rtl)
+	b.top = b.y + b.fs*0.8
+	b.bottom = b.y - float64(len(l.Li)-1)*b.fs*lp - b.fs*0.2
+	return b
+}
@@ -0,0 +1,181 @@
+package deck
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ListEntry is a list item as it is drawn: numbered and styled by its level
+type ListEntry struct {
+	ListItem
+	Bullet      string  // for bulleted items: disc, circle, square, dash, checkbox, checked, image, or a glyph drawn as text
+	Number      string  // for numbered items: the label, i.e. "2." or "b)"
+	Indent      float64 // indentation from the list's position, in multiples of the list's text size
+	Scale       float64 // size of the item's text, relative to the list's text size
+	BulletFont  string  // font of a glyph bullet, the list's font if not set
+	BulletColor string  // color of the bullet, the list's color if not set
+	BulletImage string  // image of an image bullet
+	BulletScale float64 // size of the bullet, relative to the list's text size
+}
+
+// default styles of the levels of lists, repeated for deeper levels
+var (
+	levelbullets = []string{"disc", "circle", "square", "dash"}
+	levelnumbers = []string{"1.", "a)", "i.", "A."}
+)
+
+const (
+	levelindent = 2.0  // indentation of each level, in multiples of the text size
+	levelscale  = 85.0 // size of each level, a percentage of the level above
+)
+
+// Entries returns the items of the list as drawn. Items are numbered within their level,
+// restarting after an item of a level above, with the formats of the list's numbers attribute
+// ("1." for 1, 2, 3, "a)" for a, b, c, "i." or "I." for roman numerals, "A." for A, B, C);
+// bullets are the shapes or glyphs of the bullets attribute, or the bullet image, unless the item
+// has a bullet of its own. Items of check lists, and items with a checked attribute, are checkboxes.
+// Each level is indented, and sized, relative to the one above.
+func (l List) Entries() []ListEntry {
+	bullets, numbers := strings.Fields(l.Bullets), strings.Fields(l.Numbers)
+	if len(bullets) == 0 {
+		bullets = levelbullets
+	}
+	if len(numbers) == 0 {
+		numbers = levelnumbers
+	}
+	indent := levelindent
+	if l.Indent > 0 && l.Sp > 0 {
+		indent = l.Indent / l.Sp
+	}
+	scale := levelscale
+	if l.Scale > 0 {
+		scale = l.Scale
+	}
+	var counts []int
+	entries := make([]ListEntry, len(l.Li))
+	for i, item := range l.Li {
+		level := item.Level
+		if level < 0 {
+			level = 0
+		}
+		for len(counts) <= level {
+			counts = append(counts, 0)
+		}
+		counts = counts[:level+1]
+		counts[level]++
+		e := ListEntry{ListItem: item, Indent: indent * float64(level), Scale: 1, BulletFont: l.BulletFont, BulletColor: l.BulletColor}
+		e.TextStyle = item.TextStyle.Inherit(l.TextStyle)
+		for j := 0; j < level; j++ {
+			e.Scale *= scale / 100
+		}
+		e.BulletScale = e.Scale
+		if l.BulletSize > 0 {
+			e.BulletScale *= l.BulletSize / 100
+		}
+		ltype := item.Type
+		if ltype == "" {
+			ltype = l.Type
+		}
+		switch {
+		case ltype == "check" || item.Checked != "":
+			e.Bullet = "checkbox"
+			if on(item.Checked) {
+				e.Bullet = "checked"
+			}
+		case item.Bullet != "":
+			e.Bullet = item.Bullet
+		case ltype == "bullet" && l.BulletImage != "":
+			e.Bullet, e.BulletImage = "image", l.BulletImage
+		case ltype == "bullet":
+			e.Bullet = bullets[level%len(bullets)]
+		case ltype == "number":
+			e.Number = Numeral(counts[level], numbers[level%len(numbers)])
+		}
+		entries[i] = e
+	}
+	return entries
+}
+
+// Numeral formats a number: the first of the characters 1, a, A, i, I in the format
+// is replaced by the number as a decimal, letter, or roman numeral, i.e. "(a)" for 2 is "(b)".
+func Numeral(n int, format string) string {
+	i := strings.IndexAny(format, "1aAiI")
+	if i < 0 {
+		return strconv.Itoa(n) + format
+	}
+	var s string
+	switch format[i] {
+	case 'a':
+		s = letters(n)
+	case 'A':
+		s = strings.ToUpper(letters(n))
+	case 'i':
+		s = strings.ToLower(roman(n))
+	case 'I':
+		s = roman(n)
+	default:
+		s = strconv.Itoa(n)
+	}
+	return format[:i] + s + format[i+1:]
+}
+
+// letters numbers with letters: a-z, then aa, ab, ...
+func letters(n int) string {
+	var s []byte
+	for ; n > 0; n = (n - 1) / 26 {
+		s = append([]byte{byte('a' + (n-1)%26)}, s...)
+	}
+	return string(s)
+}
+
+// roman makes roman numerals, for numbers from 1 to 3999
+func roman(n int) string {
+	if n <= 0 || n >= 4000 {
+		return strconv.Itoa(n)
+	}
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	symbols := []string{"M", "CM", "D", "CD", "C", "XC", "L", "XL", "X", "IX", "V", "IV", "I"}
+	var b strings.Builder
+	for i, v := range values {
+		for ; n >= v; n -= v {
+			b.WriteString(symbols[i])
+		}
+	}
+	return b.String()
+}
+
+// nest flattens the lists nested in list items into the items of the enclosing lists,
+// as items of the next level with the type of the nested list
+func (d *Deck) nest() {
+	for i := range d.Slide {
+		for j := range d.Slide[i].List {
+			d.Slide[i].List[j].Li = flatten(d.Slide[i].List[j].Li, 0, "")
+		}
+	}
+	for _, o := range []*Overlay{&d.Header, &d.Footer} {
+		for j := range o.List {
+			o.List[j].Li = flatten(o.List[j].Li, 0, "")
+		}
+	}
+}
+
+// flatten returns the items, with the items of their nested lists following them
+func flatten(items []ListItem, level int, ltype string) []ListItem {
+	var flat []ListItem
+	for _, item := range items {
+		nested := item.List
+		item.List = nil
+		item.Level += level
+		if item.Type == "" {
+			item.Type = ltype
+		}
+		if len(nested) > 0 {
+			item.ListText = strings.TrimSpace(item.ListText)
+		}
+		flat = append(flat, item)
+		for _, l := range nested {
+			flat = append(flat, flatten(l.Li, item.Level+1, l.Type)...)
+		}
+	}
+	return flat
+}
@@ -0,0 +1,568 @@
+package deck
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Span is a run of text of one style, made from the inline markup of markdown:
+// **strong**, *emphasis*, `code` and [links](url)
+type Span struct {
+	Text     string
+	Strong   bool   // drawn in bold
+	Emphasis bool   // drawn in italics
+	Code     bool   // drawn in the mono font
+	Link     string // URL, or "#n" for slide n of the deck
+}
+
+// Word is a word of styled text, made of the spans it spans
+type Word []Span
+
+// Face returns the font of a span of text in the font: code is mono, and strong and emphasized text
+// use the bold and italic versions of the font, declared as fonts named with -bold, -italic or -bolditalic
+// (i.e. "sans-bold"), if has reports that the deck has them. Otherwise, Face reports that the text must be
+// made bold, or slanted, by the renderer.
+func (s Span) Face(font string, has func(string) bool) (face string, bold, italic bool) {
+	if s.Code {
+		font = "mono"
+	}
+	switch {
+	case s.Strong && s.Emphasis && has(font+"-bolditalic"):
+		return font + "-bolditalic", false, false
+	case s.Strong && has(font+"-bold"):
+		return font + "-bold", false, s.Emphasis
+	case s.Emphasis && has(font+"-italic"):
+		return font + "-italic", s.Strong, false
+	}
+	return font, s.Strong, s.Emphasis
+}
+
+// mdpunct are the characters that may be escaped with a backslash
+const mdpunct = "\\`*_{}[]()#+-.!|<>"
+
+// Inline returns the spans of text with markdown inline markup
+func Inline(s string) []Span {
+	return inline(s, Span{})
+}
+
+// inline returns the spans of text, in the style of the text around it
+func inline(s string, style Span) []Span {
+	var spans []Span
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			sp := style
+			sp.Text = text.String()
+			spans = appendspan(spans, sp)
+			text.Reset()
+		}
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(mdpunct, s[i+1]) >= 0:
+			text.WriteByte(s[i+1])
+			i += 2
+			continue
+		case c == '`':
+			if j := strings.IndexByte(s[i+1:], '`'); j >= 0 {
+				flush()
+				sp := style
+				sp.Code, sp.Text = true, s[i+1:i+1+j]
+				spans = appendspan(spans, sp)
+				i += j + 2
+				continue
+			}
+		case (c == '*' || c == '_') && opens(s, i):
+			n := 1
+			if i+1 < len(s) && s[i+1] == c {
+				n = 2
+			}
+			if j := closes(s, i+n, c, n); j >= 0 {
+				flush()
+				st := style
+				if n == 2 {
+					st.Strong = true
+				} else {
+					st.Emphasis = true
+				}
+				for _, sp := range inline(s[i+n:j], st) {
+					spans = appendspan(spans, sp)
+				}
+				i = j + n
+				continue
+			}
+		case c == '[':
+			if j := strings.Index(s[i:], "]("); j > 0 {
+				if k := strings.IndexByte(s[i+j+2:], ')'); k >= 0 {
+					flush()
+					st := style
+					st.Link = strings.TrimSpace(s[i+j+2 : i+j+2+k])
+					for _, sp := range inline(s[i+1:i+j], st) {
+						spans = appendspan(spans, sp)
+					}
+					i += j + k + 3
+					continue
+				}
+			}
+		}
+		text.WriteByte(c)
+		i++
+	}
+	flush()
+	return spans
+}
+
+// opens reports whether the * or _ at i may open emphasis: it is followed by text,
+// and an underscore is not within a word, as in snake_case
+func opens(s string, i int) bool {
+	j := i
+	for j < len(s) && s[j] == s[i] {
+		j++
+	}
+	if j == len(s) || s[j] == ' ' || s[j] == '\t' || s[j] == '\n' {
+		return false
+	}
+	if s[i] == '_' && i > 0 {
+		r, _ := utf8.DecodeLastRuneInString(s[:i])
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}
+	return true
+}
+
+// closes returns the position of the n delimiters c that close emphasis opened before from, or -1
+func closes(s string, from int, c byte, n int) int {
+	for j := from; j < len(s); j++ {
+		switch {
+		case s[j] == '\\':
+			j++
+		case s[j] == '`':
+			if k := strings.IndexByte(s[j+1:], '`'); k >= 0 {
+				j += k + 1
+			}
+		case s[j] == c:
+			run := 1
+			for j+run < len(s) && s[j+run] == c {
+				run++
+			}
+			if j > from && s[j-1] != ' ' && run == n {
+				return j
+			}
+			j += run - 1
+		}
+	}
+	return -1
+}
+
+// appendspan appends a span, joined to the last span if they have the same style
+func appendspan(spans []Span, s Span) []Span {
+	if n := len(spans); n > 0 {
+		last := spans[n-1]
+		last.Text = s.Text
+		if last == s {
+			spans[n-1].Text += s.Text
+			return spans
+		}
+	}
+	return append(spans, s)
+}
+
+// Plain returns the text of spans, without their markup
+func Plain(spans []Span) string {
+	var b strings.Builder
+	for _, s := range spans {
+		b.WriteString(s.Text)
+	}
+	return b.String()
+}
+
+// Words splits spans into words at the spaces between them; a word may be made of several spans
+func Words(spans []Span) []Word {
+	var words []Word
+	var word Word
+	for _, s := range spans {
+		start := 0
+		for i, r := range s.Text {
+			if !wrapspace(r) {
+				continue
+			}
+			if i > start {
+				part := s
+				part.Text = s.Text[start:i]
+				word = append(word, part)
+			}
+			if len(word) > 0 {
+				words = append(words, word)
+				word = nil
+			}
+			start = i + utf8.RuneLen(r)
+		}
+		if start < len(s.Text) {
+			part := s
+			part.Text = s.Text[start:]
+			word = append(word, part)
+		}
+	}
+	if len(word) > 0 {
+		words = append(words, word)
+	}
+	return words
+}
+
+// WrapWords breaks styled words into lines that fit the width, as WrapWithin breaks text
+func WrapWords(words []Word, width, space float64, measure func(Word) float64) [][]Word {
+	var lines [][]Word
+	var line []Word
+	x := 0.0
+	for _, word := range words {
+		w := measure(word)
+		if len(line) > 0 && x+w > width {
+			lines = append(lines, line)
+			line = nil
+			x = 0
+		}
+		line = append(line, word)
+		x += w + space
+	}
+	return append(lines, line)
+}
+
+// markdown block elements
+var (
+	mdheading = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mditem    = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.*)$`)
+	mdfence   = regexp.MustCompile("^\\s*(```|~~~)\\s*([^\\s`]*)")
+)
+
+// headingscale is the size of headings of each level, relative to the size of the text
+var headingscale = []float64{2, 1.6, 1.3, 1.15, 1, 1}
+
+// mdblock is a block of markdown text: a heading, paragraph, list or code block
+type mdblock struct {
+	kind  string   // heading, paragraph, list or code
+	level int      // level of a heading, from 1
+	text  string   // text of a heading or paragraph
+	lines []string // lines of code
+	lang  string   // language of code
+	items []mdentry
+}
+
+// mdentry is an item of a markdown list
+type mdentry struct {
+	level  int
+	number bool
+	text   string
+}
+
+// blocks splits markdown text into its blocks. The indentation common to the lines is removed first,
+// as text in a deck is indented with its markup.
+func blocks(s string) []mdblock {
+	lines := dedent(strings.Split(strings.Replace(s, "\t", "    ", -1), "\n"))
+	var blocks []mdblock
+	cur := -1         // the block that following lines may continue
+	var indents []int // indentation of the levels of the current list
+	continues := func(kind string) bool { return cur >= 0 && blocks[cur].kind == kind }
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			if !continues("list") {
+				cur = -1
+			}
+			continue
+		}
+		if m := mdfence.FindStringSubmatch(line); m != nil {
+			code := mdblock{kind: "code", lang: m[2]}
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), m[1]); i++ {
+				code.lines = append(code.lines, lines[i])
+			}
+			blocks = append(blocks, code)
+			cur = -1
+			continue
+		}
+		if m := mdheading.FindStringSubmatch(line); m != nil {
+			blocks = append(blocks, mdblock{kind: "heading", level: len(m[1]), text: m[2]})
+			cur = -1
+			continue
+		}
+		if m := mditem.FindStringSubmatch(line); m != nil {
+			n := len(m[1])
+			number := m[2] != "-" && m[2] != "*" && m[2] != "+"
+			// a list of the other kind of item starts a new list
+			if continues("list") && n <= indents[0] && number != blocks[cur].items[0].number {
+				cur = -1
+			}
+			if !continues("list") {
+				blocks = append(blocks, mdblock{kind: "list"})
+				cur = len(blocks) - 1
+				indents = indents[:0]
+			}
+			for len(indents) > 0 && indents[len(indents)-1] > n {
+				indents = indents[:len(indents)-1]
+			}
+			if len(indents) == 0 || indents[len(indents)-1] < n {
+				indents = append(indents, n)
+			}
+			blocks[cur].items = append(blocks[cur].items, mdentry{level: len(indents) - 1, number: number, text: m[3]})
+			continue
+		}
+		switch {
+		case continues("list") && (strings.TrimSpace(lines[i-1]) != "" || line != strings.TrimLeft(line, " ")):
+			// lines following an item, or indented, continue it
+			items := blocks[cur].items
+			items[len(items)-1].text += " " + trimmed
+		case continues("paragraph"):
+			blocks[cur].text += " " + trimmed
+		default:
+			blocks = append(blocks, mdblock{kind: "paragraph", text: trimmed})
+			cur = len(blocks) - 1
+		}
+	}
+	return blocks
+}
+
+// dedent removes the indentation common to the lines that are not blank, and the blank lines at the start and end
+func dedent(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	common := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " "))
+		if common < 0 || n < common {
+			common = n
+		}
+	}
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if len(line) >= common && common > 0 {
+			line = line[common:]
+		}
+		out[i] = line
+	}
+	return out
+}
+
+// mdcharwidth is the width of a letter, relative to the size of text, taken to estimate the lines of wrapped
+// markdown text; it is on the wide side, so that the blocks are placed apart
+const mdcharwidth = 0.6
+
+// mdestimate returns the space between words, and the measure of words, estimated from the width of letters
+func mdestimate(font string, fs float64, ts TextStyle) (float64, func(string) float64) {
+	letter := fs * mdcharwidth
+	return letter, func(s string) float64 { return letter * float64(utf8.RuneCountInString(s)) }
+}
+
+// flowing places a block made from markdown text in the flow of the blocks made from the text
+type flowing struct {
+	flow  int     // the markdown text, from 1; 0 for text and lists not made from markdown
+	block int     // the place of the block in the flow
+	space float64 // the size of the markdown text, which spaces the blocks
+}
+
+// Markdown lays out markdown text: its headings and paragraphs become block text, its lists
+// become lists, and code blocks become code, placed down from the text's position, one below the other,
+// in the canvas of width cw and height ch, by an estimate of the lines they wrap into (see Flow).
+// The styles of inline markup are kept as spans.
+// Markdown text is as wide as the canvas within margins of its x position, if its width is not given.
+func (t Text) Markdown(s string, cw, ch int) ([]Text, []List) {
+	sp, lp, wp := t.Sp, t.Lp, t.Wp
+	if sp <= 0 {
+		sp = 2
+	}
+	if lp <= 0 {
+		lp = 1.4
+	}
+	if wp <= 0 {
+		wp = 100 - t.Xp*2
+		if wp < 20 {
+			wp = 20
+		}
+	}
+	var texts []Text
+	var lists []List
+	base := t.CommonAttr
+	base.Type, base.Sp, base.Lp, base.Rotation, base.Link = "", 0, lp, 0, ""
+	for i, b := range blocks(s) {
+		flow := flowing{flow: 1, block: i, space: sp}
+		switch b.kind {
+		case "heading":
+			spans := Inline(b.text)
+			for j := range spans {
+				spans[j].Strong = true
+			}
+			e := Text{CommonAttr: base, Wp: wp, Tdata: Plain(spans), Spans: spans, flow: flow}
+			e.Type, e.Sp, e.Yp = "block", sp*headingscale[b.level-1], t.Yp
+			texts = append(texts, e)
+		case "paragraph":
+			spans := Inline(b.text)
+			e := Text{CommonAttr: base, Wp: wp, Tdata: Plain(spans), Spans: spans, flow: flow}
+			e.Type, e.Sp, e.Yp = "block", sp, t.Yp
+			texts = append(texts, e)
+		case "code":
+			e := Text{CommonAttr: base, Wp: wp - sp, Tdata: strings.Join(b.lines, "\n"), flow: flow}
+			e.Type, e.Sp, e.Xp, e.Yp, e.Font = "code", sp, t.Xp+sp, t.Yp, "mono"
+			e.Lang, e.Scheme = b.lang, t.Scheme
+			texts = append(texts, e)
+		case "list":
+			l := List{CommonAttr: base, Wp: wp - sp*1.2, flow: flow}
+			l.Type, l.Sp, l.Yp = "bullet", sp, t.Yp
+			if b.items[0].number {
+				l.Type = "number"
+			}
+			for _, it := range b.items {
+				spans := Inline(it.text)
+				item := ListItem{Level: it.level, ListText: Plain(spans), Spans: spans, Type: "bullet"}
+				if it.number {
+					item.Type = "number"
+				}
+				l.Li = append(l.Li, item)
+			}
+			lists = append(lists, l)
+		}
+	}
+	// heights are percentages of the canvas height, sizes of the canvas width
+	w, h := float64(cw), float64(ch)
+	if cw <= 0 || ch <= 0 {
+		w, h = 100, 100
+	}
+	Flow(texts, lists, w, h, mdestimate)
+	return texts, lists
+}
+
+// Flow places the blocks made from markdown text one below the other, from the position of the first,
+// each as many lines down as it wraps into in the canvas of width cw and height ch. measures returns the space
+// between words and the measure of words of a font, size and style. Markdown places the blocks by an estimate;
+// renderers flow them again by the metrics of their fonts, in their canvas. Other text and lists are left as they are.
+func Flow(texts []Text, lists []List, cw, ch float64, measures func(font string, fs float64, ts TextStyle) (float64, func(string) float64)) {
+	type block struct {
+		flowing
+		yp     *float64 // the position of the first line
+		size   float64  // the size of the first line
+		height float64  // the height of the lines
+	}
+	// px converts a percentage of the canvas width to a length, y a length to a percentage of the canvas height
+	px := func(p float64) float64 { return p * cw / 100 }
+	y := func(l float64) float64 { return l * 100 / ch }
+	// lines returns the number of lines of words wrapped to the width
+	lines := func(words []Word, width float64, font string, fs float64, ts TextStyle) int {
+		space, measure := measures(font, fs, ts)
+		return len(WrapWords(words, width, space, func(w Word) float64 { return measure(Plain(w)) }))
+	}
+	flows := map[int][]block{}
+	for i := range texts {
+		t := &texts[i]
+		if t.flow.flow == 0 {
+			continue
+		}
+		fs := px(t.Sp)
+		n := lines(Words(t.Spans), px(t.Wp), t.Font, fs, t.TextStyle)
+		if t.Type == "code" {
+			n = len(strings.Split(t.Tdata, "\n"))
+		}
+		flows[t.flow.flow] = append(flows[t.flow.flow], block{t.flow, &t.Yp, fs, float64(n) * t.Lp * fs})
+	}
+	for i := range lists {
+		l := &lists[i]
+		if l.flow.flow == 0 {
+			continue
+		}
+		fs, height := px(l.Sp), 0.0
+		for _, e := range l.Entries() {
+			spans := e.Spans
+			if len(spans) == 0 {
+				spans = []Span{{Text: e.ListText}}
+			}
+			if len(e.Number) > 0 {
+				spans = append([]Span{{Text: e.Number + " "}}, spans...)
+			}
+			font := l.Font
+			if len(e.Font) > 0 {
+				font = e.Font
+			}
+			ifs := fs * e.Scale
+			height += float64(lines(Words(spans), px(l.Wp)-fs*e.Indent, font, ifs, e.TextStyle)) * l.Lp * ifs
+		}
+		flows[l.flow.flow] = append(flows[l.flow.flow], block{l.flow, &l.Yp, fs, height})
+	}
+	for _, flow := range flows {
+		sort.Slice(flow, func(i, j int) bool { return flow[i].block < flow[j].block })
+		// top is the top of the next block, a percentage of the canvas height
+		top := *flow[0].yp + y(flow[0].size)
+		for i, b := range flow {
+			if i > 0 {
+				top -= y(px(b.space)) / 2
+			}
+			*b.yp = top - y(b.size)
+			top -= y(b.height)
+		}
+	}
+}
+
+// markdown lays out the markdown text of the slides, and of the header and footer, as text and lists,
+// and makes the inline markup of list items with type="markdown" their spans
+func (d *Deck) markdown() {
+	// the blocks of each markdown text are a flow of their own, on every slide they are on
+	flows := 0
+	layout := func(texts []Text, lists []List, cw, ch int) ([]Text, []List) {
+		var out []Text
+		for _, t := range texts {
+			if t.Type != "markdown" {
+				out = append(out, t)
+				continue
+			}
+			mt, ml := t.Markdown(d.content(t), cw, ch)
+			flows++
+			for i := range mt {
+				mt[i].flow.flow = flows
+			}
+			for i := range ml {
+				ml[i].flow.flow = flows
+			}
+			out = append(out, mt...)
+			lists = append(lists, ml...)
+		}
+		for i := range lists {
+			for j, item := range lists[i].Li {
+				if item.Type == "markdown" {
+					item.Spans = Inline(strings.TrimSpace(item.ListText))
+					item.ListText, item.Type = Plain(item.Spans), ""
+					lists[i].Li[j] = item
+				}
+			}
+		}
+		return out, lists
+	}
+	for i := range d.Slide {
+		s := &d.Slide[i]
+		c := d.canvas(*s)
+		s.Text, s.List = layout(s.Text, s.List, c.Width, c.Height)
+	}
+	for _, o := range []*Overlay{&d.Header, &d.Footer} {
+		o.Text, o.List = layout(o.Text, o.List, d.Canvas.Width, d.Canvas.Height)
+	}
+}
+
+// spanned returns spans with their text replaced, other than code
+func spanned(spans []Span, replace func(string) string) []Span {
+	if spans == nil {
+		return nil
+	}
+	out := make([]Span, len(spans))
+	for i, s := range spans {
+		if !s.Code {
+			s.Text = replace(s.Text)
+		}
+		out[i] = s
+	}
+	return out
+}
@@ -0,0 +1,124 @@
+package deck
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// Meta is a custom property of a deck
+// <meta name="recording" value="https://example.com/talk.mp4"/>
+type Meta struct {
+	Name  string `xml:"name,attr,omitempty"`
+	Value string `xml:"value,attr,omitempty"`
+}
+
+// Properties returns the metadata of the deck that is set: the standard elements
+// (named as in the markup: title, creator, subject, publisher, description, date,
+// keywords, language, license, version, event, venue), followed by the custom properties.
+func (d Deck) Properties() []Meta {
+	var props []Meta
+	for _, m := range []Meta{
+		{"title", d.Title},
+		{"creator", d.Creator},
+		{"subject", d.Subject},
+		{"publisher", d.Publisher},
+		{"description", d.Description},
+		{"date", d.Date},
+		{"keywords", d.Keywords},
+		{"language", d.Language},
+		{"license", d.License},
+		{"version", d.Version},
+		{"event", d.Event},
+		{"venue", d.Venue},
+	} {
+		m.Value = strings.TrimSpace(m.Value)
+		if m.Value != "" {
+			props = append(props, m)
+		}
+	}
+	for _, m := range d.Meta {
+		if m.Name != "" {
+			props = append(props, m)
+		}
+	}
+	return props
+}
+
+// KeywordList returns the deck's keywords
+func (d Deck) KeywordList() []string {
+	var kw []string
+	for _, k := range strings.Split(d.Keywords, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			kw = append(kw, k)
+		}
+	}
+	return kw
+}
+
+// Namespace is the XML namespace of the deck properties in RDF metadata
+const Namespace = "http://github.com/ajstarks/deck/ns/1.0/"
+
+// RDF returns the deck's metadata as an RDF element, using Dublin Core for the standard elements
+// and the deck namespace for the version, event, venue, and custom properties.
+func (d Deck) RDF() string {
+	var b strings.Builder
+	esc := func(s string) string {
+		var e strings.Builder
+		xml.EscapeText(&e, []byte(strings.TrimSpace(s)))
+		return e.String()
+	}
+	// container writes a property holding an RDF container (Alt, Bag or Seq) of values
+	container := func(prop, kind string, values ...string) {
+		var items []string
+		for _, v := range values {
+			if v = strings.TrimSpace(v); v == "" {
+				continue
+			}
+			lang := ""
+			if kind == "Alt" {
+				lang = ` xml:lang="x-default"`
+			}
+			items = append(items, "<rdf:li"+lang+">"+esc(v)+"</rdf:li>")
+		}
+		if len(items) > 0 {
+			b.WriteString("<" + prop + "><rdf:" + kind + ">" + strings.Join(items, "") + "</rdf:" + kind + "></" + prop + ">\n")
+		}
+	}
+	simple := func(prop, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			b.WriteString("<" + prop + ">" + esc(value) + "</" + prop + ">\n")
+		}
+	}
+	b.WriteString(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` + "\n")
+	b.WriteString(`<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:pdf="http://ns.adobe.com/pdf/1.3/" xmlns:deck="` + Namespace + `">` + "\n")
+	container("dc:title", "Alt", d.Title)
+	container("dc:creator", "Seq", d.Creator)
+	container("dc:description", "Alt", d.Description)
+	container("dc:subject", "Bag", d.KeywordList()...)
+	container("dc:publisher", "Bag", d.Publisher)
+	container("dc:date", "Seq", d.Date)
+	container("dc:language", "Bag", d.Language)
+	container("dc:rights", "Alt", d.License)
+	simple("pdf:Keywords", strings.Join(d.KeywordList(), ", "))
+	simple("deck:version", d.Version)
+	simple("deck:event", d.Event)
+	simple("deck:venue", d.Venue)
+	var custom []string
+	for _, m := range d.Meta {
+		if m.Name != "" {
+			custom = append(custom, `<rdf:li rdf:parseType="Resource"><deck:name>`+esc(m.Name)+`</deck:name><deck:value>`+esc(m.Value)+`</deck:value></rdf:li>`)
+		}
+	}
+	if len(custom) > 0 {
+		b.WriteString("<deck:properties><rdf:Bag>" + strings.Join(custom, "") + "</rdf:Bag></deck:properties>\n")
+	}
+	b.WriteString("</rdf:Description>\n</rdf:RDF>\n")
+	return b.String()
+}
+
+// XMP returns the deck's metadata as an XMP packet
+func (d Deck) XMP() []byte {
+	return []byte("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n" +
+		`<x:xmpmeta xmlns:x="adobe:ns:meta/">` + "\n" + d.RDF() + "</x:xmpmeta>\n" +
+		`<?xpacket end="w"?>`)
+}
@@ -0,0 +1,119 @@
+package deck
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Item is an element of a slide, as presented to a screen reader
+type Item struct {
+	Kind   string  // element name: text, textpath, list, image, qrcode, rect, ellipse, arc, curve, line, polygon
+	Index  int     // index of the element within its kind
+	Xp, Yp float64 // position of the element
+	Text   string  // text content, list items one per line, or text alternative
+	Title  string  // short title of a graphic
+}
+
+// ReadingOrder returns the content of slide n in reading order: from the top of the slide down,
+// and left to right at the same height. Images are read by their text alternative (or caption);
+// other graphics without alt or title are decorative, and are left out.
+func (d Deck) ReadingOrder(n int) []Item {
+	if n < 0 || n >= len(d.Slide) {
+		return nil
+	}
+	s := d.Slide[n]
+	var items []Item
+	graphic := func(kind string, i int, xp, yp float64, alt, title string) {
+		if alt != "" || title != "" {
+			items = append(items, Item{Kind: kind, Index: i, Xp: xp, Yp: yp, Text: alt, Title: title})
+		}
+	}
+	for i, t := range s.Text {
+		items = append(items, Item{Kind: "text", Index: i, Xp: t.Xp, Yp: t.Yp, Text: strings.TrimSpace(d.content(t))})
+	}
+	for i, t := range s.TextPath {
+		xp, yp := t.Xp, t.Yp
+		if t.IsCurve() {
+			xp, yp = t.Xp1, t.Yp1
+		}
+		items = append(items, Item{Kind: "textpath", Index: i, Xp: xp, Yp: yp, Text: t.Text()})
+	}
+	for i, l := range s.List {
+		li := make([]string, len(l.Li))
+		for j, item := range l.Li {
+			li[j] = strings.TrimSpace(item.ListText)
+		}
+		items = append(items, Item{Kind: "list", Index: i, Xp: l.Xp, Yp: l.Yp, Text: strings.Join(li, "\n")})
+	}
+	for i, im := range s.Image {
+		alt := im.Alt
+		if alt == "" {
+			alt = im.Caption
+		}
+		graphic("image", i, im.Xp, im.Yp, alt, im.Title)
+	}
+	for i, r := range s.Rect {
+		graphic("rect", i, r.Xp, r.Yp, r.Alt, r.Title)
+	}
+	for i, e := range s.Ellipse {
+		graphic("ellipse", i, e.Xp, e.Yp, e.Alt, e.Title)
+	}
+	// a QR code is read by its text alternative, or its data
+	for i, q := range s.QRCode {
+		alt := q.Alt
+		if alt == "" {
+			alt = q.Data
+		}
+		graphic("qrcode", i, q.Xp, q.Yp, alt, q.Title)
+	}
+	for i, a := range s.Arc {
+		graphic("arc", i, a.Xp, a.Yp, a.Alt, a.Title)
+	}
+	for i, c := range s.Curve {
+		graphic("curve", i, c.Xp1, c.Yp1, c.Alt, c.Title)
+	}
+	for i, l := range s.Line {
+		graphic("line", i, l.Xp1, l.Yp1, l.Alt, l.Title)
+	}
+	for i, p := range s.Polygon {
+		var xp, yp float64
+		if x := strings.Fields(p.XC); len(x) > 0 {
+			xp, _ = strconv.ParseFloat(x[0], 64)
+		}
+		if y := strings.Fields(p.YC); len(y) > 0 {
+			yp, _ = strconv.ParseFloat(y[0], 64)
+		}
+		graphic("polygon", i, xp, yp, p.Alt, p.Title)
+	}
+	// y increases upwards, so the top of the slide is read first
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Yp != items[j].Yp {
+			return items[i].Yp > items[j].Yp
+		}
+		return items[i].Xp < items[j].Xp
+	})
+	return items
+}
+
+// Label returns the accessible name of an item: its title or text, up to the end of the first line
+func (it Item) Label() string {
+	s := it.Title
+	if s == "" {
+		s = it.Text
+	}
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+// content returns the text of a text element, reading it from its file if named
+func (d Deck) content(t Text) string {
+	if t.File != "" {
+		if data, err := d.Assets.ReadFile(t.File); err == nil {
+			return string(data)
+		}
+	}
+	return t.Tdata
+}
@@ -0,0 +1,515 @@
+package deck
+
+// The encoding of QR codes is adapted from Project Nayuki's QR Code generator library
+// (https://www.nayuki.io/page/qr-code-generator-library): its tables of error correction codewords and blocks,
+// the placement of the codewords, the masks and their penalties, and the Reed-Solomon arithmetic.
+//
+// Copyright (c) Project Nayuki. (MIT License)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation
+// the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+// - The above copyright notice and this permission notice shall be included in all copies or substantial portions
+//   of the Software.
+// - The Software is provided "as is", without warranty of any kind, express or implied, including but not limited
+//   to the warranties of merchantability, fitness for a particular purpose and noninfringement. In no event shall the
+//   authors or copyright holders be liable for any claim, damages or other liability, whether in an action of
+//   contract, tort or otherwise, arising from, out of or in connection with the Software or the use or other
+//   dealings in the Software.
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QuietZone is the width of the light margin around a QR code, in modules
+const QuietZone = 4
+
+// qrlevels are the error correction levels of QR codes, recovering about 7%, 15%, 25% and 30% of a code,
+// with the bits of each in the format information
+var qrlevels = map[string]int{"L": 1, "M": 0, "Q": 3, "H": 2}
+
+// qrecc is the number of error correction codewords in each block, by level (L, M, Q, H) and version
+var qrecc = [4][41]int{
+	{0, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{0, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{0, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+// qrblocks is the number of error correction blocks, by level (L, M, Q, H) and version
+var qrblocks = [4][41]int{
+	{0, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{0, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{0, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// qrorder is the order of the levels in the tables
+var qrorder = map[string]int{"L": 0, "M": 1, "Q": 2, "H": 3}
+
+// qrsymbol is a QR code being made: its modules, dark or light, and the modules of its function patterns
+type qrsymbol struct {
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// QR encodes data as the modules of a QR code, dark or light, row by row, at an error correction level:
+// L, M (the default), Q or H. The data is encoded as bytes, in the smallest version of QR code holding it.
+func QR(data, level string) ([][]bool, error) {
+	q, format, err := qrencode(data, level)
+	if err != nil {
+		return nil, err
+	}
+	// the mask with the least penalty is kept
+	best, least := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.mask(mask)
+		q.format(format, mask)
+		if p := q.penalty(); least < 0 || p < least {
+			best, least = mask, p
+		}
+		q.mask(mask)
+	}
+	q.mask(best)
+	q.format(format, best)
+	return q.modules, nil
+}
+
+// qrencode places data in a QR code at an error correction level, unmasked, returning the code
+// and the bits of the level in its format information
+func qrencode(data, level string) (*qrsymbol, int, error) {
+	level = strings.ToUpper(level)
+	if level == "" {
+		level = "M"
+	}
+	ecl, ok := qrorder[level]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown QR error correction level %q", level)
+	}
+	version := 0
+	for v := 1; v <= 40; v++ {
+		if 4+qrcount(v)+8*len(data) <= 8*qrdatawords(v, ecl) {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, 0, fmt.Errorf("%d bytes of data do not fit a QR code at level %s", len(data), level)
+	}
+	// byte mode, the count of bytes, the bytes, the terminator, and padding to the capacity
+	var bits []bool
+	appendbits := func(v, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, v>>uint(i)&1 == 1)
+		}
+	}
+	appendbits(4, 4)
+	appendbits(len(data), qrcount(version))
+	for i := 0; i < len(data); i++ {
+		appendbits(int(data[i]), 8)
+	}
+	capacity := 8 * qrdatawords(version, ecl)
+	for i := 0; i < 4 && len(bits) < capacity; i++ {
+		bits = append(bits, false)
+	}
+	for len(bits)%8 != 0 {
+		bits = append(bits, false)
+	}
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		appendbits(pad, 8)
+	}
+	words := make([]byte, len(bits)/8)
+	for i, b := range bits {
+		if b {
+			words[i/8] |= 1 << uint(7-i%8)
+		}
+	}
+
+	q := newqrsymbol(version)
+	q.patterns(version, qrlevels[level])
+	q.place(qrinterleave(words, version, ecl))
+	return q, qrlevels[level], nil
+}
+
+// qrcount is the number of bits of the count of bytes in a version of QR code
+func qrcount(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// qrrawmodules is the number of modules of a version of QR code holding data and error correction
+func qrrawmodules(version int) int {
+	n := (16*version+128)*version + 64
+	if version >= 2 {
+		align := version/7 + 2
+		n -= (25*align-10)*align - 55
+		if version >= 7 {
+			n -= 36
+		}
+	}
+	return n
+}
+
+// qrdatawords is the number of data codewords of a version of QR code at a level
+func qrdatawords(version, ecl int) int {
+	return qrrawmodules(version)/8 - qrecc[ecl][version]*qrblocks[ecl][version]
+}
+
+// qralignment returns the positions of the alignment patterns of a version of QR code, across and down
+func qralignment(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	n := version/7 + 2
+	step := (version*8 + n*3 + 5) / (n*4 - 4) * 2
+	pos := make([]int, n)
+	pos[0] = 6
+	for i, p := n-1, version*4+10; i >= 1; i, p = i-1, p-step {
+		pos[i] = p
+	}
+	return pos
+}
+
+// newqrsymbol makes an empty QR code of a version
+func newqrsymbol(version int) *qrsymbol {
+	size := version*4 + 17
+	q := &qrsymbol{size: size, modules: make([][]bool, size), function: make([][]bool, size)}
+	for i := range q.modules {
+		q.modules[i] = make([]bool, size)
+		q.function[i] = make([]bool, size)
+	}
+	return q
+}
+
+// set sets a module of a function pattern, at column x and row y
+func (q *qrsymbol) set(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.function[y][x] = true
+}
+
+// patterns draws the function patterns: timing, finder and alignment patterns, format and version information
+func (q *qrsymbol) patterns(version, level int) {
+	for i := 0; i < q.size; i++ {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+	for _, c := range [][2]int{{3, 3}, {q.size - 4, 3}, {3, q.size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if x >= 0 && x < q.size && y >= 0 && y < q.size {
+					d := qrdistance(dx, dy)
+					q.set(x, y, d != 2 && d != 4)
+				}
+			}
+		}
+	}
+	pos := qralignment(version)
+	last := len(pos) - 1
+	for i, x := range pos {
+		for j, y := range pos {
+			// not over the finder patterns
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.set(x+dx, y+dy, qrdistance(dx, dy) != 1)
+				}
+			}
+		}
+	}
+	q.format(level, 0)
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ (rem>>11)*0x1F25
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			a, b := q.size-11+i%3, i/3
+			q.set(a, b, bits>>uint(i)&1 == 1)
+			q.set(b, a, bits>>uint(i)&1 == 1)
+		}
+	}
+}
+
+// qrdistance is the distance of a module from the center of a pattern, ring by ring
+func qrdistance(dx, dy int) int {
+	if dx < 0 {
+		dx = -dx
+	}
+	if dy < 0 {
+		dy = -dy
+	}
+	if dx > dy {
+		return dx
+	}
+	return dy
+}
+
+// format draws both copies of the format information: the level and the mask
+func (q *qrsymbol) format(level, mask int) {
+	data := level<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>uint(i)&1 == 1 }
+	for i := 0; i <= 5; i++ {
+		q.set(8, i, bit(i))
+	}
+	q.set(8, 7, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		q.set(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(8, q.size-15+i, bit(i))
+	}
+	q.set(8, q.size-8, true)
+}
+
+// place places the codewords in the modules outside the function patterns,
+// up and down columns two modules wide, from the right
+func (q *qrsymbol) place(words []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < q.size; vert++ {
+			y := vert
+			if upward {
+				y = q.size - 1 - vert
+			}
+			for x := right; x > right-2; x-- {
+				if !q.function[y][x] && i < len(words)*8 {
+					q.modules[y][x] = words[i/8]>>uint(7-i%8)&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// mask inverts the modules outside the function patterns by one of the eight mask patterns;
+// masking twice undoes the mask
+func (q *qrsymbol) mask(mask int) {
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !q.function[y][x] {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the modules by the rules for choosing a mask: runs of a color, blocks of a color,
+// patterns like the finder patterns, and an imbalance of dark and light
+func (q *qrsymbol) penalty() int {
+	n := q.size
+	finder := []bool{true, false, true, true, true, false, true}
+	score := 0
+	for _, across := range []bool{true, false} {
+		for y := 0; y < n; y++ {
+			run := 1
+			for x := 1; x <= n; x++ {
+				if x < n && q.at(x, y, across) == q.at(x-1, y, across) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					score += run - 2
+				}
+				run = 1
+			}
+			// a finder-like pattern, with four light modules on one side
+			for x := 0; x+7 <= n; x++ {
+				match := true
+				for i, dark := range finder {
+					if q.at(x+i, y, across) != dark {
+						match = false
+						break
+					}
+				}
+				if match && (q.light(x-4, x, y, across) || q.light(x+7, x+11, y, across)) {
+					score += 40
+				}
+			}
+		}
+	}
+	dark := 0
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if q.modules[y][x] {
+				dark++
+			}
+			if x+1 < n && y+1 < n {
+				c := q.modules[y][x]
+				if q.modules[y][x+1] == c && q.modules[y+1][x] == c && q.modules[y+1][x+1] == c {
+					score += 3
+				}
+			}
+		}
+	}
+	total := n * n
+	d := dark*20 - total*10
+	if d < 0 {
+		d = -d
+	}
+	return score + (d+total-1)/total*10 - 10
+}
+
+// at returns a module along a row, across, or down a column
+func (q *qrsymbol) at(x, y int, across bool) bool {
+	if across {
+		return q.modules[y][x]
+	}
+	return q.modules[x][y]
+}
+
+// light determines if the modules from a to b, along a row or column, are light; modules outside the code are light
+func (q *qrsymbol) light(a, b, y int, across bool) bool {
+	for x := a; x < b; x++ {
+		if x >= 0 && x < q.size && q.at(x, y, across) {
+			return false
+		}
+	}
+	return true
+}
+
+// qrinterleave divides the data codewords of a version of QR code into blocks, adds the error correction
+// codewords of each, and interleaves the blocks
+func qrinterleave(data []byte, version, ecl int) []byte {
+	nblocks, ecc := qrblocks[ecl][version], qrecc[ecl][version]
+	raw := qrrawmodules(version) / 8
+	short := nblocks - raw%nblocks
+	shortlen := raw / nblocks
+	divisor := rsdivisor(ecc)
+	blocks := make([][]byte, nblocks)
+	k := 0
+	for i := range blocks {
+		n := shortlen - ecc
+		if i >= short {
+			n++
+		}
+		block := append([]byte(nil), data[k:k+n]...)
+		k += n
+		// short blocks are padded to line up with the long blocks
+		if i < short {
+			block = append(block, 0)
+		}
+		blocks[i] = append(block, rsremainder(data[k-n:k], divisor)...)
+	}
+	var words []byte
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			if i != shortlen-ecc || j >= short {
+				words = append(words, block[i])
+			}
+		}
+	}
+	return words
+}
+
+// rsdivisor returns the generator polynomial of Reed-Solomon codes of a degree, highest term first,
+// leaving out the leading 1
+func rsdivisor(degree int) []byte {
+	d := make([]byte, degree)
+	d[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range d {
+			d[j] = rsmultiply(d[j], root)
+			if j+1 < degree {
+				d[j] ^= d[j+1]
+			}
+		}
+		root = rsmultiply(root, 2)
+	}
+	return d
+}
+
+// rsremainder returns the Reed-Solomon error correction codewords of data
+func rsremainder(data, divisor []byte) []byte {
+	r := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ r[0]
+		copy(r, r[1:])
+		r[len(r)-1] = 0
+		for i, d := range divisor {
+			r[i] ^= rsmultiply(d, factor)
+		}
+	}
+	return r
+}
+
+// rsmultiply multiplies in the field of 256 elements of QR codes
+func rsmultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11D
+		z ^= int(y>>uint(i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+// Modules returns the modules of a QR code, dark or light, row by row
+func (q QRCode) Modules() ([][]bool, error) {
+	return QR(q.Data, q.Level)
+}
+
+// QRRun is a run of dark modules along a row of a QR code: the row, the column it begins at, and its length
+type QRRun struct {
+	Row, Col, Len int
+}
+
+// QRRuns returns the runs of dark modules of a QR code, row by row, for drawing each as a rectangle
+func QRRuns(modules [][]bool) []QRRun {
+	var runs []QRRun
+	for r, row := range modules {
+		for c := 0; c < len(row); c++ {
+			if !row[c] {
+				continue
+			}
+			run := QRRun{Row: r, Col: c}
+			for c < len(row) && row[c] {
+				c++
+			}
+			run.Len = c - run.Col
+			runs = append(runs, run)
+		}
+	}
+	return runs
+}
@@ -0,0 +1,269 @@
+package deck
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Reflow modes
+const (
+	Letterbox = "letterbox" // fit the content, unchanged, in a centered area of the new canvas
+	Stretch   = "stretch"   // keep the percentages, stretching the content with the canvas
+	Smart     = "smart"     // move the content with the canvas, keeping the size of text and the shape of graphics
+)
+
+// reflow maps the percentages of a slide from one canvas to another
+type reflow struct {
+	mode   string
+	ow, oh float64 // old canvas
+	nw, nh float64 // new canvas
+	s      float64 // scale of sizes, from old to new canvas
+	ox, oy float64 // origin of the letterbox
+}
+
+// newreflow makes the mapping of a slide from the old to the new canvas
+func newreflow(mode string, old, new canvas) reflow {
+	r := reflow{mode: mode,
+		ow: float64(old.Width), oh: float64(old.Height),
+		nw: float64(new.Width), nh: float64(new.Height),
+	}
+	r.s = math.Min(r.nw/r.ow, r.nh/r.oh)
+	r.ox = (r.nw - r.s*r.ow) / 2
+	r.oy = (r.nh - r.s*r.oh) / 2
+	return r
+}
+
+// point maps a position
+func (r reflow) point(xp, yp float64) (float64, float64) {
+	if r.mode != Letterbox {
+		return xp, yp
+	}
+	return round((r.ox + r.s*xp*r.ow/100) * 100 / r.nw), round((r.oy + r.s*yp*r.oh/100) * 100 / r.nh)
+}
+
+// size maps a size given as a percentage of the canvas width
+func (r reflow) size(p float64) float64 {
+	if r.mode == Stretch {
+		return p
+	}
+	return round(p * r.s * r.ow / r.nw)
+}
+
+// height maps a size given as a percentage of the canvas height
+func (r reflow) height(p float64) float64 {
+	if r.mode == Stretch {
+		return p
+	}
+	return round(p * r.s * r.oh / r.nh)
+}
+
+// scale maps the scale of an image, whose size is not relative to the canvas
+func (r reflow) scale(p float64) float64 {
+	if p == 0 {
+		p = 100
+	}
+	if r.mode == Stretch {
+		return round(p * r.nw / r.ow)
+	}
+	return round(p * r.s)
+}
+
+// round rounds percentages to thousandths
+func round(p float64) float64 {
+	return math.Round(p*1000) / 1000
+}
+
+// shape maps the points of a shape. In smart mode the center of the shape moves with the canvas,
+// and the points keep their distance from it, scaled as sizes are.
+func (r reflow) shape(xp, yp []float64) {
+	if r.mode == Smart {
+		var cx, cy float64
+		for i := range xp {
+			cx += xp[i] / float64(len(xp))
+			cy += yp[i] / float64(len(yp))
+		}
+		for i := range xp {
+			xp[i] = round(cx + r.size(xp[i]-cx))
+			yp[i] = round(cy + r.height(yp[i]-cy))
+		}
+		return
+	}
+	for i := range xp {
+		xp[i], yp[i] = r.point(xp[i], yp[i])
+	}
+}
+
+// common maps the position and size of text, lists and images
+func (r reflow) common(c *CommonAttr) {
+	c.Xp, c.Yp = r.point(c.Xp, c.Yp)
+	c.Sp = r.size(c.Sp)
+}
+
+// dimension maps the position and size of rectangles, ellipses and arcs
+func (r reflow) dimension(d *Dimension, hp func(float64) float64) {
+	r.common(&d.CommonAttr)
+	d.Wp = r.size(d.Wp)
+	d.Hp = hp(d.Hp)
+}
+
+// overlay maps the content of a slide, header or footer
+func (r reflow) overlay(o *Overlay) {
+	if r.ow == r.nw && r.oh == r.nh {
+		return
+	}
+	for i := range o.List {
+		l := &o.List[i]
+		r.common(&l.CommonAttr)
+		if l.Wp == 0 {
+			l.Wp = 50 // the default width, half the canvas
+		}
+		l.Wp = r.size(l.Wp)
+		l.Hp = r.height(l.Hp)
+		l.Indent = r.size(l.Indent)
+		l.MinSp = r.size(l.MinSp)
+	}
+	for i := range o.Text {
+		t := &o.Text[i]
+		r.common(&t.CommonAttr)
+		if t.Wp == 0 && t.Type == "block" {
+			t.Wp = 50
+		}
+		t.Wp = r.size(t.Wp)
+		t.Hp = r.height(t.Hp)
+		t.Padding = r.size(t.Padding)
+		t.BorderWidth = r.size(t.BorderWidth)
+		t.Radius = r.size(t.Radius)
+		t.MinSp = r.size(t.MinSp)
+	}
+	for i := range o.Image {
+		im := &o.Image[i]
+		r.common(&im.CommonAttr)
+		im.Scale = r.scale(im.Scale)
+	}
+	for i := range o.Rect {
+		r.dimension(&o.Rect[i].Dimension, r.height)
+	}
+	for i := range o.Ellipse {
+		r.dimension(&o.Ellipse[i].Dimension, r.height)
+	}
+	for i := range o.Arc {
+		// the height of an arc is a percentage of the canvas width
+		r.dimension(&o.Arc[i].Dimension, r.size)
+		o.Arc[i].Sp = r.size(o.Arc[i].Sp)
+	}
+	for i := range o.QRCode {
+		// QR codes are square, their height following their width
+		r.dimension(&o.QRCode[i].Dimension, r.size)
+	}
+	for i := range o.Line {
+		l := &o.Line[i]
+		xp, yp := []float64{l.Xp1, l.Xp2}, []float64{l.Yp1, l.Yp2}
+		r.shape(xp, yp)
+		l.Xp1, l.Xp2, l.Yp1, l.Yp2 = xp[0], xp[1], yp[0], yp[1]
+		l.Sp = r.size(l.Sp)
+	}
+	for i := range o.Curve {
+		c := &o.Curve[i]
+		xp, yp := []float64{c.Xp1, c.Xp2, c.Xp3}, []float64{c.Yp1, c.Yp2, c.Yp3}
+		r.shape(xp, yp)
+		c.Xp1, c.Xp2, c.Xp3, c.Yp1, c.Yp2, c.Yp3 = xp[0], xp[1], xp[2], yp[0], yp[1], yp[2]
+		c.Sp = r.size(c.Sp)
+	}
+	for i := range o.Polygon {
+		p := &o.Polygon[i]
+		xp, yp := coords(p.XC), coords(p.YC)
+		if len(xp) != len(yp) || len(xp) == 0 {
+			continue
+		}
+		r.shape(xp, yp)
+		p.XC, p.YC = joincoords(xp), joincoords(yp)
+	}
+	for i := range o.TextPath {
+		t := &o.TextPath[i]
+		r.common(&t.CommonAttr)
+		// the height of an arc is a percentage of the canvas width
+		t.Wp, t.Hp = r.size(t.Wp), r.size(t.Hp)
+		if t.IsCurve() {
+			xp, yp := []float64{t.Xp1, t.Xp2, t.Xp3}, []float64{t.Yp1, t.Yp2, t.Yp3}
+			r.shape(xp, yp)
+			t.Xp1, t.Xp2, t.Xp3, t.Yp1, t.Yp2, t.Yp3 = xp[0], xp[1], xp[2], yp[0], yp[1], yp[2]
+		}
+	}
+}
+
+// slide maps the content of a slide
+func (r reflow) slide(s *Slide) {
+	o := Overlay{s.List, s.Text, s.Image, s.Ellipse, s.Line, s.Rect, s.Curve, s.Arc, s.Polygon, s.TextPath, s.QRCode}
+	r.overlay(&o)
+	for i := range s.TOC {
+		r.common(&s.TOC[i].CommonAttr)
+		if s.TOC[i].Wp == 0 {
+			s.TOC[i].Wp = 50
+		}
+		s.TOC[i].Wp = r.size(s.TOC[i].Wp)
+	}
+}
+
+// coords parses the space separated coordinates of a polygon
+func coords(s string) []float64 {
+	var c []float64
+	for _, f := range strings.Fields(s) {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil
+		}
+		c = append(c, v)
+	}
+	return c
+}
+
+// joincoords formats the coordinates of a polygon
+func joincoords(c []float64) string {
+	s := make([]string, len(c))
+	for i, v := range c {
+		s[i] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strings.Join(s, " ")
+}
+
+// Reflow returns a copy of the deck for a canvas of a different size (and aspect ratio).
+// Letterbox fits the content of each slide, unchanged, in the largest centered area of the
+// new canvas with the old aspect ratio; Stretch keeps the percentages, so content stretches
+// with the canvas; Smart moves the content with the canvas, keeping the size of text and images
+// (relative to the smaller of the changes in width and height) and the shape of graphics.
+// Slides that set their own width and height are left as they are.
+func Reflow(d Deck, w, h int, mode string) (Deck, error) {
+	switch mode {
+	case Letterbox, Stretch, Smart:
+	default:
+		return d, fmt.Errorf("unknown reflow mode %q (letterbox, stretch, or smart)", mode)
+	}
+	if w <= 0 || h <= 0 || d.Canvas.Width <= 0 || d.Canvas.Height <= 0 {
+		return d, fmt.Errorf("reflow from %dx%d to %dx%d: the canvas sizes must be set", d.Canvas.Width, d.Canvas.Height, w, h)
+	}
+	old := d
+	d.Canvas = canvas{Width: w, Height: h}
+	slides := func(ss []Slide) []Slide {
+		c := make([]Slide, len(ss))
+		for i, s := range ss {
+			c[i] = s.clone()
+			newreflow(mode, old.canvas(s), d.canvas(s)).slide(&c[i])
+		}
+		return c
+	}
+	d.Slide = slides(old.Slide)
+	d.Section = append([]Section(nil), old.Section...)
+	for i := range d.Section {
+		d.Section[i].Slide = slides(d.Section[i].Slide)
+	}
+	if old.Divider != nil {
+		d.Divider = &slides([]Slide{*old.Divider})[0]
+	}
+	r := newreflow(mode, old.Canvas, d.Canvas)
+	d.Header, d.Footer = old.Header.clone(), old.Footer.clone()
+	r.overlay(&d.Header)
+	r.overlay(&d.Footer)
+	return d, nil
+}
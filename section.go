@@ -0,0 +1,158 @@
+package deck
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Section groups slides under a title. A section may begin with a divider slide,
+// made from the deck's divider element, where {section} is the section's title.
+// <section title="Background" divider="on"><slide>...</slide></section>
+type Section struct {
+	Variant
+	Title   string  `xml:"title,attr,omitempty"`
+	Divider string  `xml:"divider,attr,omitempty"` // "on" begins the section with a divider slide
+	Slide   []Slide `xml:"slide,omitempty"`
+}
+
+// TOC is a table of contents: a list of the titles of the deck's sections, linked to their first slides
+// <toc xp="10" yp="80" sp="2.5" type="number"/>
+type TOC struct {
+	CommonAttr
+	Wp float64 `xml:"wp,attr,omitempty"`
+}
+
+// SectionRange is the slides of a section
+type SectionRange struct {
+	Title       string
+	First, Last int // indexes of the first and last slides
+}
+
+// Sections returns the sections of the deck: runs of slides with the same section title
+func (d Deck) Sections() []SectionRange {
+	var sr []SectionRange
+	for i, s := range d.Slide {
+		switch {
+		case s.Section == "":
+		case len(sr) > 0 && sr[len(sr)-1].Title == s.Section && sr[len(sr)-1].Last == i-1:
+			sr[len(sr)-1].Last = i
+		default:
+			sr = append(sr, SectionRange{Title: s.Section, First: i, Last: i})
+		}
+	}
+	return sr
+}
+
+// SlideLink returns the slide number (counting from 1) of a link to a slide of the deck, "#n"
+func SlideLink(link string) (int, bool) {
+	if !strings.HasPrefix(link, "#") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(link[1:])
+	return n, err == nil && n > 0
+}
+
+// on reports whether an attribute turns a feature on
+func on(s string) bool {
+	return s == "on" || s == "yes" || s == "true"
+}
+
+// sections places the slides of sections in sequence with the deck's other slides,
+// in the order of the deck description, adding the sections' divider slides.
+// Sections not selected by the tags, or whose slides the tags all leave out, are left out with their dividers.
+func (d *Deck) sections(data []byte, tags []string) error {
+	if len(d.Section) == 0 {
+		return nil
+	}
+	order, err := elements(data)
+	if err != nil {
+		return err
+	}
+	var slides []Slide
+	ns, nsec := 0, 0
+	for _, name := range order {
+		switch {
+		case name == "slide" && ns < len(d.Slide):
+			slides = append(slides, d.Slide[ns])
+			ns++
+		case name == "section" && nsec < len(d.Section):
+			sec := d.Section[nsec]
+			nsec++
+			if !sec.Selected(tags) || emptied(sec.Slide, tags) {
+				continue
+			}
+			if on(sec.Divider) {
+				slides = append(slides, d.divider(sec.Title))
+			}
+			for _, s := range sec.Slide {
+				s.Section = sec.Title
+				slides = append(slides, s)
+			}
+		}
+	}
+	d.Slide = slides
+	return nil
+}
+
+// emptied reports whether the tags leave out all of the slides
+func emptied(slides []Slide, tags []string) bool {
+	for _, s := range slides {
+		if s.Selected(tags) {
+			return false
+		}
+	}
+	return len(slides) > 0
+}
+
+// divider makes a divider slide for a section
+func (d *Deck) divider(title string) Slide {
+	s := Slide{Text: []Text{{CommonAttr: CommonAttr{Xp: 50, Yp: 50, Sp: 5, Align: "center"}, Tdata: "{section}"}}}
+	if d.Divider != nil {
+		s = d.Divider.clone()
+	}
+	s.Section = title
+	return s
+}
+
+// contents expands each table of contents into a list of section titles
+func (d *Deck) contents() {
+	sr := d.Sections()
+	for i := range d.Slide {
+		s := &d.Slide[i]
+		for _, t := range s.TOC {
+			l := List{CommonAttr: t.CommonAttr, Wp: t.Wp}
+			for _, sec := range sr {
+				l.Li = append(l.Li, ListItem{ListText: sec.Title, Link: "#" + strconv.Itoa(sec.First+1)})
+			}
+			s.List = append(s.List, l)
+		}
+	}
+}
+
+// clone returns a copy of an overlay that shares no content with it
+func (o Overlay) clone() Overlay {
+	s := Slide{List: o.List, Text: o.Text, Image: o.Image, Ellipse: o.Ellipse, Line: o.Line,
+		Rect: o.Rect, Curve: o.Curve, Arc: o.Arc, Polygon: o.Polygon, TextPath: o.TextPath, QRCode: o.QRCode}.clone()
+	return Overlay{s.List, s.Text, s.Image, s.Ellipse, s.Line, s.Rect, s.Curve, s.Arc, s.Polygon, s.TextPath, s.QRCode}
+}
+
+// clone returns a copy of a slide that shares no content with it
+func (s Slide) clone() Slide {
+	c := s
+	c.List = append([]List(nil), s.List...)
+	for i := range c.List {
+		c.List[i].Li = append([]ListItem(nil), c.List[i].Li...)
+	}
+	c.Text = append([]Text(nil), s.Text...)
+	c.Image = append([]Image(nil), s.Image...)
+	c.Ellipse = append([]Ellipse(nil), s.Ellipse...)
+	c.Line = append([]Line(nil), s.Line...)
+	c.Rect = append([]Rect(nil), s.Rect...)
+	c.Curve = append([]Curve(nil), s.Curve...)
+	c.Arc = append([]Arc(nil), s.Arc...)
+	c.Polygon = append([]Polygon(nil), s.Polygon...)
+	c.TextPath = append([]TextPath(nil), s.TextPath...)
+	c.QRCode = append([]QRCode(nil), s.QRCode...)
+	c.TOC = append([]TOC(nil), s.TOC...)
+	return c
+}
@@ -0,0 +1,377 @@
+package deck
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Decode reads a deck file as written, without the processing of Read: sections are kept,
+// headers and footers are not added to the slides, placeholders, tables of contents, nested lists
+// and markdown are left as they are, and nothing is cut for a variant. Programs that rewrite decks,
+// such as deckreflow, read them with Decode and write them with Encode.
+func Decode(filename string, w, h int) (Deck, error) {
+	var d Deck
+	if IsBundle(filename) {
+		return d, fmt.Errorf("%s: a bundle cannot be decoded, only read", filename)
+	}
+	var r io.Reader = os.Stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return d, err
+		}
+		defer f.Close()
+		r = f
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return d, err
+	}
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(&d); err != nil {
+		return d, err
+	}
+	if d.Canvas.Width == 0 {
+		d.Canvas.Width = w
+	}
+	if d.Canvas.Height == 0 {
+		d.Canvas.Height = h
+	}
+	d.Assets = NewResolver(filename)
+	if d.decoded, err = d.markup(""); err != nil {
+		return d, err
+	}
+	d.source = data
+	return d, nil
+}
+
+// elements returns the names of the elements of a deck, in order
+func elements(data []byte) ([]string, error) {
+	var order struct {
+		Element []struct {
+			XMLName xml.Name
+		} `xml:",any"`
+	}
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(&order); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(order.Element))
+	for i, e := range order.Element {
+		names[i] = e.XMLName.Local
+	}
+	return names, nil
+}
+
+// empty reports whether an overlay has no content
+func (o Overlay) empty() bool {
+	return len(o.List)+len(o.Text)+len(o.Image)+len(o.Ellipse)+len(o.Line)+len(o.Rect)+len(o.Curve)+
+		len(o.Arc)+len(o.Polygon)+len(o.TextPath)+len(o.QRCode) == 0
+}
+
+// markup returns the deck as markup, indented by indent, leaving out an empty header and footer
+func (d Deck) markup(indent string) ([]byte, error) {
+	deck := struct {
+		Deck
+		Header *Overlay `xml:"header,omitempty"`
+		Footer *Overlay `xml:"footer,omitempty"`
+	}{Deck: d}
+	if !d.Header.empty() {
+		deck.Header = &d.Header
+	}
+	if !d.Footer.empty() {
+		deck.Footer = &d.Footer
+	}
+	var b bytes.Buffer
+	enc := xml.NewEncoder(&b)
+	enc.Indent("", indent)
+	if err := enc.EncodeElement(deck, xml.StartElement{Name: xml.Name{Local: "deck"}}); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Encode writes the deck as markup. A decoded deck is written as it was written, its comments, order
+// and layout kept, with the attributes changed since it was decoded rewritten, and the elements added
+// to it (such as a canvas) placed at the end of their parents; other decks are written afresh.
+func (d Deck) Encode(w io.Writer) error {
+	if d.source == nil {
+		data, err := d.markup("  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, data)
+		return err
+	}
+	now, err := d.markup("")
+	if err != nil {
+		return err
+	}
+	data, err := edit(d.source, d.decoded, now)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// node is an element of markup: its name, attributes and children, and the offsets of its start tag,
+// from start to tagend, and of its end tag, from endstart to end
+type node struct {
+	name                         string
+	attrs                        []xml.Attr
+	children                     []*node
+	start, tagend, endstart, end int
+}
+
+// tree returns the elements of markup, as the children of a node with no name
+func tree(data []byte) (*node, error) {
+	root := &node{}
+	stack := []*node{root}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		start := int(dec.InputOffset())
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			return root, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &node{name: qname(t.Name), attrs: t.Attr, start: start, tagend: int(dec.InputOffset())}
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("unexpected end element </%s>", qname(t.Name))
+			}
+			n := stack[len(stack)-1]
+			n.endstart, n.end = start, int(dec.InputOffset())
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+// qname returns a name as written, with its prefix
+func qname(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
+
+// child returns the nth child of a node (counting from 0) with the name, or nil
+func (n *node) child(name string, nth int) *node {
+	for _, c := range n.children {
+		if c.name == name {
+			if nth == 0 {
+				return c
+			}
+			nth--
+		}
+	}
+	return nil
+}
+
+// attr returns the value of an attribute of a node, and whether it has it
+func (n *node) attr(name string) (string, bool) {
+	for _, a := range n.attrs {
+		if qname(a.Name) == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// edit returns the source markup of a deck with the changes from the markup of the deck as it was decoded
+// to its markup now. Elements are matched by their name and their place among the elements of that name:
+// the start tags whose attributes changed are rewritten, and the elements that the source lacks, changed
+// since the deck was decoded, are added at the end of their parents. The rest of the source is kept.
+func edit(source, was, now []byte) ([]byte, error) {
+	src, err := tree(source)
+	if err != nil {
+		return nil, err
+	}
+	before, err := tree(was)
+	if err != nil {
+		return nil, err
+	}
+	after, err := tree(now)
+	if err != nil {
+		return nil, err
+	}
+	// the edits replace the source from start to end with text, in order
+	type change struct {
+		start, end int
+		text       string
+	}
+	var changes []change
+	var walk func(s, b, a *node)
+	walk = func(s, b, a *node) {
+		if s.name != "" {
+			if tag, ok := retag(source, s, b, a); ok {
+				changes = append(changes, change{s.start, s.tagend, tag})
+			}
+		}
+		count := map[string]int{}
+		for _, c := range s.children {
+			n := count[c.name]
+			count[c.name]++
+			if cb, ca := b.child(c.name, n), a.child(c.name, n); cb != nil && ca != nil {
+				walk(c, cb, ca)
+			}
+		}
+		// self-closed elements, and the root, are left as they are
+		if s.name == "" || s.endstart == s.tagend {
+			return
+		}
+		// added elements are placed before the end tag, indented as the lines before it
+		indent := source[bytes.LastIndexByte(source[:s.endstart], '\n')+1 : s.endstart]
+		if len(bytes.TrimSpace(indent)) > 0 {
+			indent = nil
+		}
+		var added []byte
+		seen := map[string]int{}
+		for _, ca := range a.children {
+			n := seen[ca.name]
+			seen[ca.name]++
+			if n < count[ca.name] {
+				continue
+			}
+			element := now[ca.start:ca.end]
+			if cb := b.child(ca.name, n); cb != nil && bytes.Equal(was[cb.start:cb.end], element) {
+				continue
+			}
+			if indent != nil {
+				added = append(added, "  "...)
+			}
+			added = append(added, element...)
+			if indent != nil {
+				added = append(append(added, '\n'), indent...)
+			}
+		}
+		if len(added) > 0 {
+			changes = append(changes, change{s.endstart, s.endstart, string(added)})
+		}
+	}
+	walk(src, before, after)
+
+	var out []byte
+	at := 0
+	for _, c := range changes {
+		out = append(append(out, source[at:c.start]...), c.text...)
+		at = c.end
+	}
+	return append(out, source[at:]...), nil
+}
+
+// retag returns the start tag of a source element with the attributes changed from before to after,
+// and whether any changed: changed attributes get their new values, removed ones are left out, and
+// added ones follow the others. Attributes that did not change keep their place.
+func retag(source []byte, s, before, after *node) (string, bool) {
+	var attrs []xml.Attr
+	changed := false
+	for _, a := range s.attrs {
+		name := qname(a.Name)
+		was, wok := before.attr(name)
+		now, nok := after.attr(name)
+		switch {
+		case was == now && wok == nok:
+		case !nok:
+			changed = true
+			continue
+		default:
+			a.Value, changed = now, true
+		}
+		attrs = append(attrs, a)
+	}
+	for _, a := range after.attrs {
+		name := qname(a.Name)
+		if _, ok := s.attr(name); ok {
+			continue
+		}
+		if was, ok := before.attr(name); !ok || was != a.Value {
+			attrs, changed = append(attrs, a), true
+		}
+	}
+	if !changed {
+		return "", false
+	}
+	var b bytes.Buffer
+	b.WriteString("<" + s.name)
+	for _, a := range attrs {
+		b.WriteString(" " + qname(a.Name) + `="`)
+		xml.EscapeText(&b, []byte(a.Value))
+		b.WriteString(`"`)
+	}
+	if bytes.HasSuffix(source[s.start:s.tagend], []byte("/>")) {
+		b.WriteString("/>")
+	} else {
+		b.WriteString(">")
+	}
+	return b.String(), true
+}
+
+// Rebase renames the files of a deck (images, text and code files, fonts, bullet images and
+// hyphenation dictionaries) that are found relative to the directory from, so that they are found
+// from the directory to, where the deck is to be written. Other names are left as they are.
+func (d *Deck) Rebase(from, to string) {
+	rel := func(name string) string {
+		if name == "" || filepath.IsAbs(name) || remote(name) {
+			return name
+		}
+		path := filepath.Join(from, name)
+		if _, err := os.Stat(path); err != nil {
+			return name
+		}
+		abspath, err := filepath.Abs(path)
+		if err != nil {
+			return name
+		}
+		absto, err := filepath.Abs(to)
+		if err != nil {
+			return name
+		}
+		if r, err := filepath.Rel(absto, abspath); err == nil {
+			return filepath.ToSlash(r)
+		}
+		return name
+	}
+	overlay := func(o Overlay) {
+		for i := range o.Image {
+			o.Image[i].Name = rel(o.Image[i].Name)
+		}
+		for i := range o.Text {
+			o.Text[i].File = rel(o.Text[i].File)
+			o.Text[i].Hyphenate = rel(o.Text[i].Hyphenate)
+		}
+		for i := range o.List {
+			o.List[i].BulletImage = rel(o.List[i].BulletImage)
+		}
+	}
+	slide := func(s Slide) {
+		overlay(Overlay{s.List, s.Text, s.Image, s.Ellipse, s.Line, s.Rect, s.Curve, s.Arc, s.Polygon, s.TextPath, s.QRCode})
+	}
+	for i := range d.Font {
+		d.Font[i].File = rel(d.Font[i].File)
+	}
+	overlay(d.Header)
+	overlay(d.Footer)
+	if d.Divider != nil {
+		slide(*d.Divider)
+	}
+	for _, s := range d.Slide {
+		slide(s)
+	}
+	for _, sec := range d.Section {
+		for _, s := range sec.Slide {
+			slide(s)
+		}
+	}
+}
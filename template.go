@@ -0,0 +1,116 @@
+package deck
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// Overlay is content drawn on every slide, after the slide's own elements of the same kind.
+// <footer><text xp="95" yp="3" sp="1.2" align="end">{slide} / {slides}</text></footer>
+type Overlay struct {
+	List     []List     `xml:"list,omitempty"`
+	Text     []Text     `xml:"text,omitempty"`
+	Image    []Image    `xml:"image,omitempty"`
+	Ellipse  []Ellipse  `xml:"ellipse,omitempty"`
+	Line     []Line     `xml:"line,omitempty"`
+	Rect     []Rect     `xml:"rect,omitempty"`
+	Curve    []Curve    `xml:"curve,omitempty"`
+	Arc      []Arc      `xml:"arc,omitempty"`
+	Polygon  []Polygon  `xml:"polygon,omitempty"`
+	TextPath []TextPath `xml:"textpath,omitempty"`
+	QRCode   []QRCode   `xml:"qrcode,omitempty"`
+}
+
+// placeholder matches the names of values in braces, i.e. {slide}
+var placeholder = regexp.MustCompile(`\{([A-Za-z][A-Za-z0-9_.-]*)\}`)
+
+// off reports whether a slide attribute turns a feature off
+func off(s string) bool {
+	return s == "off" || s == "no" || s == "false" || s == "none"
+}
+
+// expand adds the deck's header and footer to each slide that has not opted out,
+// and replaces placeholders in text (other than code), list items and captions:
+// {slide} is the slide number, {slides} the number of slides, {section} the slide's section, and any metadata
+// property, standard or custom, by its name ({title}, {date}, {creator}, {event}, ...).
+// Only the slides that are not hidden are numbered and counted; hidden slides are numbered
+// after the slide before them, with a letter (3a, 3b, ...). Unknown placeholders are left as they are.
+func (d *Deck) expand() {
+	shown := 0
+	for _, s := range d.Slide {
+		if !s.IsHidden() {
+			shown++
+		}
+	}
+	values := map[string]string{"title": "", "creator": "", "date": "", "slides": strconv.Itoa(shown)}
+	for _, p := range d.Properties() {
+		values[p.Name] = p.Value
+	}
+	replace := func(s string) string {
+		return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+			if v, ok := values[m[1:len(m)-1]]; ok {
+				return v
+			}
+			return m
+		})
+	}
+	number, hidden := 0, 0
+	for i := range d.Slide {
+		s := &d.Slide[i]
+		if s.IsHidden() {
+			hidden++
+			values["slide"] = strconv.Itoa(number) + letters(hidden)
+		} else {
+			number++
+			hidden = 0
+			values["slide"] = strconv.Itoa(number)
+		}
+		if !off(s.Header) {
+			s.add(d.Header)
+		}
+		if !off(s.Footer) {
+			s.add(d.Footer)
+		}
+		values["section"] = s.Section
+		for j := range s.Text {
+			if s.Text[j].Type != "code" {
+				s.Text[j].Tdata = replace(s.Text[j].Tdata)
+				s.Text[j].Spans = spanned(s.Text[j].Spans, replace)
+			}
+		}
+		for j := range s.TextPath {
+			s.TextPath[j].Tdata = replace(s.TextPath[j].Tdata)
+		}
+		for j := range s.QRCode {
+			s.QRCode[j].Data = replace(s.QRCode[j].Data)
+		}
+		// list items may be shared with other slides by the header and footer
+		for j := range s.List {
+			li := make([]ListItem, len(s.List[j].Li))
+			for k, item := range s.List[j].Li {
+				item.ListText = replace(item.ListText)
+				item.Spans = spanned(item.Spans, replace)
+				li[k] = item
+			}
+			s.List[j].Li = li
+		}
+		for j := range s.Image {
+			s.Image[j].Caption = replace(s.Image[j].Caption)
+		}
+	}
+}
+
+// add appends the content of an overlay to the slide
+func (s *Slide) add(o Overlay) {
+	s.List = append(s.List, o.List...)
+	s.Text = append(s.Text, o.Text...)
+	s.Image = append(s.Image, o.Image...)
+	s.Ellipse = append(s.Ellipse, o.Ellipse...)
+	s.Line = append(s.Line, o.Line...)
+	s.Rect = append(s.Rect, o.Rect...)
+	s.Curve = append(s.Curve, o.Curve...)
+	s.Arc = append(s.Arc, o.Arc...)
+	s.Polygon = append(s.Polygon, o.Polygon...)
+	s.TextPath = append(s.TextPath, o.TextPath...)
+	s.QRCode = append(s.QRCode, o.QRCode...)
+}
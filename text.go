@@ -0,0 +1,155 @@
+package deck
+
+import (
+	"strings"
+)
+
+// Wrap breaks text into lines of words for a wrapping width. Each word is measured,
+// followed by the space between words; a line ends after the word that crosses the width.
+// If the last word ends a line, the result has a final empty line, so that the number of
+// line breaks is always one less than the number of lines.
+func Wrap(s string, width, space float64, measure func(string) float64) []string {
+	var lines []string
+	var line []string
+	x := 0.0
+	for _, word := range strings.FieldsFunc(s, wrapspace) {
+		line = append(line, word)
+		x += measure(word) + space
+		if x > width {
+			lines = append(lines, strings.Join(line, " "))
+			line = line[:0]
+			x = 0
+		}
+	}
+	return append(lines, strings.Join(line, " "))
+}
+
+// WrapWithin breaks text into lines of words that fit the width, as text boxes need;
+// a word wider than the width has a line of its own.
+func WrapWithin(s string, width, space float64, measure func(string) float64) []string {
+	var lines []string
+	var line []string
+	x := 0.0
+	for _, word := range strings.FieldsFunc(s, wrapspace) {
+		w := measure(word)
+		if len(line) > 0 && x+w > width {
+			lines = append(lines, strings.Join(line, " "))
+			line = line[:0]
+			x = 0
+		}
+		line = append(line, word)
+		x += w + space
+	}
+	return append(lines, strings.Join(line, " "))
+}
+
+// wrapspace determines if a rune separates words when wrapping
+func wrapspace(r rune) bool {
+	return r == ' ' || r == '\n' || r == '\t'
+}
+
+// Underline reports whether text is underlined
+func (t TextStyle) Underline() bool {
+	return t.decorated("underline")
+}
+
+// Strike reports whether text is struck through
+func (t TextStyle) Strike() bool {
+	return t.decorated("strike", "strikethrough", "line-through")
+}
+
+// decorated reports whether the decoration includes any of the names
+func (t TextStyle) decorated(names ...string) bool {
+	for _, d := range strings.Fields(t.Decoration) {
+		for _, name := range names {
+			if d == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Highlighted reports whether text has a highlight; highlight="none" turns off the highlight of a list for an item
+func (t TextStyle) Highlighted() bool {
+	return t.Highlight != "" && !off(t.Highlight)
+}
+
+// Tracking returns the space added between the letters of text of size fs
+func (t TextStyle) Tracking(fs float64) float64 {
+	return fs * t.LetterSpacing / 100
+}
+
+// Inherit returns the style with the attributes it does not set taken from the parent,
+// as list items take the style of their list
+func (t TextStyle) Inherit(parent TextStyle) TextStyle {
+	if t.Decoration == "" {
+		t.Decoration = parent.Decoration
+	}
+	if t.Highlight == "" {
+		t.Highlight = parent.Highlight
+	}
+	if t.LetterSpacing == 0 {
+		t.LetterSpacing = parent.LetterSpacing
+	}
+	return t
+}
+
+// Lines returns the positions of the lines decorating text of size fs set on the baseline y,
+// measured down the page, and the thickness of the lines
+func (t TextStyle) Lines(y, fs float64) ([]float64, float64) {
+	var lines []float64
+	if t.Underline() {
+		lines = append(lines, y+fs*0.12)
+	}
+	if t.Strike() {
+		lines = append(lines, y-fs*0.3)
+	}
+	return lines, fs * 0.06
+}
+
+// HighlightBox returns the top and height of the highlight behind text of size fs
+// set on the baseline y, measured down the page, and the margin on either side of the text
+func (t TextStyle) HighlightBox(y, fs float64) (top, height, margin float64) {
+	return y - fs*0.95, fs * 1.25, fs * 0.1
+}
+
+// IsBox reports whether text is drawn as a text box
+func (t Text) IsBox() bool {
+	return t.Type == "block" && (t.Hp > 0 || t.Padding > 0 || t.Fill != "" || t.Border != "" || t.Fit != "")
+}
+
+// Wrapper returns the wrapping of block text: by paragraph, for text that is justified or hyphenated by h,
+// or within the width, for text boxes
+func (t Text) Wrapper(h *Hyphenator) func(s string, width, space float64, measure func(string) float64) []string {
+	switch {
+	case Justified(t.Align) || h != nil:
+		return Paragraph(h)
+	case t.IsBox():
+		return WrapWithin
+	}
+	return Wrap
+}
+
+// Box lays out a text box whose top, height and padding are given, measured down the page, holding the wrapped
+// lines of text of size fs with the leading. It returns the height of the box, which fits the text if not given,
+// and the baseline of the first line, placed by the vertical alignment.
+func (t Text) Box(top, height, pad float64, lines []string, fs, leading float64) (float64, float64) {
+	n := len(lines)
+	if n > 1 && lines[n-1] == "" {
+		n--
+	}
+	// from the top of the first line to the bottom of the last
+	th := fs*1.05 + float64(n-1)*leading
+	if height <= 0 {
+		height = th + pad*2
+	}
+	ty := top + pad
+	switch t.VAlign {
+	case "middle", "mid", "center", "c":
+		ty = top + (height-th)/2
+	case "bottom", "end", "b":
+		ty = top + height - pad - th
+	}
+	return height, ty + fs*0.8
+}
@@ -0,0 +1,136 @@
+package deck
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// pathsteps is the number of line segments approximating a text path
+const pathsteps = 256
+
+// Glyph is a character of text on a path, placed by the start of its baseline, measured down the page,
+// and turned counterclockwise by the angle, in degrees
+type Glyph struct {
+	Text  string
+	X, Y  float64
+	Angle float64
+}
+
+// IsCurve reports whether text is laid out along a curve, rather than an arc
+func (t TextPath) IsCurve() bool {
+	return t.Xp1 != 0 || t.Yp1 != 0 || t.Xp2 != 0 || t.Yp2 != 0 || t.Xp3 != 0 || t.Yp3 != 0
+}
+
+// Angles returns the beginning and ending angles of the arc of a text path, in degrees.
+// The same angles make the whole circle, clockwise, so that text around it reads from the outside.
+func (t TextPath) Angles() (float64, float64) {
+	if t.A1 == t.A2 {
+		return t.A1, t.A1 - 360
+	}
+	return t.A1, t.A2
+}
+
+// Radii returns the radii of the arc of a text path on a canvas of width cw; the height is the width if not set
+func (t TextPath) Radii(cw float64) (float64, float64) {
+	h := t.Hp
+	if h == 0 {
+		h = t.Wp
+	}
+	return t.Wp * cw / 200, h * cw / 200
+}
+
+// Text returns the text of a text path, on one line
+func (t TextPath) Text() string {
+	return strings.Join(strings.Fields(t.Tdata), " ")
+}
+
+// Anchor returns the alignment of text on a path at its offset: start, middle or end
+func (t TextPath) Anchor() string {
+	switch t.Align {
+	case "center", "middle", "mid", "c":
+		return "middle"
+	case "right", "end", "e":
+		return "end"
+	}
+	return "start"
+}
+
+// points returns the points along the path on a canvas of width cw and height ch, measured down the page
+func (t TextPath) points(cw, ch float64) [][2]float64 {
+	at := func(xp, yp float64) (float64, float64) {
+		return xp * cw / 100, (100 - yp) * ch / 100
+	}
+	pts := make([][2]float64, pathsteps+1)
+	if t.IsCurve() {
+		x1, y1 := at(t.Xp1, t.Yp1)
+		x2, y2 := at(t.Xp2, t.Yp2)
+		x3, y3 := at(t.Xp3, t.Yp3)
+		for i := range pts {
+			u := float64(i) / pathsteps
+			a, b, c := (1-u)*(1-u), 2*(1-u)*u, u*u
+			pts[i] = [2]float64{a*x1 + b*x2 + c*x3, a*y1 + b*y2 + c*y3}
+		}
+		return pts
+	}
+	cx, cy := at(t.Xp, t.Yp)
+	rx, ry := t.Radii(cw)
+	a1, a2 := t.Angles()
+	for i := range pts {
+		a := (a1 + (a2-a1)*float64(i)/pathsteps) * math.Pi / 180
+		pts[i] = [2]float64{cx + rx*math.Cos(a), cy - ry*math.Sin(a)}
+	}
+	return pts
+}
+
+// Glyphs lays out the characters of text along its path, on a canvas of width cw and height ch,
+// each as wide as measured. The text begins at the offset along the path, or is centered on it or ends at it,
+// by its alignment; characters beyond the ends of the path are left out.
+func (t TextPath) Glyphs(cw, ch float64, measure func(string) float64) []Glyph {
+	pts := t.points(cw, ch)
+	lengths := make([]float64, len(pts))
+	for i := 1; i < len(pts); i++ {
+		lengths[i] = lengths[i-1] + math.Hypot(pts[i][0]-pts[i-1][0], pts[i][1]-pts[i-1][1])
+	}
+	total := lengths[len(lengths)-1]
+	var chars []string
+	var widths []float64
+	tw := 0.0
+	for s := t.Text(); len(s) > 0; {
+		_, n := utf8.DecodeRuneInString(s)
+		w := measure(s[:n])
+		chars, widths = append(chars, s[:n]), append(widths, w)
+		tw += w
+		s = s[n:]
+	}
+	d := t.Offset * total / 100
+	switch t.Anchor() {
+	case "middle":
+		d -= tw / 2
+	case "end":
+		d -= tw
+	}
+	var glyphs []Glyph
+	seg := 1
+	for i, c := range chars {
+		mid := d + widths[i]/2
+		d += widths[i]
+		if mid < 0 || mid > total {
+			continue
+		}
+		for seg < len(lengths)-1 && lengths[seg] < mid {
+			seg++
+		}
+		p, q := pts[seg-1], pts[seg]
+		dx, dy := q[0]-p[0], q[1]-p[1]
+		f := 0.0
+		if l := lengths[seg] - lengths[seg-1]; l > 0 {
+			f = (mid - lengths[seg-1]) / l
+		}
+		a := math.Atan2(-dy, dx)
+		x := p[0] + f*dx - widths[i]/2*math.Cos(a)
+		y := p[1] + f*dy + widths[i]/2*math.Sin(a)
+		glyphs = append(glyphs, Glyph{Text: c, X: x, Y: y, Angle: a * 180 / math.Pi})
+	}
+	return glyphs
+}
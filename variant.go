@@ -0,0 +1,141 @@
+package deck
+
+import "strings"
+
+// Variant limits content to some cuts of a deck, named by tags:
+// content with only="short,public" is in the cuts made with either tag,
+// content with except="short" is left out of the cuts made with the tag.
+// <slide only="long">, <text except="public">
+type Variant struct {
+	Only   string `xml:"only,attr,omitempty"`   // comma separated tags
+	Except string `xml:"except,attr,omitempty"` // comma separated tags
+}
+
+// Tags splits a comma separated list of tags
+func Tags(s string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// Selected reports whether content is in the cut made with the tags.
+// With no tags, all content is selected.
+func (v Variant) Selected(tags []string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	has := func(list string) bool {
+		for _, t := range Tags(list) {
+			for _, tag := range tags {
+				if t == tag {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if v.Only != "" && !has(v.Only) {
+		return false
+	}
+	return !has(v.Except)
+}
+
+// cut keeps the slides, and the content of slides, header and footer, selected by the tags
+func (d *Deck) cut(tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	d.Header = d.Header.cut(tags)
+	d.Footer = d.Footer.cut(tags)
+	var slides []Slide
+	for _, s := range d.Slide {
+		if !s.Selected(tags) {
+			continue
+		}
+		o := Overlay{s.List, s.Text, s.Image, s.Ellipse, s.Line, s.Rect, s.Curve, s.Arc, s.Polygon, s.TextPath, s.QRCode}.cut(tags)
+		s.List, s.Text, s.Image, s.Ellipse, s.Line, s.Rect, s.Curve, s.Arc, s.Polygon, s.TextPath, s.QRCode =
+			o.List, o.Text, o.Image, o.Ellipse, o.Line, o.Rect, o.Curve, o.Arc, o.Polygon, o.TextPath, o.QRCode
+		var toc []TOC
+		for _, t := range s.TOC {
+			if t.Selected(tags) {
+				toc = append(toc, t)
+			}
+		}
+		s.TOC = toc
+		slides = append(slides, s)
+	}
+	d.Slide = slides
+}
+
+// cut returns the content selected by the tags
+func (o Overlay) cut(tags []string) Overlay {
+	var c Overlay
+	for _, l := range o.List {
+		if !l.Selected(tags) {
+			continue
+		}
+		var li []ListItem
+		for _, item := range l.Li {
+			if item.Selected(tags) {
+				li = append(li, item)
+			}
+		}
+		l.Li = li
+		c.List = append(c.List, l)
+	}
+	for _, t := range o.Text {
+		if t.Selected(tags) {
+			c.Text = append(c.Text, t)
+		}
+	}
+	for _, im := range o.Image {
+		if im.Selected(tags) {
+			c.Image = append(c.Image, im)
+		}
+	}
+	for _, e := range o.Ellipse {
+		if e.Selected(tags) {
+			c.Ellipse = append(c.Ellipse, e)
+		}
+	}
+	for _, l := range o.Line {
+		if l.Selected(tags) {
+			c.Line = append(c.Line, l)
+		}
+	}
+	for _, r := range o.Rect {
+		if r.Selected(tags) {
+			c.Rect = append(c.Rect, r)
+		}
+	}
+	for _, cv := range o.Curve {
+		if cv.Selected(tags) {
+			c.Curve = append(c.Curve, cv)
+		}
+	}
+	for _, a := range o.Arc {
+		if a.Selected(tags) {
+			c.Arc = append(c.Arc, a)
+		}
+	}
+	for _, p := range o.Polygon {
+		if p.Selected(tags) {
+			c.Polygon = append(c.Polygon, p)
+		}
+	}
+	for _, t := range o.TextPath {
+		if t.Selected(tags) {
+			c.TextPath = append(c.TextPath, t)
+		}
+	}
+	for _, q := range o.QRCode {
+		if q.Selected(tags) {
+			c.QRCode = append(c.QRCode, q)
+		}
+	}
+	return c
+}